@@ -0,0 +1,87 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/upbound/upjet/pkg/resource/json"
+	"github.com/upbound/upjet/pkg/terraform"
+)
+
+func main() {
+	var (
+		app      = kingpin.New(filepath.Base(os.Args[0]), "Collects the debug bundle of a managed resource from the debug endpoint of a running Upjet based provider.").DefaultEnvars()
+		endpoint = app.Flag("endpoint", "Address of the server the debug handler is mounted on, e.g., the port-forwarded metrics server of the provider").Short('e').Default("http://localhost:8080").String()
+		uid      = app.Flag("uid", "UID of the managed resource").Short('u').Required().String()
+		outDir   = app.Flag("out", "Directory to write the debug bundle into. Defaults to debug-bundle-<UID>").Short('o').String()
+		timeout  = app.Flag("timeout", "Timeout of the request to the debug endpoint").Default("30s").Duration()
+	)
+	kingpin.MustParse(app.Parse(os.Args[1:]))
+
+	dir := *outDir
+	if dir == "" {
+		dir = "debug-bundle-" + *uid
+	}
+	b, err := fetch(*endpoint, *uid, *timeout)
+	kingpin.FatalIfError(err, "Failed to fetch the debug bundle")
+	kingpin.FatalIfError(write(dir, b), "Failed to write the debug bundle")
+	fmt.Printf("Debug bundle of the managed resource with UID %s is written to %s\n", *uid, dir)
+}
+
+func fetch(endpoint, uid string, timeout time.Duration) (*terraform.DebugBundle, error) {
+	url := strings.TrimSuffix(endpoint, "/") + terraform.DebugPathPrefix + "workspaces/" + uid
+	resp, err := (&http.Client{Timeout: timeout}).Get(url) //nolint:noctx
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get %s", url)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read the response body")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	b := &terraform.DebugBundle{}
+	return b, errors.Wrap(json.JSParser.Unmarshal(body, b), "cannot unmarshal the debug bundle")
+}
+
+// write writes the Terraform files of the given bundle into the given
+// directory together with a workspace.json file holding the rest of the
+// bundle, i.e., the CLI invocations and the runner information.
+func write(dir string, b *terraform.DebugBundle) error {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return errors.Wrapf(err, "cannot create the directory %s", dir)
+	}
+	files := map[string]string{
+		"main.tf.json":      b.MainTF,
+		"main.tf":           b.MainTFHCL,
+		"terraform.tfstate": b.State,
+	}
+	for name, content := range files {
+		if content == "" {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			return errors.Wrapf(err, "cannot write %s", name)
+		}
+	}
+	b.MainTF, b.MainTFHCL, b.State = "", "", ""
+	data, err := json.JSParser.Marshal(b)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal the workspace information")
+	}
+	return errors.Wrap(os.WriteFile(filepath.Join(dir, "workspace.json"), data, 0600), "cannot write workspace.json")
+}
@@ -24,6 +24,11 @@ const (
 	// PackageNameMonolith is the name of the backwards-compatible
 	// provider subpackage that contains the all the resources.
 	PackageNameMonolith = "monolith"
+	// DataSourceReferencePrefix is the prefix of the Reference.TerraformName
+	// values referring to generated Terraform data sources, i.e., the
+	// reference target is "data.aws_caller_identity" for the
+	// "aws_caller_identity" data source.
+	DataSourceReferencePrefix = "data."
 )
 
 // Commonly used resource configurations.
@@ -94,15 +99,40 @@ func DefaultResource(name string, terraformSchema *schema.Resource, terraformReg
 	return r
 }
 
+// DefaultDataSource keeps an initial default configuration for the Terraform
+// data sources of a provider. Data sources are generated as observe-only
+// managed resources whose kinds are suffixed with "DataSource" so that they
+// do not collide with the kinds of the managed resources with the same name,
+// e.g. "aws_ami" data source yields the "AMIDataSource" kind.
+func DefaultDataSource(name string, terraformSchema *schema.Resource, opts ...ResourceOption) *Resource {
+	r := DefaultResource(name, terraformSchema, nil)
+	r.Kind += "DataSource"
+	r.DataSource = true
+	r.UseAsync = false
+	r.ExternalName = IdentifierFromProvider
+	for _, f := range opts {
+		f(r)
+	}
+	return r
+}
+
 // MoveToStatus moves given fields and their leaf fields to the status as
 // a whole. It's used mostly in cases where there is a field that is
 // represented as a separate CRD, hence you'd like to remove that field from
 // spec.
 func MoveToStatus(sch *schema.Resource, fieldpaths ...string) {
+	moveToStatus(sch, fieldpaths...)
+}
+
+// moveToStatus moves the given fields to the status and returns the ones that
+// do not exist in the schema.
+func moveToStatus(sch *schema.Resource, fieldpaths ...string) []string {
+	var unknown []string
 	for _, f := range fieldpaths {
 		s := GetSchema(sch, f)
 		if s == nil {
-			return
+			unknown = append(unknown, f)
+			continue
 		}
 		s.Optional = false
 		s.Computed = true
@@ -118,6 +148,7 @@ func MoveToStatus(sch *schema.Resource, fieldpaths ...string) {
 			MoveToStatus(el, l...)
 		}
 	}
+	return unknown
 }
 
 // MarkAsRequired marks the schema of the given fieldpath as required. It's most
@@ -125,14 +156,52 @@ func MoveToStatus(sch *schema.Resource, fieldpaths ...string) {
 // defaulted by the provider but we need it to exist or to fix plain buggy
 // schemas.
 func MarkAsRequired(sch *schema.Resource, fieldpaths ...string) {
+	markAsRequired(sch, fieldpaths...)
+}
+
+// markAsRequired marks the given fields as required and returns the ones that
+// do not exist in the schema.
+func markAsRequired(sch *schema.Resource, fieldpaths ...string) []string {
+	var unknown []string
 	for _, fieldpath := range fieldpaths {
-		if s := GetSchema(sch, fieldpath); s != nil {
-			s.Computed = false
-			s.Optional = false
+		s := GetSchema(sch, fieldpath)
+		if s == nil {
+			unknown = append(unknown, fieldpath)
+			continue
 		}
+		s.Computed = false
+		s.Optional = false
+	}
+	return unknown
+}
+
+// MoveToStatus moves the given fields of the Terraform schema of this
+// resource and their leaf fields to the status. Unlike the MoveToStatus
+// function, the field paths that do not exist in the schema are recorded
+// and reported by Provider.Validate.
+func (r *Resource) MoveToStatus(fieldpaths ...string) {
+	for _, f := range moveToStatus(r.TerraformResource, fieldpaths...) {
+		r.unknownFieldPaths = append(r.unknownFieldPaths, unknownFieldPath{fn: "MoveToStatus", path: f})
 	}
 }
 
+// MarkAsRequired marks the given fields of the Terraform schema of this
+// resource as required. Unlike the MarkAsRequired function, the field paths
+// that do not exist in the schema are recorded and reported by
+// Provider.Validate.
+func (r *Resource) MarkAsRequired(fieldpaths ...string) {
+	for _, f := range markAsRequired(r.TerraformResource, fieldpaths...) {
+		r.unknownFieldPaths = append(r.unknownFieldPaths, unknownFieldPath{fn: "MarkAsRequired", path: f})
+	}
+}
+
+// unknownFieldPath is a field path that was given to a schema manipulation
+// function of a Resource but does not exist in its Terraform schema.
+type unknownFieldPath struct {
+	fn   string
+	path string
+}
+
 // GetSchema returns the schema of the field whose fieldpath is given.
 // Returns nil if Schema is not found at the specified path.
 func GetSchema(sch *schema.Resource, fieldpath string) *schema.Schema {
@@ -100,10 +100,7 @@ func ParameterAsIdentifier(param string) ExternalName {
 // TemplatedStringAsIdentifier("index_name", "{{ .parameters.cluster_id }}:{{ .parameters.node_id }}:{{ .external_name }}")
 // TemplatedStringAsIdentifier("", "arn:aws:network-firewall:{{ .setup.configuration.region }}:{{ .setup.client_metadata.account_id }}:{{ .parameters.type | ToLower }}-rulegroup/{{ .external_name }}")
 func TemplatedStringAsIdentifier(nameFieldPath, tmpl string) ExternalName {
-	t, err := template.New("getid").Funcs(template.FuncMap{
-		"ToLower": strings.ToLower,
-		"ToUpper": strings.ToUpper,
-	}).Parse(tmpl)
+	t, err := parseExternalNameTemplate(tmpl)
 	if err != nil {
 		panic(errors.Wrap(err, "cannot parse template"))
 	}
@@ -159,6 +156,15 @@ func TemplatedStringAsIdentifier(nameFieldPath, tmpl string) ExternalName {
 	}
 }
 
+// parseExternalNameTemplate parses the given external name template with the
+// functions available to TemplatedStringAsIdentifier.
+func parseExternalNameTemplate(tmpl string) (*template.Template, error) {
+	return template.New("getid").Funcs(template.FuncMap{
+		"ToLower": strings.ToLower,
+		"ToUpper": strings.ToUpper,
+	}).Parse(tmpl)
+}
+
 // GetExternalNameFromTemplated takes a Terraform ID and the template it's produced
 // from and reverse it to get the external name. For example, you can supply
 // "/subscription/{{ .paramters.some }}/{{ .external_name }}" with
@@ -0,0 +1,331 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package config
+
+import (
+	"bytes"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ExternalNamePresetNameAsIdentifier selects NameAsIdentifier.
+	ExternalNamePresetNameAsIdentifier = "NameAsIdentifier"
+	// ExternalNamePresetIdentifierFromProvider selects IdentifierFromProvider.
+	ExternalNamePresetIdentifierFromProvider = "IdentifierFromProvider"
+	// ExternalNamePresetParameterAsIdentifier selects ParameterAsIdentifier
+	// with the configured parameter.
+	ExternalNamePresetParameterAsIdentifier = "ParameterAsIdentifier"
+	// ExternalNamePresetTemplatedStringAsIdentifier selects
+	// TemplatedStringAsIdentifier with the configured name field path and
+	// template.
+	ExternalNamePresetTemplatedStringAsIdentifier = "TemplatedStringAsIdentifier"
+
+	errReadResourceConfiguration   = "cannot read the resource configuration file"
+	errDecodeResourceConfiguration = "cannot decode the resource configuration"
+	errFmtLine                     = "line %d: %s"
+	errFmtUnknownPreset            = "resources[%s].externalName.preset: unknown external name preset %q"
+	errFmtMissingPresetField       = "resources[%s].externalName.%s: required by the %s preset"
+	errFmtInvalidTemplate          = "resources[%s].externalName.template: cannot parse the template: %s"
+	errFmtMissingReferenceTarget   = "resources[%s].references[%s]: either terraformName or type must be set"
+	errFmtNegativeTimeout          = "resources[%s].operationTimeouts.%s: cannot be negative"
+	errFmtUnknownResource          = "resources[%s]: resource is not generated by the provider"
+	errFmtUnknownField             = "resources[%s].%s: field %q does not exist in the Terraform schema"
+)
+
+// ResourceConfiguration is the declarative configuration of the resources of
+// a provider. It can be written in YAML or JSON so that the common
+// customizations don't require writing Go ResourceConfiguratorFns.
+type ResourceConfiguration struct {
+	// Resources are the overrides keyed by Terraform resource name.
+	Resources map[string]ResourceOverride `yaml:"resources"`
+
+	// root is the parsed document used for reporting the line numbers of
+	// the errors found after decoding.
+	root *yaml.Node
+}
+
+// ResourceOverride is the declarative configuration of a single resource.
+type ResourceOverride struct {
+	// Kind overrides the kind of the generated CRD.
+	Kind string `yaml:"kind"`
+	// ShortGroup overrides the short API group of the generated CRD.
+	ShortGroup string `yaml:"shortGroup"`
+	// Version overrides the version of the generated CRD.
+	Version string `yaml:"version"`
+	// ExternalName selects one of the built-in external name configurations.
+	ExternalName *ExternalNameOverride `yaml:"externalName"`
+	// References are the cross resource references keyed by Terraform
+	// field path.
+	References map[string]ReferenceOverride `yaml:"references"`
+	// LateInitializerIgnoredFields are the Terraform field paths to be
+	// skipped during late-initialization.
+	LateInitializerIgnoredFields []string `yaml:"lateInitializerIgnoredFields"`
+	// OperationTimeouts are the timeouts of the Terraform operations, e.g.
+	// "10m".
+	OperationTimeouts *OperationTimeoutsOverride `yaml:"operationTimeouts"`
+	// MoveToStatus are the Terraform field paths to be moved to the status.
+	MoveToStatus []string `yaml:"moveToStatus"`
+	// MarkAsRequired are the Terraform field paths to be marked as required.
+	MarkAsRequired []string `yaml:"markAsRequired"`
+	// PrinterColumns are the additional printer columns of the generated
+	// CRD.
+	PrinterColumns []PrinterColumnOverride `yaml:"printerColumns"`
+	// ShortNames are the short names of the generated CRD.
+	ShortNames []string `yaml:"shortNames"`
+	// Categories are the additional categories of the generated CRD.
+	Categories []string `yaml:"categories"`
+}
+
+// ExternalNameOverride selects a built-in external name configuration.
+type ExternalNameOverride struct {
+	// Preset is the name of the built-in external name configuration, one
+	// of NameAsIdentifier, IdentifierFromProvider, ParameterAsIdentifier and
+	// TemplatedStringAsIdentifier.
+	Preset string `yaml:"preset"`
+	// Parameter is the identifier parameter of the ParameterAsIdentifier
+	// preset.
+	Parameter string `yaml:"parameter"`
+	// NameFieldPath is the name field path of the
+	// TemplatedStringAsIdentifier preset.
+	NameFieldPath string `yaml:"nameFieldPath"`
+	// Template is the template of the TemplatedStringAsIdentifier preset.
+	Template string `yaml:"template"`
+}
+
+// ReferenceOverride is the declarative counterpart of Reference.
+type ReferenceOverride struct {
+	TerraformName     string `yaml:"terraformName"`
+	Type              string `yaml:"type"`
+	Extractor         string `yaml:"extractor"`
+	RefFieldName      string `yaml:"refFieldName"`
+	SelectorFieldName string `yaml:"selectorFieldName"`
+}
+
+// PrinterColumnOverride is the declarative counterpart of PrinterColumn.
+type PrinterColumnOverride struct {
+	Name      string `yaml:"name"`
+	FieldPath string `yaml:"fieldPath"`
+	Type      string `yaml:"type"`
+	Priority  int    `yaml:"priority"`
+}
+
+// OperationTimeoutsOverride is the declarative counterpart of
+// OperationTimeouts.
+type OperationTimeoutsOverride struct {
+	Read   time.Duration `yaml:"read"`
+	Create time.Duration `yaml:"create"`
+	Update time.Duration `yaml:"update"`
+	Delete time.Duration `yaml:"delete"`
+}
+
+// ParseResourceConfiguration parses and validates the given YAML or JSON
+// resource configuration. Unknown fields are rejected and the returned
+// errors contain the line numbers of the offending fields.
+func ParseResourceConfiguration(data []byte) (*ResourceConfiguration, error) {
+	c := &ResourceConfiguration{root: &yaml.Node{}}
+	if err := yaml.Unmarshal(data, c.root); err != nil {
+		return nil, errors.Wrap(err, errDecodeResourceConfiguration)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	// An empty document is a valid configuration with no overrides.
+	if err := dec.Decode(c); err != nil && len(bytes.TrimSpace(data)) != 0 {
+		return nil, errors.Wrap(err, errDecodeResourceConfiguration)
+	}
+	for _, name := range c.resourceNames() {
+		if err := c.validate(name); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// LoadResourceConfiguration reads the resource configuration file at the
+// given path and adds its overrides to the provider as resource
+// configurators.
+func (p *Provider) LoadResourceConfiguration(path string) error {
+	data, err := os.ReadFile(path) //nolint:gosec // the path is supplied by the provider author
+	if err != nil {
+		return errors.Wrap(err, errReadResourceConfiguration)
+	}
+	c, err := ParseResourceConfiguration(data)
+	if err != nil {
+		return errors.Wrapf(err, "cannot parse the resource configuration file %s", path)
+	}
+	return c.AddTo(p)
+}
+
+// AddTo adds the overrides as resource configurators to the given provider.
+// It returns an error if an override refers to a resource or a field that
+// the provider does not have.
+func (c *ResourceConfiguration) AddTo(p *Provider) error {
+	for _, name := range c.resourceNames() {
+		r, ok := p.Resources[name]
+		if !ok {
+			return c.errorf([]string{"resources", name}, errFmtUnknownResource, name)
+		}
+		o := c.Resources[name]
+		fields := []struct {
+			key   string
+			paths []string
+		}{{key: "moveToStatus", paths: o.MoveToStatus}, {key: "markAsRequired", paths: o.MarkAsRequired}}
+		for _, fs := range fields {
+			for _, f := range fs.paths {
+				if r.TerraformResource == nil || GetSchema(r.TerraformResource, f) == nil {
+					return c.errorf([]string{"resources", name, fs.key}, errFmtUnknownField, name, fs.key, f)
+				}
+			}
+		}
+		p.AddResourceConfigurator(name, o.configure)
+	}
+	return nil
+}
+
+// configure applies the override to the given resource configuration.
+func (o ResourceOverride) configure(r *Resource) {
+	if o.Kind != "" {
+		r.Kind = o.Kind
+	}
+	if o.ShortGroup != "" {
+		r.ShortGroup = o.ShortGroup
+	}
+	if o.Version != "" {
+		r.Version = o.Version
+	}
+	if o.ExternalName != nil {
+		r.ExternalName = o.ExternalName.externalName()
+	}
+	for f, ref := range o.References {
+		r.References[f] = Reference{
+			TerraformName:     ref.TerraformName,
+			Type:              ref.Type,
+			Extractor:         ref.Extractor,
+			RefFieldName:      ref.RefFieldName,
+			SelectorFieldName: ref.SelectorFieldName,
+		}
+	}
+	r.LateInitializer.IgnoredFields = append(r.LateInitializer.IgnoredFields, o.LateInitializerIgnoredFields...)
+	if t := o.OperationTimeouts; t != nil {
+		r.OperationTimeouts = OperationTimeouts{
+			Read:   t.Read,
+			Create: t.Create,
+			Update: t.Update,
+			Delete: t.Delete,
+		}
+	}
+	for _, c := range o.PrinterColumns {
+		r.PrinterColumns = append(r.PrinterColumns, PrinterColumn{
+			Name:      c.Name,
+			FieldPath: c.FieldPath,
+			Type:      c.Type,
+			Priority:  c.Priority,
+		})
+	}
+	r.ShortNames = append(r.ShortNames, o.ShortNames...)
+	r.Categories = append(r.Categories, o.Categories...)
+	r.MoveToStatus(o.MoveToStatus...)
+	r.MarkAsRequired(o.MarkAsRequired...)
+}
+
+func (e ExternalNameOverride) externalName() ExternalName {
+	switch e.Preset {
+	case ExternalNamePresetIdentifierFromProvider:
+		return IdentifierFromProvider
+	case ExternalNamePresetParameterAsIdentifier:
+		return ParameterAsIdentifier(e.Parameter)
+	case ExternalNamePresetTemplatedStringAsIdentifier:
+		return TemplatedStringAsIdentifier(e.NameFieldPath, e.Template)
+	default:
+		return NameAsIdentifier
+	}
+}
+
+func (c *ResourceConfiguration) validate(name string) error { //nolint:gocyclo // a flat list of checks
+	o := c.Resources[name]
+	if e := o.ExternalName; e != nil {
+		path := []string{"resources", name, "externalName"}
+		switch e.Preset {
+		case ExternalNamePresetNameAsIdentifier, ExternalNamePresetIdentifierFromProvider:
+		case ExternalNamePresetParameterAsIdentifier:
+			if e.Parameter == "" {
+				return c.errorf(path, errFmtMissingPresetField, name, "parameter", e.Preset)
+			}
+		case ExternalNamePresetTemplatedStringAsIdentifier:
+			if e.Template == "" {
+				return c.errorf(path, errFmtMissingPresetField, name, "template", e.Preset)
+			}
+			if _, err := parseExternalNameTemplate(e.Template); err != nil {
+				return c.errorf(append(path, "template"), errFmtInvalidTemplate, name, err)
+			}
+		default:
+			return c.errorf(append(path, "preset"), errFmtUnknownPreset, name, e.Preset)
+		}
+	}
+	for f, ref := range o.References {
+		if ref.TerraformName == "" && ref.Type == "" {
+			return c.errorf([]string{"resources", name, "references", f}, errFmtMissingReferenceTarget, name, f)
+		}
+	}
+	if t := o.OperationTimeouts; t != nil {
+		keys := []string{"read", "create", "update", "delete"}
+		for i, d := range []time.Duration{t.Read, t.Create, t.Update, t.Delete} {
+			if d < 0 {
+				return c.errorf([]string{"resources", name, "operationTimeouts", keys[i]}, errFmtNegativeTimeout, name, keys[i])
+			}
+		}
+	}
+	return nil
+}
+
+// resourceNames returns the names of the configured resources in a stable
+// order so that the reported errors are deterministic.
+func (c *ResourceConfiguration) resourceNames() []string {
+	names := make([]string, 0, len(c.Resources))
+	for n := range c.Resources {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// errorf returns an error prefixed with the line number of the deepest node
+// found at the given path of mapping keys.
+func (c *ResourceConfiguration) errorf(path []string, format string, args ...any) error {
+	return errors.Errorf(errFmtLine, c.line(path), errors.Errorf(format, args...).Error())
+}
+
+func (c *ResourceConfiguration) line(path []string) int {
+	n := c.root
+	if n == nil {
+		return 0
+	}
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		n = n.Content[0]
+	}
+	line := n.Line
+	for _, key := range path {
+		if n.Kind != yaml.MappingNode {
+			break
+		}
+		found := false
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == key {
+				line = n.Content[i].Line
+				n = n.Content[i+1]
+				found = true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	return line
+}
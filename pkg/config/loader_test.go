@@ -0,0 +1,182 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+func TestLoadResourceConfiguration(t *testing.T) {
+	type want struct {
+		err                    error
+		kind                   string
+		references             References
+		timeouts               OperationTimeouts
+		disableNameInitializer bool
+		printerColumns         []PrinterColumn
+		shortNames             []string
+	}
+	cases := map[string]struct {
+		reason string
+		data   string
+		want   want
+	}{
+		"Success": {
+			reason: "The overrides should be applied to the resource configuration.",
+			data: `
+resources:
+  google_a:
+    kind: Alpha
+    externalName:
+      preset: IdentifierFromProvider
+    references:
+      name:
+        terraformName: google_b
+    operationTimeouts:
+      create: 10m
+    markAsRequired:
+      - name
+    printerColumns:
+      - name: NAME
+        fieldPath: spec.forProvider.name
+    shortNames:
+      - alpha
+`,
+			want: want{
+				kind: "Alpha",
+				references: References{
+					"name": {TerraformName: "google_b"},
+				},
+				timeouts:               OperationTimeouts{Create: 10 * time.Minute},
+				disableNameInitializer: true,
+				printerColumns:         []PrinterColumn{{Name: "NAME", FieldPath: "spec.forProvider.name"}},
+				shortNames:             []string{"alpha"},
+			},
+		},
+		"JSON": {
+			reason: "The configuration can be written in JSON.",
+			data:   `{"resources": {"google_a": {"kind": "Alpha"}}}`,
+			want: want{
+				kind:       "Alpha",
+				references: References{},
+			},
+		},
+		"UnknownField": {
+			reason: "Unknown fields should be rejected with their line numbers.",
+			data: `
+resources:
+  google_a:
+    kindd: Alpha
+`,
+			want: want{
+				err: errors.Wrap(errors.New("yaml: unmarshal errors:\n  line 4: field kindd not found in type config.ResourceOverride"), errDecodeResourceConfiguration),
+			},
+		},
+		"UnknownPreset": {
+			reason: "Unknown external name presets should be rejected with their line numbers.",
+			data: `
+resources:
+  google_a:
+    externalName:
+      preset: Unknown
+`,
+			want: want{
+				err: errors.Errorf(errFmtLine, 5, errors.Errorf(errFmtUnknownPreset, "google_a", "Unknown").Error()),
+			},
+		},
+		"MissingParameter": {
+			reason: "The ParameterAsIdentifier preset should require a parameter.",
+			data: `
+resources:
+  google_a:
+    externalName:
+      preset: ParameterAsIdentifier
+`,
+			want: want{
+				err: errors.Errorf(errFmtLine, 4, errors.Errorf(errFmtMissingPresetField, "google_a", "parameter", "ParameterAsIdentifier").Error()),
+			},
+		},
+		"InvalidTemplate": {
+			reason: "The template of the TemplatedStringAsIdentifier preset should be parsed.",
+			data: `
+resources:
+  google_a:
+    externalName:
+      preset: TemplatedStringAsIdentifier
+      nameFieldPath: name
+      template: "{{ .external_name "
+`,
+			want: want{
+				err: errors.Errorf(errFmtLine, 7, errors.Errorf(errFmtInvalidTemplate, "google_a", `template: getid:1: unclosed action`).Error()),
+			},
+		},
+		"UnknownResource": {
+			reason: "Overrides of the resources that are not generated should be rejected.",
+			data: `
+resources:
+  google_z:
+    kind: Zeta
+`,
+			want: want{
+				err: errors.Errorf(errFmtLine, 3, errors.Errorf(errFmtUnknownResource, "google_z").Error()),
+			},
+		},
+		"UnknownSchemaField": {
+			reason: "Field moves should be rejected if the field does not exist in the Terraform schema.",
+			data: `
+resources:
+  google_a:
+    moveToStatus:
+      - missing
+`,
+			want: want{
+				err: errors.Errorf(errFmtLine, 4, errors.Errorf(errFmtUnknownField, "google_a", "moveToStatus", "missing").Error()),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := NewProvider([]byte(testProviderSchema), "google", "github.com/upbound/provider-test", nil,
+				WithIncludeList([]string{"google_a$"}),
+				WithAdditionalSchemas(AdditionalSchema{Source: "registry.terraform.io/hashicorp/google-beta", Name: "google-beta", IncludeList: []string{}},
+					AdditionalSchema{Source: "registry.terraform.io/hashicorp/random", Name: "random", IncludeList: []string{}}))
+			c, err := ParseResourceConfiguration([]byte(tc.data))
+			if err == nil {
+				err = c.AddTo(p)
+			}
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("\n%s\nLoadResourceConfiguration(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if tc.want.err != nil {
+				return
+			}
+			p.ConfigureResources()
+			r := p.Resources["google_a"]
+			if diff := cmp.Diff(tc.want.kind, r.Kind); diff != "" {
+				t.Errorf("\n%s\nLoadResourceConfiguration(...): -want kind, +got kind:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.references, r.References); diff != "" {
+				t.Errorf("\n%s\nLoadResourceConfiguration(...): -want references, +got references:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.timeouts, r.OperationTimeouts); diff != "" {
+				t.Errorf("\n%s\nLoadResourceConfiguration(...): -want timeouts, +got timeouts:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.disableNameInitializer, r.ExternalName.DisableNameInitializer); diff != "" {
+				t.Errorf("\n%s\nLoadResourceConfiguration(...): -want disableNameInitializer, +got disableNameInitializer:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.printerColumns, r.PrinterColumns); diff != "" {
+				t.Errorf("\n%s\nLoadResourceConfiguration(...): -want printerColumns, +got printerColumns:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.shortNames, r.ShortNames); diff != "" {
+				t.Errorf("\n%s\nLoadResourceConfiguration(...): -want shortNames, +got shortNames:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
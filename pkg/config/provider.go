@@ -40,6 +40,27 @@ func (cc ResourceConfiguratorChain) Configure(r *Resource) {
 	}
 }
 
+// Templates are the overrides of the code generation templates. An empty
+// template means that the default template embedded in upjet is used. The
+// templates are Go text/template templates and, in addition to the variables
+// documented for each template, they can use the {{ .Header }},
+// {{ .GenStatement }} and {{ .Imports }} variables set by the file wrapper.
+type Templates struct {
+	// CRDTypes is the template of the CRD types files. It's executed with
+	// pipeline.CRDTypesTemplateVars.
+	CRDTypes string
+	// Controller is the template of the controller files. It's executed with
+	// pipeline.ControllerTemplateVars.
+	Controller string
+	// Terraformed is the template of the files implementing the
+	// resource.Terraformed interface for an API version. It's executed with
+	// pipeline.TerraformedTemplateVars.
+	Terraformed string
+	// Setup is the template of the controller setup files. It's executed with
+	// pipeline.SetupTemplateVars.
+	Setup string
+}
+
 // BasePackages keeps lists of packages that needs to be registered as API
 // and controllers. Typically, we expect to see ProviderConfig packages here.
 // These APIs and controllers belong to non-generated (manually maintained)
@@ -100,12 +121,27 @@ type Provider struct {
 	// ensure backwards-compatibility.
 	MainTemplate string
 
+	// Templates are the overrides of the code generation templates embedded
+	// in upjet. The templates of the individual resources can be overridden
+	// via Resource.Templates.
+	Templates Templates
+
 	// skippedResourceNames is a list of Terraform resource names
 	// available in the Terraform provider schema, but
 	// not in the include list or in the skip list, meaning that
 	// the corresponding managed resources are not generated.
 	skippedResourceNames []string
 
+	// skippedDataSourceNames is a list of Terraform data source names
+	// available in the main Terraform provider schema for which no managed
+	// resource is generated.
+	skippedDataSourceNames []string
+
+	// AdditionalSchemas configures the generation of resources from the
+	// Terraform providers other than the main one, when the provider schema
+	// contains multiple Terraform providers.
+	AdditionalSchemas []AdditionalSchema
+
 	// IncludeList is a list of regex for the Terraform resources to be
 	// included. For example, to include "aws_shield_protection_group" into
 	// the generated resources, one can add "aws_shield_protection_group$".
@@ -117,6 +153,16 @@ type Provider struct {
 	// resource name.
 	Resources map[string]*Resource
 
+	// DataSourceIncludeList is a list of regex for the Terraform data sources
+	// to be generated as observe-only managed resources. For example, to
+	// generate a kind for "aws_caller_identity" data source, one can add
+	// "aws_caller_identity$". No data source is generated by default.
+	DataSourceIncludeList []string
+
+	// DataSources is a map holding the configurations of the Terraform data
+	// sources to be generated, where key is Terraform data source name.
+	DataSources map[string]*Resource
+
 	// refInjectors is an ordered list of `ReferenceInjector`s for
 	// injecting references across this Provider's resources.
 	refInjectors []ReferenceInjector
@@ -124,6 +170,42 @@ type Provider struct {
 	// resourceConfigurators is a map holding resource configurators where key
 	// is Terraform resource name.
 	resourceConfigurators map[string]ResourceConfiguratorChain
+
+	// dataSourceConfigurators is a map holding data source configurators
+	// where key is Terraform data source name.
+	dataSourceConfigurators map[string]ResourceConfiguratorChain
+}
+
+// AdditionalSchema configures the generation of resources from the schema of
+// a Terraform provider other than the main one, e.g. google-beta while the
+// main Terraform provider is google.
+type AdditionalSchema struct {
+	// Source is the fully qualified source address of the Terraform provider
+	// as it appears in the provider schema, e.g.
+	// "registry.terraform.io/hashicorp/google-beta".
+	Source string
+
+	// Name is the local name of the Terraform provider, e.g. "google-beta".
+	// It's used as the key of the required_providers entry and the provider
+	// block in the Terraform configuration, and the key of the
+	// terraform.Setup.AdditionalProviders map at runtime.
+	Name string
+
+	// ResourcePrefix is the prefix used in the resources of this Terraform
+	// provider, e.g. "random_".
+	ResourcePrefix string
+
+	// IncludeList is a list of regex for the Terraform resources to be
+	// generated from this schema. Defaults to all the resources having the
+	// ResourcePrefix. Resources that
+	// are already generated from the main schema or from a preceding
+	// additional schema are not generated again, so one can skip a resource
+	// in the main schema to generate it from this one instead.
+	IncludeList []string
+
+	// SkipList is a list of regex for the Terraform resources of this schema
+	// to be skipped.
+	SkipList []string
 }
 
 // ReferenceInjector injects cross-resource references across the resources
@@ -178,6 +260,21 @@ func WithDefaultResourceOptions(opts ...ResourceOption) ProviderOption {
 	}
 }
 
+// WithDataSourceIncludeList configures DataSourceIncludeList for this
+// Provider.
+func WithDataSourceIncludeList(l []string) ProviderOption {
+	return func(p *Provider) {
+		p.DataSourceIncludeList = l
+	}
+}
+
+// WithAdditionalSchemas configures AdditionalSchemas for this Provider.
+func WithAdditionalSchemas(s ...AdditionalSchema) ProviderOption {
+	return func(p *Provider) {
+		p.AdditionalSchemas = s
+	}
+}
+
 // WithReferenceInjectors configures an ordered list of `ReferenceInjector`s
 // for this Provider. The configured reference resolvers are executed in order
 // to inject cross-resource references across this Provider's resources.
@@ -200,27 +297,37 @@ func WithMainTemplate(template string) ProviderOption {
 	}
 }
 
+// WithTemplates configures the code generation template overrides of this
+// Provider.
+func WithTemplates(t Templates) ProviderOption {
+	return func(p *Provider) {
+		p.Templates = t
+	}
+}
+
 // NewProvider builds and returns a new Provider from provider
 // tfjson schema, that is generated using Terraform CLI with:
 // `terraform providers schema --json`
-func NewProvider(schema []byte, prefix string, modulePath string, metadata []byte, opts ...ProviderOption) *Provider { // nolint:gocyclo
-	ps := tfjson.ProviderSchemas{}
-	if err := ps.UnmarshalJSON(schema); err != nil {
+// If the schema contains more than one Terraform provider, all but the main
+// one must be configured via the AdditionalSchemas option.
+// It panics on errors, see NewProviderE for an error-returning variant.
+func NewProvider(schema []byte, prefix string, modulePath string, metadata []byte, opts ...ProviderOption) *Provider {
+	p, err := NewProviderE(schema, prefix, modulePath, metadata, opts...)
+	if err != nil {
 		panic(err)
 	}
-	if len(ps.Schemas) != 1 {
-		panic(fmt.Sprintf("there should exactly be 1 provider schema but there are %d", len(ps.Schemas)))
-	}
-	var rs map[string]*tfjson.Schema
-	for _, v := range ps.Schemas {
-		rs = v.ResourceSchemas
-		break
-	}
+	return p
+}
 
-	resourceMap := conversiontfjson.GetV2ResourceMap(rs)
+// NewProviderE is the error-returning variant of NewProvider.
+func NewProviderE(schema []byte, prefix string, modulePath string, metadata []byte, opts ...ProviderOption) (*Provider, error) { // nolint:gocyclo
+	ps := tfjson.ProviderSchemas{}
+	if err := ps.UnmarshalJSON(schema); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal the provider schema")
+	}
 	providerMetadata, err := registry.NewProviderMetadataFromFile(metadata)
 	if err != nil {
-		panic(errors.Wrap(err, "cannot load provider metadata"))
+		return nil, errors.Wrap(err, "cannot load provider metadata")
 	}
 
 	p := &Provider{
@@ -233,32 +340,140 @@ func NewProvider(schema []byte, prefix string, modulePath string, metadata []byt
 			// Include all Resources
 			".+",
 		},
-		Resources:             map[string]*Resource{},
-		resourceConfigurators: map[string]ResourceConfiguratorChain{},
+		Resources:               map[string]*Resource{},
+		DataSources:             map[string]*Resource{},
+		resourceConfigurators:   map[string]ResourceConfiguratorChain{},
+		dataSourceConfigurators: map[string]ResourceConfiguratorChain{},
 	}
 
 	for _, o := range opts {
 		o(p)
 	}
+	if err := p.validateRegexLists(); err != nil {
+		return nil, err
+	}
 
-	p.skippedResourceNames = make([]string, 0, len(resourceMap))
-	for name, terraformResource := range resourceMap {
+	additional := make(map[string]bool, len(p.AdditionalSchemas))
+	for _, as := range p.AdditionalSchemas {
+		if additional[as.Source] {
+			return nil, errors.Errorf("the additional provider schema %q is configured more than once", as.Source)
+		}
+		additional[as.Source] = true
+	}
+	if len(ps.Schemas) != len(p.AdditionalSchemas)+1 {
+		return nil, errors.Errorf("there should exactly be %d provider schema(s) but there are %d", len(p.AdditionalSchemas)+1, len(ps.Schemas))
+	}
+	for _, as := range p.AdditionalSchemas {
+		if _, ok := ps.Schemas[as.Source]; !ok {
+			return nil, errors.Errorf("cannot find the additional provider schema %q", as.Source)
+		}
+	}
+	var mainSchema *tfjson.ProviderSchema
+	for source, s := range ps.Schemas {
+		if !additional[source] {
+			mainSchema = s
+		}
+	}
+
+	p.skippedResourceNames = make([]string, 0, len(mainSchema.ResourceSchemas))
+	if err := p.addResources(mainSchema, "", p.IncludeList, p.SkipList, providerMetadata); err != nil {
+		return nil, err
+	}
+	// Resources that have already been generated from the main schema or
+	// from a preceding additional schema are not overridden.
+	for _, as := range p.AdditionalSchemas {
+		includeList := as.IncludeList
+		if len(includeList) == 0 {
+			includeList = []string{"^" + regexp.QuoteMeta(as.ResourcePrefix) + ".+"}
+		}
+		if err := p.addResources(ps.Schemas[as.Source], as.Name, includeList, as.SkipList, providerMetadata); err != nil {
+			return nil, errors.Wrapf(err, "cannot add the resources of the additional provider schema %q", as.Source)
+		}
+	}
+	dataSources, err := conversiontfjson.GetV2ResourceMapE(mainSchema.DataSourceSchemas)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot convert the data source schemas")
+	}
+	for name, terraformDataSource := range dataSources {
+		included := false
+		if len(terraformDataSource.Schema) != 0 && len(p.DataSourceIncludeList) != 0 {
+			if included, err = matches(name, p.DataSourceIncludeList); err != nil {
+				return nil, err
+			}
+		}
+		if !included {
+			p.skippedDataSourceNames = append(p.skippedDataSourceNames, name)
+			continue
+		}
+		p.DataSources[name] = DefaultDataSource(name, terraformDataSource, p.DefaultResourceOptions...)
+	}
+	for i, refInjector := range p.refInjectors {
+		if err := refInjector.InjectReferences(p.Resources); err != nil {
+			return nil, errors.Wrapf(err, "cannot inject references using the configured ReferenceInjector at index %d", i)
+		}
+	}
+	return p, nil
+}
+
+// validateRegexLists makes sure that all the configured include and skip
+// lists are valid regular expressions, so that matching them never fails.
+func (p *Provider) validateRegexLists() error {
+	lists := map[string][]string{
+		"IncludeList":           p.IncludeList,
+		"SkipList":              p.SkipList,
+		"DataSourceIncludeList": p.DataSourceIncludeList,
+	}
+	for _, as := range p.AdditionalSchemas {
+		lists[as.Source+" IncludeList"] = as.IncludeList
+		lists[as.Source+" SkipList"] = as.SkipList
+	}
+	for name, l := range lists {
+		for _, r := range l {
+			if _, err := regexp.Compile(r); err != nil {
+				return errors.Wrapf(err, "cannot compile the regular expression %q in %s", r, name)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Provider) addResources(ps *tfjson.ProviderSchema, providerName string, includeList, skipList []string, providerMetadata *registry.ProviderMetadata) error {
+	opts := append([]ResourceOption{func(r *Resource) {
+		r.TerraformProviderName = providerName
+	}}, p.DefaultResourceOptions...)
+	resources, err := conversiontfjson.GetV2ResourceMapE(ps.ResourceSchemas)
+	if err != nil {
+		return errors.Wrap(err, "cannot convert the resource schemas")
+	}
+	for name, terraformResource := range resources {
 		if len(terraformResource.Schema) == 0 {
 			// There are resources with no schema, that we will address later.
 			fmt.Printf("Skipping resource %s because it has no schema\n", name)
+			p.skippedResourceNames = append(p.skippedResourceNames, name)
+			continue
+		}
+		skipped, err := matches(name, skipList)
+		if err != nil {
+			return err
+		}
+		included, err := matches(name, includeList)
+		if err != nil {
+			return err
 		}
-		if len(terraformResource.Schema) == 0 || matches(name, p.SkipList) || !matches(name, p.IncludeList) {
+		if skipped || !included {
 			p.skippedResourceNames = append(p.skippedResourceNames, name)
 			continue
 		}
-		p.Resources[name] = DefaultResource(name, terraformResource, providerMetadata.Resources[name], p.DefaultResourceOptions...)
-	}
-	for i, refInjector := range p.refInjectors {
-		if err := refInjector.InjectReferences(p.Resources); err != nil {
-			panic(errors.Wrapf(err, "cannot inject references using the configured ReferenceInjector at index %d", i))
+		if _, ok := p.Resources[name]; ok {
+			// The resource has already been added from the main schema or
+			// from a preceding additional schema.
+			fmt.Printf("Skipping resource %s of provider %q because a resource with the same name has already been added\n", name, providerName)
+			p.skippedResourceNames = append(p.skippedResourceNames, name)
+			continue
 		}
+		p.Resources[name] = DefaultResource(name, terraformResource, providerMetadata.Resources[name], opts...)
 	}
-	return p
+	return nil
 }
 
 // AddResourceConfigurator adds resource specific configurators.
@@ -270,6 +485,11 @@ func (p *Provider) AddResourceConfigurator(resource string, c ResourceConfigurat
 	p.resourceConfigurators[resource] = append(p.resourceConfigurators[resource], c)
 }
 
+// AddDataSourceConfigurator adds data source specific configurators.
+func (p *Provider) AddDataSourceConfigurator(dataSource string, c ResourceConfiguratorFn) { //nolint:interfacer
+	p.dataSourceConfigurators[dataSource] = append(p.dataSourceConfigurators[dataSource], c)
+}
+
 // SetResourceConfigurator sets ResourceConfigurator for a resource. This will
 // override all previously added ResourceConfigurators for this resource.
 func (p *Provider) SetResourceConfigurator(resource string, c ResourceConfigurator) {
@@ -284,25 +504,33 @@ func (p *Provider) ConfigureResources() {
 			c.Configure(r)
 		}
 	}
+	for name, c := range p.dataSourceConfigurators {
+		if r, ok := p.DataSources[name]; ok {
+			c.Configure(r)
+		}
+	}
 }
 
 // GetSkippedResourceNames returns a list of Terraform resource names
 // available in the Terraform provider schema, but
 // not in the include list or in the skip list, meaning that
 // the corresponding managed resources are not generated.
+// The resources of an additional schema whose names collide with the
+// resources added from the main schema or from a preceding additional schema
+// are also listed, as they are not generated from that schema.
 func (p *Provider) GetSkippedResourceNames() []string {
 	return p.skippedResourceNames
 }
 
-func matches(name string, regexList []string) bool {
+func matches(name string, regexList []string) (bool, error) {
 	for _, r := range regexList {
 		ok, err := regexp.MatchString(r, name)
 		if err != nil {
-			panic(errors.Wrap(err, "cannot match regular expression"))
+			return false, errors.Wrapf(err, "cannot match regular expression %q", r)
 		}
 		if ok {
-			return true
+			return true, nil
 		}
 	}
-	return false
+	return false, nil
 }
@@ -0,0 +1,192 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package config
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+const (
+	testSchemaAttributes = `{"version":0,"block":{"attributes":{"name":{"type":"string","required":true}}}}`
+
+	testProviderSchema = `{"format_version":"1.0","provider_schemas":{
+"registry.terraform.io/hashicorp/google":{"resource_schemas":{"google_a":` + testSchemaAttributes + `,"google_b":` + testSchemaAttributes + `},
+	"data_source_schemas":{"google_a":` + testSchemaAttributes + `,"google_c":` + testSchemaAttributes + `}},
+"registry.terraform.io/hashicorp/google-beta":{"resource_schemas":{"google_a":` + testSchemaAttributes + `,"google_b":` + testSchemaAttributes + `,"google_d":` + testSchemaAttributes + `}},
+"registry.terraform.io/hashicorp/random":{"resource_schemas":{"random_e":` + testSchemaAttributes + `}}}}`
+)
+
+func TestNewProvider(t *testing.T) {
+	type want struct {
+		resources   map[string]string
+		dataSources []string
+		skipped     []string
+	}
+	cases := map[string]struct {
+		reason string
+		opts   []ProviderOption
+		want   want
+	}{
+		"MultipleSchemas": {
+			reason: "Resources of the main schema should take precedence over the ones in the additional schemas.",
+			opts: []ProviderOption{
+				WithSkipList([]string{"google_b$"}),
+				WithAdditionalSchemas(AdditionalSchema{
+					Source:         "registry.terraform.io/hashicorp/google-beta",
+					Name:           "google-beta",
+					ResourcePrefix: "google_",
+				}, AdditionalSchema{
+					Source:         "registry.terraform.io/hashicorp/random",
+					Name:           "random",
+					ResourcePrefix: "random_",
+				}),
+			},
+			want: want{
+				resources: map[string]string{
+					"google_a": "",
+					"google_b": "google-beta",
+					"google_d": "google-beta",
+					"random_e": "random",
+				},
+				dataSources: []string{},
+				// google_b is skipped in the main schema and google_a
+				// collides with the resource of the main schema.
+				skipped: []string{"google_a", "google_b"},
+			},
+		},
+		"Collision": {
+			reason: "Resources of an additional schema colliding with the already added ones should be reported as skipped.",
+			opts: []ProviderOption{
+				WithAdditionalSchemas(AdditionalSchema{
+					Source:         "registry.terraform.io/hashicorp/google-beta",
+					Name:           "google-beta",
+					ResourcePrefix: "google_",
+				}, AdditionalSchema{
+					Source: "registry.terraform.io/hashicorp/random",
+					Name:   "random",
+				}),
+			},
+			want: want{
+				resources: map[string]string{
+					"google_a": "",
+					"google_b": "",
+					"google_d": "google-beta",
+					"random_e": "random",
+				},
+				dataSources: []string{},
+				skipped:     []string{"google_a", "google_b"},
+			},
+		},
+		"DataSources": {
+			reason: "Only the included data sources of the main schema should be generated.",
+			opts: []ProviderOption{
+				WithDataSourceIncludeList([]string{"google_a$"}),
+				WithAdditionalSchemas(AdditionalSchema{
+					Source:      "registry.terraform.io/hashicorp/google-beta",
+					Name:        "google-beta",
+					IncludeList: []string{"google_d$"},
+				}, AdditionalSchema{
+					Source: "registry.terraform.io/hashicorp/random",
+					Name:   "random",
+				}),
+			},
+			want: want{
+				resources: map[string]string{
+					"google_a": "",
+					"google_b": "",
+					"google_d": "google-beta",
+					"random_e": "random",
+				},
+				dataSources: []string{"google_a"},
+				skipped:     []string{"google_a", "google_b"},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := NewProvider([]byte(testProviderSchema), "google", "github.com/upbound/provider-test", nil, tc.opts...)
+			got := make(map[string]string, len(p.Resources))
+			for n, r := range p.Resources {
+				got[n] = r.TerraformProviderName
+			}
+			if diff := cmp.Diff(tc.want.resources, got); diff != "" {
+				t.Errorf("\n%s\nNewProvider(...): -want resources, +got resources:\n%s", tc.reason, diff)
+			}
+			gotDataSources := make([]string, 0, len(p.DataSources))
+			for n, r := range p.DataSources {
+				if !r.DataSource {
+					t.Errorf("\n%s\nNewProvider(...): data source %q is not marked as a data source", tc.reason, n)
+				}
+				gotDataSources = append(gotDataSources, n)
+			}
+			sort.Strings(gotDataSources)
+			if diff := cmp.Diff(tc.want.dataSources, gotDataSources); diff != "" {
+				t.Errorf("\n%s\nNewProvider(...): -want data sources, +got data sources:\n%s", tc.reason, diff)
+			}
+			gotSkipped := append([]string{}, p.GetSkippedResourceNames()...)
+			sort.Strings(gotSkipped)
+			if diff := cmp.Diff(tc.want.skipped, gotSkipped); diff != "" {
+				t.Errorf("\n%s\nNewProvider(...): -want skipped resources, +got skipped resources:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestNewProviderE(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		schema string
+		opts   []ProviderOption
+		want   error
+	}{
+		"MissingAdditionalSchema": {
+			reason: "An error should be returned if an additional schema does not exist.",
+			schema: testProviderSchema,
+			opts: []ProviderOption{
+				WithAdditionalSchemas(AdditionalSchema{Source: "registry.terraform.io/hashicorp/google-beta"}, AdditionalSchema{Source: "registry.terraform.io/hashicorp/missing"}),
+			},
+			want: errors.New(`cannot find the additional provider schema "registry.terraform.io/hashicorp/missing"`),
+		},
+		"DuplicateAdditionalSchema": {
+			reason: "An error should be returned if an additional schema is configured more than once.",
+			schema: testProviderSchema,
+			opts: []ProviderOption{
+				WithAdditionalSchemas(AdditionalSchema{Source: "registry.terraform.io/hashicorp/google-beta"}, AdditionalSchema{Source: "registry.terraform.io/hashicorp/google-beta"}),
+			},
+			want: errors.New(`the additional provider schema "registry.terraform.io/hashicorp/google-beta" is configured more than once`),
+		},
+		"SchemaCountMismatch": {
+			reason: "An error should be returned if the schemas other than the main one are not configured.",
+			schema: testProviderSchema,
+			want:   errors.New("there should exactly be 1 provider schema(s) but there are 3"),
+		},
+		"InvalidRegex": {
+			reason: "An error should be returned if an include list has an invalid regular expression.",
+			schema: testProviderSchema,
+			opts: []ProviderOption{
+				WithIncludeList([]string{"google_(a"}),
+			},
+			want: errors.Wrapf(errors.New("error parsing regexp: missing closing ): `google_(a`"), "cannot compile the regular expression %q in %s", "google_(a", "IncludeList"),
+		},
+		"UnsupportedNestingMode": {
+			reason: "An error should be returned if a resource schema cannot be converted.",
+			schema: `{"format_version":"1.0","provider_schemas":{"registry.terraform.io/hashicorp/google":{"resource_schemas":{"google_a":{"version":0,"block":{"block_types":{"settings":{"nesting_mode":"group","block":{}}}}}}}}}`,
+			want:   errors.Wrap(errors.Wrap(errors.Wrap(errors.New("unexpected nesting mode: group"), "cannot convert block settings"), "cannot convert the schema of google_a"), "cannot convert the resource schemas"),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewProviderE([]byte(tc.schema), "google", "github.com/upbound/provider-test", nil, tc.opts...)
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nNewProviderE(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -127,7 +127,9 @@ type Reference struct {
 	// TerraformName is the name of the Terraform resource
 	// which will be referenced. The supplied resource name is
 	// converted to a type name of the corresponding CRD using
-	// the configured TerraformTypeMapper.
+	// the configured TerraformTypeMapper. A generated Terraform data source
+	// can be referenced by prefixing its name with "data.", e.g.
+	// "data.aws_caller_identity".
 	TerraformName string
 	// Extractor is the function to be used to extract value from the
 	// referenced type. Defaults to getting external name.
@@ -319,4 +321,88 @@ type Resource struct {
 	// the plural name of the generated CRD. Overriding this sets both the
 	// path and the plural name for the generated CRD.
 	Path string
+
+	// TerraformProviderName is the local name of the Terraform provider this
+	// resource belongs to, as configured in Provider.AdditionalSchemas. It's
+	// empty for the resources of the main Terraform provider.
+	TerraformProviderName string
+
+	// ProviderArguments maps the Terraform field paths of the resource
+	// arguments to the paths of the Terraform provider configuration
+	// arguments they override, e.g. {"region": "region"}. When any of these
+	// arguments is set on a managed resource, its value is moved from the
+	// resource block to an aliased provider configuration that is the
+	// provider setup merged with the overrides. Fields that are not part of
+	// the Terraform resource schema, like the region for most AWS resources,
+	// need to be added to the schema in the resource configurator.
+	ProviderArguments map[string]string
+
+	// DataSource is set if this configuration belongs to a Terraform data
+	// source. The generated managed resource is observe-only, i.e., its
+	// controller refreshes a `data` block in the Terraform workspace and
+	// reports the results under `status.atProvider`.
+	DataSource bool
+
+	// PrinterColumns are the additional columns printed by kubectl for the
+	// managed resources, after the default READY, SYNCED and EXTERNAL-NAME
+	// columns.
+	PrinterColumns []PrinterColumn
+
+	// ShortNames are the short names of the generated CRD, e.g. "vpc".
+	ShortNames []string
+
+	// Categories are the categories of the generated CRD in addition to the
+	// default "crossplane", "managed" and the provider short name
+	// categories.
+	Categories []string
+
+	// Templates are the overrides of the code generation templates of this
+	// resource. They take precedence over the provider-level overrides in
+	// Provider.Templates.
+	Templates ResourceTemplates
+
+	// unknownFieldPaths are the field paths given to the schema manipulation
+	// methods of this resource that do not exist in its Terraform schema.
+	unknownFieldPaths []unknownFieldPath
+}
+
+// The types of the printer columns.
+const (
+	PrinterColumnTypeString  = "string"
+	PrinterColumnTypeInteger = "integer"
+	PrinterColumnTypeNumber  = "number"
+	PrinterColumnTypeBoolean = "boolean"
+	PrinterColumnTypeDate    = "date"
+)
+
+// PrinterColumn is an additional column printed by kubectl for the managed
+// resources.
+type PrinterColumn struct {
+	// Name is the name of the column, e.g. "INSTANCE-TYPE".
+	Name string
+	// FieldPath is the path of the printed field in the managed resource.
+	// It starts with spec.forProvider, spec.initProvider or
+	// status.atProvider, e.g. "status.atProvider.arn". The elements of the
+	// lists are addressed with an index, e.g.
+	// "spec.forProvider.rule[0].name". The path is validated against the
+	// generated CRD types.
+	FieldPath string
+	// Type is the type of the column. Defaults to the type of the printed
+	// field, which must then be a string, a number or a boolean.
+	Type string
+	// Priority is the priority of the column. The columns with a priority
+	// greater than zero are printed only in the wide output.
+	Priority int
+}
+
+// ResourceTemplates are the code generation templates that can be overridden
+// per resource. An empty template means that the provider-level override, or
+// the default template embedded in upjet if there is none, is used.
+type ResourceTemplates struct {
+	// CRDTypes is the template of the CRD types file. It's executed with
+	// pipeline.CRDTypesTemplateVars.
+	CRDTypes string
+	// Controller is the template of the controller file. It's executed with
+	// pipeline.ControllerTemplateVars.
+	Controller string
 }
@@ -0,0 +1,196 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	errFmtUnknownReferenceField  = "reference field %q does not exist in the Terraform schema"
+	errFmtUnknownReferenceTarget = "reference of field %q targets the unknown Terraform resource %q"
+	errFmtSkippedReferenceTarget = "reference of field %q targets the Terraform resource %q which is not generated"
+	errFmtUnknownIgnoredField    = "late-initialization ignored field %q does not exist in the Terraform schema"
+	errFmtUnknownOmittedField    = "external name omitted field %q does not exist in the Terraform schema"
+	errFmtUnknownIdentifierField = "external name template parameter %q does not exist in the Terraform schema"
+	errFmtUnknownSchemaFieldPath = "field path %q given to %s does not exist in the Terraform schema"
+	errFmtUnknownConfigurator    = "configurator is registered for the unknown Terraform %s"
+	errFmtInvalidPrinterColumn   = "printer column %q is invalid: %s"
+	errFmtInvalidShortName       = "short name %q is not a valid DNS-1123 label"
+	errFmtInvalidCategory        = "category %q is not a valid DNS-1123 label"
+)
+
+var (
+	printerColumnTypes = []string{PrinterColumnTypeString, PrinterColumnTypeInteger, PrinterColumnTypeNumber, PrinterColumnTypeBoolean, PrinterColumnTypeDate}
+	printerColumnRoots = []string{"spec.forProvider.", "spec.initProvider.", "status.atProvider."}
+)
+
+// ValidationError is an invalid configuration of a resource.
+type ValidationError struct {
+	// Resource is the Terraform name of the resource. Data sources are
+	// prefixed with DataSourceReferencePrefix.
+	Resource string
+	// Message describes the invalid configuration.
+	Message string
+}
+
+// ValidationErrors is the report of all the invalid resource configurations
+// of a provider.
+type ValidationErrors []ValidationError
+
+// Error returns the report with one invalid configuration per line.
+func (e ValidationErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, ve := range e {
+		lines[i] = fmt.Sprintf("%s: %s", ve.Resource, ve.Message)
+	}
+	return fmt.Sprintf("%d invalid resource configuration(s):\n%s", len(e), strings.Join(lines, "\n"))
+}
+
+// Validate checks the configurations of all the resources and data sources of
+// the provider and returns a ValidationErrors report of the field paths that
+// do not exist in their Terraform schemas, the references to the unknown or
+// skipped Terraform resources and the configurators registered for the
+// unknown Terraform resources. It should be called after ConfigureResources
+// and before the code generation pipeline mutates the schemas.
+func (p *Provider) Validate() error {
+	var errs ValidationErrors
+	for name, r := range p.Resources {
+		errs = append(errs, p.validateResource(name, r)...)
+	}
+	for name, r := range p.DataSources {
+		errs = append(errs, p.validateResource(DataSourceReferencePrefix+name, r)...)
+	}
+	errs = append(errs, validateConfigurators("resource", "", p.resourceConfigurators, p.Resources, p.skippedResourceNames)...)
+	errs = append(errs, validateConfigurators("data source", DataSourceReferencePrefix, p.dataSourceConfigurators, p.DataSources, p.skippedDataSourceNames)...)
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Resource != errs[j].Resource {
+			return errs[i].Resource < errs[j].Resource
+		}
+		return errs[i].Message < errs[j].Message
+	})
+	return errs
+}
+
+func (p *Provider) validateResource(name string, r *Resource) ValidationErrors { //nolint:gocyclo // a flat list of checks
+	var errs ValidationErrors
+	add := func(format string, args ...any) {
+		errs = append(errs, ValidationError{Resource: name, Message: fmt.Sprintf(format, args...)})
+	}
+	if r.TerraformResource == nil {
+		return nil
+	}
+	for f, ref := range r.References {
+		if GetSchema(r.TerraformResource, f) == nil {
+			add(errFmtUnknownReferenceField, f)
+		}
+		if ref.TerraformName == "" {
+			continue
+		}
+		switch {
+		case p.Resources[ref.TerraformName] != nil:
+		case strings.HasPrefix(ref.TerraformName, DataSourceReferencePrefix) && p.DataSources[strings.TrimPrefix(ref.TerraformName, DataSourceReferencePrefix)] != nil:
+		case contains(p.skippedResourceNames, ref.TerraformName),
+			strings.HasPrefix(ref.TerraformName, DataSourceReferencePrefix) && contains(p.skippedDataSourceNames, strings.TrimPrefix(ref.TerraformName, DataSourceReferencePrefix)):
+			add(errFmtSkippedReferenceTarget, f, ref.TerraformName)
+		default:
+			add(errFmtUnknownReferenceTarget, f, ref.TerraformName)
+		}
+	}
+	for _, f := range r.LateInitializer.IgnoredFields {
+		if GetSchema(r.TerraformResource, f) == nil {
+			add(errFmtUnknownIgnoredField, f)
+		}
+	}
+	for _, f := range r.ExternalName.OmittedFields {
+		// Missing top-level fields are safe to omit, e.g. "name_prefix" of
+		// NameAsIdentifier, but the parents of a nested field must exist.
+		if i := strings.LastIndex(f, "."); i != -1 && !isBlock(GetSchema(r.TerraformResource, f[:i])) {
+			add(errFmtUnknownOmittedField, f)
+		}
+	}
+	for _, f := range r.ExternalName.IdentifierFields {
+		if GetSchema(r.TerraformResource, f) == nil {
+			add(errFmtUnknownIdentifierField, f)
+		}
+	}
+	for _, f := range r.unknownFieldPaths {
+		add(errFmtUnknownSchemaFieldPath, f.path, f.fn)
+	}
+	for _, c := range r.PrinterColumns {
+		if msg := validatePrinterColumn(c); msg != "" {
+			add(errFmtInvalidPrinterColumn, c.Name, msg)
+		}
+	}
+	// short names and categories are validated by the Kubernetes API server
+	// as DNS-1123 labels.
+	for _, n := range r.ShortNames {
+		if len(validation.IsDNS1123Label(n)) > 0 {
+			add(errFmtInvalidShortName, n)
+		}
+	}
+	for _, c := range r.Categories {
+		if len(validation.IsDNS1123Label(c)) > 0 {
+			add(errFmtInvalidCategory, c)
+		}
+	}
+	return errs
+}
+
+// validatePrinterColumn returns the reason why the given printer column is
+// invalid, or an empty string if it's valid. Whether the field path exists
+// is checked while the CRD types are generated.
+func validatePrinterColumn(c PrinterColumn) string {
+	switch {
+	case c.Name == "":
+		return "name is empty"
+	case c.Type != "" && !contains(printerColumnTypes, c.Type):
+		return fmt.Sprintf("type %q is not one of %s", c.Type, strings.Join(printerColumnTypes, ", "))
+	case c.Priority < 0:
+		return "priority is negative"
+	}
+	for _, root := range printerColumnRoots {
+		if strings.HasPrefix(c.FieldPath, root) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("field path %q does not start with one of %s", c.FieldPath, strings.Join(printerColumnRoots, ", "))
+}
+
+func validateConfigurators(kind, prefix string, configurators map[string]ResourceConfiguratorChain, generated map[string]*Resource, skipped []string) ValidationErrors {
+	var errs ValidationErrors
+	for name := range configurators {
+		if generated[name] != nil || contains(skipped, name) {
+			continue
+		}
+		errs = append(errs, ValidationError{Resource: prefix + name, Message: fmt.Sprintf(errFmtUnknownConfigurator, kind)})
+	}
+	return errs
+}
+
+func isBlock(s *schema.Schema) bool {
+	if s == nil {
+		return false
+	}
+	_, ok := s.Elem.(*schema.Resource)
+	return ok
+}
+
+func contains(l []string, s string) bool {
+	for _, e := range l {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package config
+
+import (
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValidate(t *testing.T) {
+	cases := map[string]struct {
+		reason        string
+		configurators map[string]ResourceConfiguratorFn
+		want          error
+	}{
+		"Valid": {
+			reason: "No error should be reported for a valid configuration.",
+			configurators: map[string]ResourceConfiguratorFn{
+				"google_a": func(r *Resource) {
+					r.References["name"] = Reference{TerraformName: "data.google_a"}
+					r.LateInitializer.IgnoredFields = []string{"name"}
+					r.MarkAsRequired("name")
+					r.PrinterColumns = []PrinterColumn{{Name: "NAME", FieldPath: "spec.forProvider.name", Type: PrinterColumnTypeString}}
+					r.ShortNames = []string{"ga"}
+					r.Categories = []string{"google", "managed-google"}
+				},
+				// Configurators of the skipped resources are fine.
+				"google_b": func(r *Resource) {},
+			},
+		},
+		"Invalid": {
+			reason: "All the invalid configurations should be reported together.",
+			configurators: map[string]ResourceConfiguratorFn{
+				"google_a": func(r *Resource) {
+					r.References["nmae"] = Reference{TerraformName: "google_b"}
+					r.References["name"] = Reference{TerraformName: "google_x"}
+					r.LateInitializer.IgnoredFields = []string{"missing"}
+					r.ExternalName = TemplatedStringAsIdentifier("", "{{ .parameters.project }}/{{ .external_name }}")
+					r.ExternalName.OmittedFields = []string{"block.field"}
+					r.MoveToStatus("status")
+					r.PrinterColumns = []PrinterColumn{{Name: "NAME", FieldPath: "name"}}
+					r.ShortNames = []string{"Ga"}
+					r.Categories = []string{"my_google"}
+				},
+				"google_y": func(r *Resource) {},
+			},
+			want: ValidationErrors{
+				{Resource: "google_a", Message: `category "my_google" is not a valid DNS-1123 label`},
+				{Resource: "google_a", Message: `external name omitted field "block.field" does not exist in the Terraform schema`},
+				{Resource: "google_a", Message: `external name template parameter "project" does not exist in the Terraform schema`},
+				{Resource: "google_a", Message: `field path "status" given to MoveToStatus does not exist in the Terraform schema`},
+				{Resource: "google_a", Message: `late-initialization ignored field "missing" does not exist in the Terraform schema`},
+				{Resource: "google_a", Message: `printer column "NAME" is invalid: field path "name" does not start with one of spec.forProvider., spec.initProvider., status.atProvider.`},
+				{Resource: "google_a", Message: `reference field "nmae" does not exist in the Terraform schema`},
+				{Resource: "google_a", Message: `reference of field "name" targets the unknown Terraform resource "google_x"`},
+				{Resource: "google_a", Message: `reference of field "nmae" targets the Terraform resource "google_b" which is not generated`},
+				{Resource: "google_a", Message: `short name "Ga" is not a valid DNS-1123 label`},
+				{Resource: "google_y", Message: `configurator is registered for the unknown Terraform resource`},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := NewProvider([]byte(testProviderSchema), "google", "github.com/upbound/provider-test", nil,
+				WithIncludeList([]string{"google_a$"}),
+				WithDataSourceIncludeList([]string{"google_a$"}),
+				WithAdditionalSchemas(AdditionalSchema{Source: "registry.terraform.io/hashicorp/google-beta", Name: "google-beta", SkipList: []string{".+"}},
+					AdditionalSchema{Source: "registry.terraform.io/hashicorp/random", Name: "random", SkipList: []string{".+"}}))
+			for n, c := range tc.configurators {
+				p.AddResourceConfigurator(n, c)
+			}
+			p.ConfigureResources()
+			if diff := cmp.Diff(tc.want, p.Validate(), test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nValidate(): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
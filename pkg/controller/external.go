@@ -12,6 +12,7 @@ import (
 
 	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
 	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
 	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
 	xpresource "github.com/crossplane/crossplane-runtime/pkg/resource"
 	"github.com/pkg/errors"
@@ -39,6 +40,7 @@ const (
 	errDestroy           = "cannot destroy"
 	errScheduleProvider  = "cannot schedule native Terraform provider process, please consider increasing its TTL with the --provider-ttl command-line option"
 	errUpdateAnnotations = "cannot update managed resource annotations"
+	errDataSourceEmpty   = "data source has not returned any results"
 )
 
 const (
@@ -196,6 +198,12 @@ func (e *external) Observe(ctx context.Context, mg xpresource.Managed) (managed.
 		return managed.ExternalObservation{}, errors.New(errUnexpectedObject)
 	}
 
+	// Data sources are only read, so we observe them regardless of the
+	// configured management policies.
+	if e.config.DataSource {
+		return e.observeDataSource(ctx, tr)
+	}
+
 	policySet := sets.New[xpv1.ManagementAction](tr.GetManagementPolicies()...)
 
 	// Note(turkenh): We don't need to check if the management policies are
@@ -347,12 +355,61 @@ func (e *external) Observe(ctx context.Context, mg xpresource.Managed) (managed.
 	}
 }
 
+func (e *external) observeDataSource(ctx context.Context, tr resource.Terraformed) (managed.ExternalObservation, error) {
+	// A deleted data source is reported as non-existent, so that its
+	// finalizer is removed, as there is nothing to delete.
+	if meta.WasDeleted(tr) {
+		return managed.ExternalObservation{ResourceExists: false}, nil
+	}
+	res, err := e.workspace.Refresh(ctx)
+	if err != nil {
+		return managed.ExternalObservation{}, errors.Wrap(err, errRefresh)
+	}
+	// A data source that cannot be read is reported as an error instead of a
+	// non-existent resource, because we never create data sources.
+	if !res.Exists {
+		return managed.ExternalObservation{}, errors.New(errDataSourceEmpty)
+	}
+	tfstate := map[string]any{}
+	if err := json.JSParser.Unmarshal(res.State.GetAttributes(), &tfstate); err != nil {
+		return managed.ExternalObservation{}, errors.Wrap(err, "cannot unmarshal state attributes")
+	}
+	if err := tr.SetObservation(tfstate); err != nil {
+		return managed.ExternalObservation{}, errors.Wrap(err, "cannot set observation")
+	}
+	annotationsUpdated, err := resource.SetCriticalAnnotations(tr, e.config, tfstate, string(res.State.GetPrivateRaw()))
+	if err != nil {
+		return managed.ExternalObservation{}, errors.Wrap(err, "cannot set critical annotations")
+	}
+	if annotationsUpdated {
+		if err := e.kube.Update(ctx, tr); err != nil {
+			return managed.ExternalObservation{}, errors.Wrap(err, errUpdateAnnotations)
+		}
+	}
+	conn, err := resource.GetConnectionDetails(tfstate, tr, e.config)
+	if err != nil {
+		return managed.ExternalObservation{}, errors.Wrap(err, "cannot get connection details")
+	}
+	if !tr.GetCondition(xpv1.TypeReady).Equal(xpv1.Available()) {
+		addTTR(tr)
+		tr.SetConditions(xpv1.Available())
+	}
+	return managed.ExternalObservation{
+		ResourceExists:    true,
+		ResourceUpToDate:  true,
+		ConnectionDetails: conn,
+	}, nil
+}
+
 func addTTR(mg xpresource.Managed) {
 	gvk := mg.GetObjectKind().GroupVersionKind()
 	metrics.TTRMeasurements.WithLabelValues(gvk.Group, gvk.Version, gvk.Kind).Observe(time.Since(mg.GetCreationTimestamp().Time).Seconds())
 }
 
 func (e *external) Create(ctx context.Context, mg xpresource.Managed) (managed.ExternalCreation, error) {
+	if e.config.DataSource {
+		return managed.ExternalCreation{}, nil
+	}
 	requeued, err := e.scheduleProvider(mg.GetName())
 	if err != nil {
 		return managed.ExternalCreation{}, errors.Wrapf(err, "cannot schedule a native provider during create: %s", mg.GetUID())
@@ -388,6 +445,9 @@ func (e *external) Create(ctx context.Context, mg xpresource.Managed) (managed.E
 }
 
 func (e *external) Update(ctx context.Context, mg xpresource.Managed) (managed.ExternalUpdate, error) {
+	if e.config.DataSource {
+		return managed.ExternalUpdate{}, nil
+	}
 	requeued, err := e.scheduleProvider(mg.GetName())
 	if err != nil {
 		return managed.ExternalUpdate{}, errors.Wrapf(err, "cannot schedule a native provider during update: %s", mg.GetUID())
@@ -415,6 +475,11 @@ func (e *external) Update(ctx context.Context, mg xpresource.Managed) (managed.E
 }
 
 func (e *external) Delete(ctx context.Context, mg xpresource.Managed) error {
+	// There is no external resource to delete for a data source. Its
+	// workspace is removed by the workspace finalizer.
+	if e.config.DataSource {
+		return nil
+	}
 	requeued, err := e.scheduleProvider(mg.GetName())
 	if err != nil {
 		return errors.Wrapf(err, "cannot schedule a native provider during delete: %s", mg.GetUID())
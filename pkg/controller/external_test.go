@@ -17,6 +17,7 @@ package controller
 import (
 	"context"
 	"testing"
+	"time"
 
 	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
 	"github.com/crossplane/crossplane-runtime/pkg/logging"
@@ -619,6 +620,101 @@ func TestObserve(t *testing.T) {
 	}
 }
 
+func TestObserveDataSource(t *testing.T) {
+	type args struct {
+		w   Workspace
+		obj xpresource.Managed
+	}
+	type want struct {
+		obs       managed.ExternalObservation
+		condition *xpv1.Condition
+		err       error
+	}
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"RefreshEmpty": {
+			reason: "It should report an error if the data source has not returned any results",
+			args: args{
+				obj: &fake.Terraformed{},
+				w: WorkspaceFns{
+					RefreshFn: func(_ context.Context) (terraform.RefreshResult, error) {
+						return terraform.RefreshResult{Exists: false}, nil
+					},
+				},
+			},
+			want: want{
+				err: errors.New(errDataSourceEmpty),
+			},
+		},
+		"Deleted": {
+			reason: "It should report a deleted data source as non-existent without reading it, so that it can be deleted",
+			args: args{
+				obj: &fake.Terraformed{
+					Managed: xpfake.Managed{
+						ObjectMeta: metav1.ObjectMeta{
+							DeletionTimestamp: &metav1.Time{Time: time.Now()},
+						},
+					},
+				},
+				w: WorkspaceFns{},
+			},
+			want: want{
+				obs: managed.ExternalObservation{ResourceExists: false},
+			},
+		},
+		"Success": {
+			reason: "It should report the data source as up-to-date and available regardless of the management policies",
+			args: args{
+				obj: &fake.Terraformed{
+					Managed: xpfake.Managed{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: exampleCriticalAnnotations,
+						},
+						Manageable: xpfake.Manageable{
+							Policy: xpv1.ManagementPolicies{xpv1.ManagementActionObserve},
+						},
+					},
+				},
+				w: WorkspaceFns{
+					RefreshFn: func(_ context.Context) (terraform.RefreshResult, error) {
+						return terraform.RefreshResult{
+							Exists: true,
+							State:  exampleState,
+						}, nil
+					},
+				},
+			},
+			want: want{
+				obs: managed.ExternalObservation{
+					ResourceExists:   true,
+					ResourceUpToDate: true,
+				},
+				condition: available(),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := &external{workspace: tc.w, config: config.DefaultDataSource("upjet_data", nil), logger: logging.NewNopLogger()}
+			observation, err := e.Observe(context.TODO(), tc.args.obj)
+			if diff := cmp.Diff(tc.want.obs, observation); diff != "" {
+				t.Errorf("\n%s\nObserve(...): -want observation, +got observation:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nObserve(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if tc.want.condition != nil {
+				if diff := cmp.Diff(*tc.want.condition, tc.args.obj.GetCondition(tc.want.condition.Type), cmpopts.IgnoreTypes(metav1.Time{})); diff != "" {
+					t.Errorf("\n%s\nObserve(...): -want condition, +got condition:\n%s", tc.reason, diff)
+				}
+			}
+		})
+	}
+}
+
 func available() *xpv1.Condition {
 	c := xpv1.Available()
 	return &c
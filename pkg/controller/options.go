@@ -12,6 +12,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
 	"github.com/upbound/upjet/pkg/config"
+	"github.com/upbound/upjet/pkg/controller/shard"
 	"github.com/upbound/upjet/pkg/terraform"
 )
 
@@ -43,6 +44,12 @@ type Options struct {
 	// PollJitter adds the specified jitter to the configured reconcile period
 	// of the up-to-date resources in managed.Reconciler.
 	PollJitter time.Duration
+
+	// Sharder distributes the managed resources across the replicas of the
+	// provider. If set, the controllers reconcile only the managed resources
+	// of the shard of the replica. If not set, all the managed resources are
+	// reconciled.
+	Sharder *shard.Sharder
 }
 
 // ESSOptions for External Secret Stores.
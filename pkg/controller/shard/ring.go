@@ -0,0 +1,77 @@
+// Copyright 2023 Upbound Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shard
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// ring is a consistent hash ring mapping the keys to the members, which are
+// placed on the ring with a number of virtual nodes each, so that only the
+// keys of a joining or a leaving member are moved.
+type ring struct {
+	members []string
+	hashes  []uint32
+	owners  map[uint32]string
+}
+
+func newRing(members []string, virtualNodes int) *ring {
+	r := &ring{
+		members: members,
+		hashes:  make([]uint32, 0, len(members)*virtualNodes),
+		owners:  make(map[uint32]string, len(members)*virtualNodes),
+	}
+	for _, m := range members {
+		for i := 0; i < virtualNodes; i++ {
+			h := hash(fmt.Sprintf("%s#%d", m, i))
+			// in the unlikely case of a collision, the smaller member name
+			// wins, so that all the replicas agree on the owner.
+			if o, ok := r.owners[h]; ok && o < m {
+				continue
+			} else if !ok {
+				r.hashes = append(r.hashes, h)
+			}
+			r.owners[h] = m
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool {
+		return r.hashes[i] < r.hashes[j]
+	})
+	return r
+}
+
+// owner returns the member owning the given key, or an empty string if the
+// ring has no members.
+func (r *ring) owner(key string) string {
+	if r == nil || len(r.hashes) == 0 {
+		return ""
+	}
+	h := hash(key)
+	i := sort.Search(len(r.hashes), func(i int) bool {
+		return r.hashes[i] >= h
+	})
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.owners[r.hashes[i]]
+}
+
+func hash(s string) uint32 {
+	sum := sha256.Sum256([]byte(s))
+	return binary.BigEndian.Uint32(sum[:4])
+}
@@ -0,0 +1,579 @@
+// Copyright 2023 Upbound Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package shard distributes the managed resources across the replicas of a
+// provider, so that each replica reconciles a deterministic subset of them.
+package shard
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/pkg/errors"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// LabelKeyShard is the label of the managed resources, whose value is
+	// hashed instead of their UIDs to pick their shards. The managed
+	// resources with the same label value are reconciled by the same
+	// replica.
+	LabelKeyShard = "upjet.upbound.io/shard"
+	// LabelKeyShardGroup is the label of the Leases of the replicas sharing
+	// the managed resources.
+	LabelKeyShardGroup = "upjet.upbound.io/shard-group"
+	// AnnotationKeyDraining is the annotation of the Lease of a replica that
+	// is still reconciling, or running the asynchronous Terraform operations
+	// of, the managed resources it has released in a rebalance. The other
+	// replicas do not acquire any managed resources until it's removed.
+	AnnotationKeyDraining = "upjet.upbound.io/shard-draining"
+
+	defaultGroup         = "upjet"
+	defaultLeaseDuration = 30 * time.Second
+	defaultVirtualNodes  = 100
+	// rebalanceRetryInterval is the interval of the attempts to list the
+	// managed resources acquired after a rebalance.
+	rebalanceRetryInterval = time.Second
+	eventBufferSize        = 1024
+
+	errGetLease    = "cannot get the Lease of the replica"
+	errCreateLease = "cannot create the Lease of the replica"
+	errUpdateLease = "cannot update the Lease of the replica"
+	errListLeases  = "cannot list the Leases of the replicas"
+	errDeleteLease = "cannot delete the Lease of the replica"
+	errListObjects = "cannot list the managed resources to rebalance"
+)
+
+// Option configures a Sharder.
+type Option func(*Sharder)
+
+// WithLogger configures the logger of the Sharder.
+func WithLogger(l logging.Logger) Option {
+	return func(s *Sharder) {
+		s.logger = l
+	}
+}
+
+// WithGroup configures the name of the group of the replicas sharing the
+// managed resources, which must be unique for each provider in the
+// namespace. Defaults to "upjet".
+func WithGroup(g string) Option {
+	return func(s *Sharder) {
+		s.group = g
+	}
+}
+
+// WithLeaseDuration configures the duration after which a replica that has
+// not renewed its Lease is considered to have left, and its managed
+// resources are distributed across the other replicas. The Leases are
+// renewed at a third of this duration. Defaults to 30 seconds.
+func WithLeaseDuration(d time.Duration) Option {
+	return func(s *Sharder) {
+		s.leaseDuration = d
+	}
+}
+
+// WithOperationTracker configures the tracker of the asynchronous operations,
+// e.g., the Terraform applies and destroys, running in the replica. A
+// replica releasing a managed resource in a rebalance waits for its running
+// asynchronous operation to complete before the managed resource is
+// acquired by another replica. Without a tracker, only the ongoing
+// reconciliations are waited for.
+func WithOperationTracker(t OperationTracker) Option {
+	return func(s *Sharder) {
+		s.tracker = t
+	}
+}
+
+// WithVirtualNodes configures the number of the virtual nodes of each
+// replica on the consistent hash ring. The more virtual nodes, the more
+// evenly the managed resources are distributed. Defaults to 100.
+func WithVirtualNodes(n int) Option {
+	return func(s *Sharder) {
+		s.virtualNodes = n
+	}
+}
+
+// OperationTracker reports the asynchronous operations running in a replica.
+type OperationTracker interface {
+	// RunningOperations returns the UIDs of the managed resources whose
+	// asynchronous operations are running.
+	RunningOperations() []types.UID
+}
+
+// Sharder distributes the managed resources across the replicas of a
+// provider using consistent hashing on their UIDs or on their shard labels.
+// The replicas maintain their memberships with a Lease each, and the managed
+// resources are rebalanced when a replica joins or leaves. A Sharder must be
+// added to the controller manager to maintain the membership, and the
+// controller manager must not use leader election, as all the replicas
+// reconcile their own shards.
+//
+// The managed resources are handed off between the replicas, so that a
+// managed resource is not reconciled by two replicas at the same time. A
+// replica stops reconciling the managed resources it releases as soon as it
+// observes a rebalance, and all of its managed resources if it cannot
+// synchronize its membership for a lease duration. A replica starts
+// reconciling the managed resources it acquires only once a lease duration
+// has passed since it observed the rebalance, and none of the other replicas
+// is still reconciling, or running the tracked asynchronous operations of,
+// the managed resources it has released. The guarantee assumes that the
+// clock skew between the replicas is small compared to the lease duration,
+// and it does not hold for the asynchronous operations of a replica whose
+// Lease expires while they are running.
+type Sharder struct {
+	client        client.Client
+	namespace     string
+	identity      string
+	group         string
+	leaseDuration time.Duration
+	virtualNodes  int
+	logger        logging.Logger
+	clock         clock.WithTicker
+	tracker       OperationTracker
+
+	mu   sync.RWMutex
+	ring *ring
+	// held are the rings replaced since the last completed handoff. A key
+	// is owned only if it's owned with the current ring and all the held
+	// rings, i.e., the keys acquired since the last handoff are not owned
+	// until the next one completes.
+	held []*ring
+	// changedAt is when the current ring was observed and syncedAt is when
+	// the membership was last synchronized.
+	changedAt time.Time
+	syncedAt  time.Time
+	// inflight is the number of the ongoing reconciliations per key, and
+	// keys are the keys of the reconciled managed resources by their UIDs.
+	inflight    map[string]int
+	keys        map[types.UID]string
+	ctx         context.Context
+	subscribers []*subscriber
+}
+
+type subscriber struct {
+	reader client.Reader
+	list   client.ObjectList
+	events chan event.GenericEvent
+}
+
+// NewSharder returns a new Sharder for the replica with the given identity,
+// e.g., its pod name, which keeps its Lease in the given namespace using the
+// given client. As the Leases of all the replicas are listed periodically,
+// the client should not read from the cache of the controller manager.
+func NewSharder(c client.Client, namespace, identity string, opts ...Option) *Sharder {
+	s := &Sharder{
+		client:        c,
+		namespace:     namespace,
+		identity:      identity,
+		group:         defaultGroup,
+		leaseDuration: defaultLeaseDuration,
+		virtualNodes:  defaultVirtualNodes,
+		logger:        logging.NewNopLogger(),
+		clock:         clock.RealClock{},
+		inflight:      make(map[string]int),
+		keys:          make(map[types.UID]string),
+		ctx:           context.Background(),
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+// Start maintains the membership of the replica until the given context is
+// done, when the replica leaves the group.
+func (s *Sharder) Start(ctx context.Context) error {
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+	t := s.clock.NewTicker(s.leaseDuration / 3)
+	defer t.Stop()
+	for {
+		if err := s.sync(ctx); err != nil {
+			s.logger.Info("Cannot synchronize the shard membership", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			// the Lease is deleted with a new context as the given one is
+			// already done.
+			lctx, cancel := context.WithTimeout(context.Background(), s.leaseDuration/3)
+			defer cancel()
+			return errors.Wrap(s.leave(lctx), "cannot leave the shard group")
+		case <-t.C():
+		}
+	}
+}
+
+// NeedLeaderElection returns false as all the replicas maintain their own
+// memberships.
+func (s *Sharder) NeedLeaderElection() bool {
+	return false
+}
+
+// Owns returns whether the given object is in the shard of the replica. No
+// object is owned until the replica joins the group and a lease duration has
+// passed, and the objects acquired in a rebalance are not owned until they
+// are handed off.
+func (s *Sharder) Owns(obj client.Object) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.owns(key(obj))
+}
+
+// owns returns whether the given key is in the shard of the replica. The
+// caller must hold the lock.
+func (s *Sharder) owns(k string) bool {
+	if s.ring.owner(k) != s.identity || s.clock.Since(s.syncedAt) > s.leaseDuration {
+		return false
+	}
+	return ownedBy(s.held, k, s.identity)
+}
+
+// ownedBy returns whether the given key is owned by the given member with
+// all of the given rings.
+func ownedBy(rings []*ring, k, member string) bool {
+	for _, r := range rings {
+		if r.owner(k) != member {
+			return false
+		}
+	}
+	return true
+}
+
+// Predicate returns an event filter skipping the managed resources of the
+// other shards.
+func (s *Sharder) Predicate() predicate.Predicate {
+	return predicate.NewPredicateFuncs(s.Owns)
+}
+
+// Source returns an event source for the managed resources listed with the
+// given reader and list, which triggers the reconciliation of the managed
+// resources moved to the shard of the replica when the replicas are
+// rebalanced.
+func (s *Sharder) Source(r client.Reader, list client.ObjectList) source.Source {
+	sub := &subscriber{
+		reader: r,
+		list:   list,
+		events: make(chan event.GenericEvent, eventBufferSize),
+	}
+	s.mu.Lock()
+	s.subscribers = append(s.subscribers, sub)
+	s.mu.Unlock()
+	return &source.Channel{Source: sub.events}
+}
+
+// Reconciler wraps the given reconciler, so that the managed resources of
+// the other shards, e.g., the ones that have been requeued before a
+// rebalance, are not reconciled. The given object is a prototype of the
+// managed resources read with the given reader. The given reconciler is
+// returned as is if the Sharder is nil.
+func (s *Sharder) Reconciler(c client.Reader, obj client.Object, r reconcile.Reconciler) reconcile.Reconciler {
+	if s == nil {
+		return r
+	}
+	return reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		o := obj.DeepCopyObject().(client.Object)
+		// the managed resources that cannot be read are left to the
+		// reconciler.
+		if err := c.Get(ctx, req.NamespacedName, o); err != nil {
+			return r.Reconcile(ctx, req)
+		}
+		k, ok := s.begin(o)
+		if !ok {
+			return reconcile.Result{}, nil
+		}
+		defer s.end(k)
+		return r.Reconcile(ctx, req)
+	})
+}
+
+// begin records the start of the reconciliation of the given object if it's
+// owned by the replica. It returns the key of the object and whether it's
+// owned.
+func (s *Sharder) begin(obj client.Object) (string, bool) {
+	k := key(obj)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.owns(k) {
+		return k, false
+	}
+	s.inflight[k]++
+	s.keys[obj.GetUID()] = k
+	return k, true
+}
+
+// end records the end of a reconciliation of the given key.
+func (s *Sharder) end(k string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inflight[k]--; s.inflight[k] <= 0 {
+		delete(s.inflight, k)
+	}
+}
+
+// draining returns whether the replica is still reconciling, or running the
+// asynchronous operations of, the managed resources that it does not own.
+// The operations of the managed resources that have not been reconciled by
+// the replica are assumed to be of the released ones.
+func (s *Sharder) draining() bool {
+	var running []types.UID
+	if s.tracker != nil {
+		running = s.tracker.RunningOperations()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := false
+	for k := range s.inflight {
+		if !s.owns(k) {
+			result = true
+		}
+	}
+	isRunning := make(map[types.UID]bool, len(running))
+	for _, uid := range running {
+		isRunning[uid] = true
+		if k, ok := s.keys[uid]; !ok || !s.owns(k) {
+			result = true
+		}
+	}
+	// the keys of the managed resources that are neither being reconciled
+	// nor running an operation are not needed anymore.
+	for uid, k := range s.keys {
+		if !isRunning[uid] && s.inflight[k] == 0 {
+			delete(s.keys, uid)
+		}
+	}
+	return result
+}
+
+// Members returns the identities of the replicas in the group.
+func (s *Sharder) Members() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.ring == nil {
+		return nil
+	}
+	return append([]string(nil), s.ring.members...)
+}
+
+// sync updates the members of the group with the replicas whose Leases have
+// not expired, renews the Lease of the replica and completes the pending
+// handoff if possible.
+func (s *Sharder) sync(ctx context.Context) error {
+	now := s.clock.Now()
+	l := &coordinationv1.LeaseList{}
+	if err := s.client.List(ctx, l, client.InNamespace(s.namespace), client.MatchingLabels{LabelKeyShardGroup: s.group}); err != nil {
+		return errors.Wrap(err, errListLeases)
+	}
+	members := []string{s.identity}
+	othersDraining := false
+	for _, lease := range l.Items {
+		id := pointer.StringDeref(lease.Spec.HolderIdentity, "")
+		if id == "" || id == s.identity || lease.Spec.RenewTime == nil {
+			continue
+		}
+		d := time.Duration(pointer.Int32Deref(lease.Spec.LeaseDurationSeconds, 0)) * time.Second
+		if lease.Spec.RenewTime.Add(d).After(now) {
+			members = append(members, id)
+			othersDraining = othersDraining || lease.Annotations[AnnotationKeyDraining] == "true"
+		}
+	}
+	s.setMembers(members)
+	// the released managed resources are not owned anymore, so the draining
+	// status reflects the new members.
+	if err := s.renew(ctx, s.draining()); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.syncedAt = now
+	s.handoff(now, othersDraining)
+	return nil
+}
+
+func (s *Sharder) leaseName() string {
+	return s.group + "-" + s.identity
+}
+
+func (s *Sharder) renew(ctx context.Context, draining bool) error {
+	now := metav1.NewMicroTime(s.clock.Now())
+	spec := coordinationv1.LeaseSpec{
+		HolderIdentity:       pointer.String(s.identity),
+		LeaseDurationSeconds: pointer.Int32(int32(s.leaseDuration.Seconds())),
+		RenewTime:            &now,
+	}
+	lease := &coordinationv1.Lease{}
+	err := s.client.Get(ctx, types.NamespacedName{Namespace: s.namespace, Name: s.leaseName()}, lease)
+	switch {
+	case kerrors.IsNotFound(err):
+		spec.AcquireTime = &now
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: s.namespace,
+				Name:      s.leaseName(),
+				Labels:    map[string]string{LabelKeyShardGroup: s.group},
+			},
+			Spec: spec,
+		}
+		setDraining(lease, draining)
+		return errors.Wrap(s.client.Create(ctx, lease), errCreateLease)
+	case err != nil:
+		return errors.Wrap(err, errGetLease)
+	}
+	spec.AcquireTime = lease.Spec.AcquireTime
+	lease.Spec = spec
+	setDraining(lease, draining)
+	return errors.Wrap(s.client.Update(ctx, lease), errUpdateLease)
+}
+
+func setDraining(lease *coordinationv1.Lease, draining bool) {
+	if !draining {
+		delete(lease.Annotations, AnnotationKeyDraining)
+		return
+	}
+	if lease.Annotations == nil {
+		lease.Annotations = map[string]string{}
+	}
+	lease.Annotations[AnnotationKeyDraining] = "true"
+}
+
+func (s *Sharder) leave(ctx context.Context) error {
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: s.namespace,
+			Name:      s.leaseName(),
+		},
+	}
+	return errors.Wrap(client.IgnoreNotFound(s.client.Delete(ctx, lease)), errDeleteLease)
+}
+
+// setMembers rebuilds the ring if the members of the group have changed. The
+// managed resources released by the replica are not owned anymore, and the
+// ones acquired are not owned until the handoff completes.
+func (s *Sharder) setMembers(members []string) {
+	sort.Strings(members)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ring != nil && equal(s.ring.members, members) {
+		return
+	}
+	s.logger.Info("Rebalancing the managed resources across the replicas", "members", members)
+	s.held = append(s.held, s.ring)
+	s.ring = newRing(members, s.virtualNodes)
+	s.changedAt = s.clock.Now()
+}
+
+// handoff completes the pending handoff, i.e., the replica starts owning the
+// managed resources acquired since the last one, if a lease duration has
+// passed since the ring has changed and the other replicas are not draining
+// anymore, and notifies the subscribers about them. The caller must hold the
+// lock.
+func (s *Sharder) handoff(now time.Time, othersDraining bool) {
+	if len(s.held) == 0 {
+		return
+	}
+	if now.Sub(s.changedAt) < s.leaseDuration || othersDraining {
+		s.logger.Debug("Waiting for the handoff of the acquired managed resources", "since", s.changedAt, "othersDraining", othersDraining)
+		return
+	}
+	s.logger.Info("Handoff of the acquired managed resources has completed", "members", s.ring.members)
+	held := s.held
+	s.held = nil
+	for _, sub := range s.subscribers {
+		go s.enqueueAcquired(s.ctx, sub, held, s.ring)
+	}
+}
+
+// enqueueAcquired sends events for the managed resources of the given
+// subscriber, which are owned by the replica with the next ring but were not
+// owned with all the given previous ones.
+func (s *Sharder) enqueueAcquired(ctx context.Context, sub *subscriber, prev []*ring, next *ring) {
+	var items []client.Object
+	for {
+		list := sub.list.DeepCopyObject().(client.ObjectList)
+		err := sub.reader.List(ctx, list)
+		if err == nil {
+			items, err = objects(list)
+		}
+		if err == nil {
+			break
+		}
+		s.logger.Debug(errListObjects, "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(rebalanceRetryInterval):
+		}
+	}
+	for _, o := range items {
+		k := key(o)
+		if next.owner(k) != s.identity || ownedBy(prev, k, s.identity) {
+			continue
+		}
+		select {
+		case sub.events <- event.GenericEvent{Object: o}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func objects(list client.ObjectList) ([]client.Object, error) {
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, errors.Wrap(err, errListObjects)
+	}
+	result := make([]client.Object, 0, len(items))
+	for _, i := range items {
+		if o, ok := i.(client.Object); ok {
+			result = append(result, o)
+		}
+	}
+	return result, nil
+}
+
+// key returns the key of the given object on the ring, which is its shard
+// label if it has one, or its UID.
+func key(obj client.Object) string {
+	if v := obj.GetLabels()[LabelKeyShard]; v != "" {
+		return "label/" + v
+	}
+	return "uid/" + string(obj.GetUID())
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,372 @@
+// Copyright 2023 Upbound Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shard
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clocktesting "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestRing(t *testing.T) {
+	members := []string{"a", "b", "c"}
+	r := newRing(members, defaultVirtualNodes)
+	reduced := newRing([]string{"a", "b"}, defaultVirtualNodes)
+	counts := map[string]int{}
+	for i := 0; i < 3000; i++ {
+		k := fmt.Sprintf("uid/%d", i)
+		o := r.owner(k)
+		counts[o]++
+		// only the keys of the leaving member are moved.
+		if o != "c" && reduced.owner(k) != o {
+			t.Errorf("owner(%s): want %s after c leaves, got %s", k, o, reduced.owner(k))
+		}
+	}
+	for _, m := range members {
+		if counts[m] < 600 {
+			t.Errorf("owner(...): want at least 600 of 3000 keys owned by %s, got %d", m, counts[m])
+		}
+	}
+	if diff := cmp.Diff("", (*ring)(nil).owner("uid/0")); diff != "" {
+		t.Errorf("owner(...): -want owner of an empty ring, +got:\n%s", diff)
+	}
+}
+
+func newObject(uid, shard string) client.Object {
+	o := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      uid,
+			Namespace: "default",
+			UID:       types.UID(uid),
+		},
+	}
+	if shard != "" {
+		o.Labels = map[string]string{LabelKeyShard: shard}
+	}
+	return o
+}
+
+func TestSharderMembership(t *testing.T) {
+	c := fake.NewClientBuilder().Build()
+	clk := clocktesting.NewFakeClock(time.Now())
+	newSharder := func(id string) *Sharder {
+		s := NewSharder(c, "upbound-system", id, WithLeaseDuration(30*time.Second))
+		s.clock = clk
+		return s
+	}
+	a, b := newSharder("a"), newSharder("b")
+
+	obj := newObject("uid-0", "")
+	if a.Owns(obj) {
+		t.Errorf("Owns(...): want no objects owned before joining the group")
+	}
+	for _, s := range []*Sharder{a, b, a} {
+		if err := s.sync(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, s := range []*Sharder{a, b} {
+		if diff := cmp.Diff([]string{"a", "b"}, s.Members()); diff != "" {
+			t.Errorf("Members(): -want, +got:\n%s", diff)
+		}
+	}
+	// no objects are owned until a lease duration has passed.
+	for i := 0; i < 100; i++ {
+		if o := newObject(fmt.Sprintf("uid-%d", i), ""); a.Owns(o) || b.Owns(o) {
+			t.Errorf("Owns(%s): want no objects owned before the handoff", o.GetUID())
+		}
+	}
+	// the Leases are renewed during the handoff.
+	for i := 0; i < 2; i++ {
+		clk.Step(20 * time.Second)
+		for _, s := range []*Sharder{a, b} {
+			if err := s.sync(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	owned := map[string]int{}
+	ownedByB := map[types.UID]bool{}
+	for i := 0; i < 100; i++ {
+		o := newObject(fmt.Sprintf("uid-%d", i), "")
+		if a.Owns(o) == b.Owns(o) {
+			t.Errorf("Owns(%s): want the object to be owned by exactly one replica", o.GetUID())
+		}
+		if a.Owns(o) {
+			owned["a"]++
+		} else {
+			owned["b"]++
+			ownedByB[o.GetUID()] = true
+		}
+	}
+	if owned["a"] == 0 || owned["b"] == 0 {
+		t.Errorf("Owns(...): want the objects to be distributed, got %v", owned)
+	}
+	// the objects with the same shard label are owned by the same replica.
+	for i := 0; i < 10; i++ {
+		if a.Owns(newObject(fmt.Sprintf("uid-%d", i), "s")) != a.Owns(newObject("uid-other", "s")) {
+			t.Errorf("Owns(...): want the objects with the same shard label to be owned by the same replica")
+		}
+	}
+
+	// a leaves without deleting its Lease, which expires.
+	clk.Step(time.Minute)
+	if err := b.sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"b"}, b.Members()); diff != "" {
+		t.Errorf("Members(): -want, +got:\n%s", diff)
+	}
+	// only the objects owned before a has left are owned until the handoff.
+	for i := 0; i < 100; i++ {
+		if o := newObject(fmt.Sprintf("uid-%d", i), ""); b.Owns(o) != ownedByB[o.GetUID()] {
+			t.Errorf("Owns(%s): want %t before the handoff, got %t", o.GetUID(), ownedByB[o.GetUID()], b.Owns(o))
+		}
+	}
+	if a.Owns(obj) {
+		t.Errorf("Owns(...): want no objects owned by a replica that has not synchronized for a lease duration")
+	}
+	clk.Step(30 * time.Second)
+	if err := b.sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if o := newObject(fmt.Sprintf("uid-%d", i), ""); !b.Owns(o) {
+			t.Errorf("Owns(%s): want all the objects to be owned by the remaining replica", o.GetUID())
+		}
+	}
+
+	// a rejoins and then leaves by deleting its Lease.
+	for _, s := range []*Sharder{a, b} {
+		if err := s.sync(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if diff := cmp.Diff([]string{"a", "b"}, b.Members()); diff != "" {
+		t.Errorf("Members(): -want, +got:\n%s", diff)
+	}
+	if err := a.leave(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"b"}, b.Members()); diff != "" {
+		t.Errorf("Members(): -want, +got:\n%s", diff)
+	}
+}
+
+func TestSharderRebalance(t *testing.T) {
+	objects := make([]client.Object, 0, 50)
+	for i := 0; i < 50; i++ {
+		objects = append(objects, newObject(fmt.Sprintf("uid-%d", i), ""))
+	}
+	c := fake.NewClientBuilder().WithObjects(objects...).Build()
+	b := NewSharder(c, "upbound-system", "b")
+
+	received := func(s *Sharder, prev, next *ring) []string {
+		sub := &subscriber{reader: c, list: &corev1.ConfigMapList{}, events: make(chan event.GenericEvent, len(objects))}
+		s.enqueueAcquired(context.Background(), sub, []*ring{prev}, next)
+		close(sub.events)
+		result := []string{}
+		for e := range sub.events {
+			result = append(result, string(e.Object.GetUID()))
+		}
+		return result
+	}
+
+	alone := newRing([]string{"b"}, defaultVirtualNodes)
+	// all the objects are acquired when the replica joins.
+	if diff := cmp.Diff(len(objects), len(received(b, nil, alone))); diff != "" {
+		t.Errorf("enqueueAcquired(...): -want events, +got events:\n%s", diff)
+	}
+	// no objects are acquired by the replica when another one joins.
+	shared := newRing([]string{"a", "b"}, defaultVirtualNodes)
+	if diff := cmp.Diff(0, len(received(b, alone, shared))); diff != "" {
+		t.Errorf("enqueueAcquired(...): -want events, +got events:\n%s", diff)
+	}
+	// the objects of the leaving replica are acquired when it leaves.
+	want := 0
+	for _, o := range objects {
+		if shared.owner(key(o)) == "a" {
+			want++
+		}
+	}
+	if diff := cmp.Diff(want, len(received(b, shared, alone))); diff != "" {
+		t.Errorf("enqueueAcquired(...): -want events, +got events:\n%s", diff)
+	}
+}
+
+func TestSharderReconciler(t *testing.T) {
+	s := NewSharder(nil, "upbound-system", "a")
+	s.setMembers([]string{"a", "b"})
+	s.syncedAt = s.clock.Now()
+	s.held = nil
+	// pick the shard labels owned by each replica.
+	owned, other := newObject("uid-owned", "owned"), newObject("uid-other", "other")
+	for i := 0; !s.Owns(owned) || s.Owns(other); i++ {
+		owned.SetLabels(map[string]string{LabelKeyShard: fmt.Sprintf("owned-%d", i)})
+		other.SetLabels(map[string]string{LabelKeyShard: fmt.Sprintf("other-%d", i)})
+	}
+	c := fake.NewClientBuilder().WithObjects(owned, other).Build()
+
+	reconciled := map[string]bool{}
+	r := s.Reconciler(c, &corev1.ConfigMap{}, reconcile.Func(func(_ context.Context, req reconcile.Request) (reconcile.Result, error) {
+		reconciled[req.Name] = true
+		return reconcile.Result{}, nil
+	}))
+	for _, n := range []string{"uid-owned", "uid-other", "uid-missing"} {
+		if _, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: n}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := map[string]bool{"uid-owned": true, "uid-missing": true}
+	if diff := cmp.Diff(want, reconciled); diff != "" {
+		t.Errorf("Reconcile(...): -want reconciled, +got reconciled:\n%s", diff)
+	}
+	if got := (*Sharder)(nil).Reconciler(c, &corev1.ConfigMap{}, r); got == nil {
+		t.Errorf("Reconciler(...): want the given reconciler for a nil Sharder")
+	}
+}
+
+type fakeTracker struct {
+	mu      sync.Mutex
+	running []types.UID
+}
+
+func (f *fakeTracker) RunningOperations() []types.UID {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]types.UID(nil), f.running...)
+}
+
+func (f *fakeTracker) set(uids ...types.UID) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.running = uids
+}
+
+func TestSharderHandoff(t *testing.T) {
+	// pick an object moved to b when b joins.
+	shared := newRing([]string{"a", "b"}, defaultVirtualNodes)
+	obj := newObject("uid-0", "")
+	for i := 1; shared.owner(key(obj)) != "b"; i++ {
+		obj = newObject(fmt.Sprintf("uid-%d", i), "")
+	}
+	c := fake.NewClientBuilder().WithObjects(obj).Build()
+	clk := clocktesting.NewFakeClock(time.Now())
+	tracker := &fakeTracker{}
+	newSharder := func(id string, opts ...Option) *Sharder {
+		s := NewSharder(c, "upbound-system", id, append([]Option{WithLeaseDuration(30 * time.Second)}, opts...)...)
+		s.clock = clk
+		return s
+	}
+	a, b := newSharder("a", WithOperationTracker(tracker)), newSharder("b")
+	syncAll := func(ss ...*Sharder) {
+		for _, s := range ss {
+			if err := s.sync(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	draining := func() bool {
+		lease := &coordinationv1.Lease{}
+		if err := c.Get(context.Background(), types.NamespacedName{Namespace: "upbound-system", Name: a.leaseName()}, lease); err != nil {
+			t.Fatal(err)
+		}
+		return lease.Annotations[AnnotationKeyDraining] == "true"
+	}
+	reconciled := 0
+	r := a.Reconciler(c, &corev1.ConfigMap{}, reconcile.Func(func(context.Context, reconcile.Request) (reconcile.Result, error) {
+		reconciled++
+		// the reconciliation starts an asynchronous operation.
+		tracker.set(obj.GetUID())
+		return reconcile.Result{}, nil
+	}))
+	reconcileObj := func() {
+		if _, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: obj.GetName()}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a is the only replica.
+	syncAll(a)
+	clk.Step(30 * time.Second)
+	syncAll(a)
+	if !a.Owns(obj) {
+		t.Fatalf("Owns(...): want the object to be owned by the only replica")
+	}
+	reconcileObj()
+	if diff := cmp.Diff(1, reconciled); diff != "" {
+		t.Errorf("Reconcile(...): -want reconciliations, +got:\n%s", diff)
+	}
+
+	// b joins while the asynchronous operation of the object is running.
+	sub := &subscriber{reader: c, list: &corev1.ConfigMapList{}, events: make(chan event.GenericEvent, 1)}
+	b.subscribers = append(b.subscribers, sub)
+	syncAll(b, a)
+	if a.Owns(obj) {
+		t.Errorf("Owns(...): want the object to be released by a as soon as it observes b")
+	}
+	if !draining() {
+		t.Errorf("sync(...): want a to be draining while the operation of the released object is running")
+	}
+	// a does not reconcile the released object anymore.
+	reconcileObj()
+	if diff := cmp.Diff(1, reconciled); diff != "" {
+		t.Errorf("Reconcile(...): -want reconciliations, +got:\n%s", diff)
+	}
+	// b does not acquire the object while a is draining even after a lease
+	// duration.
+	for i := 0; i < 3; i++ {
+		clk.Step(20 * time.Second)
+		syncAll(a, b)
+		if a.Owns(obj) || b.Owns(obj) {
+			t.Errorf("Owns(...): want the object not to be owned by any replica during the handoff")
+		}
+	}
+
+	// the operation completes and the object is handed off.
+	tracker.set()
+	syncAll(a)
+	if draining() {
+		t.Errorf("sync(...): want a not to be draining once the operation has completed")
+	}
+	syncAll(b)
+	if a.Owns(obj) || !b.Owns(obj) {
+		t.Errorf("Owns(...): want the object to be owned by b after the handoff")
+	}
+	select {
+	case e := <-sub.events:
+		if diff := cmp.Diff(obj.GetUID(), e.Object.GetUID()); diff != "" {
+			t.Errorf("handoff(...): -want acquired object, +got:\n%s", diff)
+		}
+	case <-time.After(10 * time.Second):
+		t.Errorf("handoff(...): want an event for the acquired object")
+	}
+}
@@ -36,6 +36,7 @@ const (
 	annotationExampleGroup = "meta.upbound.io/example-id"
 	defaultExampleName     = "example"
 	defaultNamespace       = "upbound-system"
+	documentSeparator      = "\n---\n\n"
 )
 
 // Generator represents a pipeline for generating example manifests.
@@ -45,11 +46,36 @@ type Generator struct {
 	rootDir         string
 	configResources map[string]*config.Resource
 	resources       map[string]*reference.PavedWithManifest
+	// secrets are the Secrets referred by the example manifests of the
+	// resources.
+	secrets         map[string]*exampleSecrets
+	secretNamespace string
+	synthesize      bool
+}
+
+// GeneratorOption configures a Generator.
+type GeneratorOption func(*Generator)
+
+// WithSynthesizedExamples configures the Generator to synthesize a minimal
+// example manifest from the required fields of the Terraform schema for the
+// resources that have no example in the Terraform registry.
+func WithSynthesizedExamples() GeneratorOption {
+	return func(eg *Generator) {
+		eg.synthesize = true
+	}
+}
+
+// WithSecretNamespace configures the namespace of the Secrets referred by the
+// sensitive fields in the example manifests. Defaults to upbound-system.
+func WithSecretNamespace(ns string) GeneratorOption {
+	return func(eg *Generator) {
+		eg.secretNamespace = ns
+	}
 }
 
 // NewGenerator returns a configured Generator
-func NewGenerator(rootDir, modulePath, shortName string, configResources map[string]*config.Resource) *Generator {
-	return &Generator{
+func NewGenerator(rootDir, modulePath, shortName string, configResources map[string]*config.Resource, opts ...GeneratorOption) *Generator {
+	eg := &Generator{
 		Injector: reference.Injector{
 			ModulePath:        modulePath,
 			ProviderShortName: shortName,
@@ -57,7 +83,13 @@ func NewGenerator(rootDir, modulePath, shortName string, configResources map[str
 		rootDir:         rootDir,
 		configResources: configResources,
 		resources:       make(map[string]*reference.PavedWithManifest),
+		secrets:         make(map[string]*exampleSecrets),
+		secretNamespace: defaultNamespace,
+	}
+	for _, o := range opts {
+		o(eg)
 	}
+	return eg
 }
 
 // StoreExamples stores the generated example manifests under examples-generated in
@@ -75,7 +107,11 @@ func (eg *Generator) StoreExamples() error { // nolint:gocyclo
 		}); err != nil {
 			return errors.Wrapf(err, "cannot store example manifest for resource: %s", rn)
 		}
-		if r, ok := eg.configResources[reference.NewRefPartsFromResourceName(rn).Resource]; ok && r.MetaResource != nil {
+		// The Secrets of the resource and its dependencies are stored after
+		// them.
+		secrets := newExampleSecrets(eg.secretNamespace)
+		secrets.merge(eg.secrets[rn])
+		if r, ok := eg.configResources[reference.NewRefPartsFromResourceName(rn).Resource]; ok && r.MetaResource != nil && len(r.MetaResource.Examples) != 0 {
 			re := r.MetaResource.Examples[0]
 			context, err := reference.PrepareLocalResolutionContext(re, reference.NewRefParts(reference.NewRefPartsFromResourceName(rn).Resource, re.Name).GetResourceName(false))
 			if err != nil {
@@ -98,15 +134,28 @@ func (eg *Generator) StoreExamples() error { // nolint:gocyclo
 				// e.g. meta.upbound.io/example-id: ec2/v1beta1/instance
 				eGroup := fmt.Sprintf("%s/%s/%s", strings.ToLower(r.ShortGroup), r.Version, strings.ToLower(r.Kind))
 				pmd := paveCRManifest(exampleParams, dr.Config,
-					reference.NewRefPartsFromResourceName(dn).ExampleName, dr.Group, dr.Version, eGroup)
+					reference.NewRefPartsFromResourceName(dn).ExampleName, dr.Group, dr.Version, eGroup, secrets)
 				if err := eg.writeManifest(&buff, pmd, context); err != nil {
 					return errors.Wrapf(err, "cannot store example manifest for %s dependency: %s", rn, dn)
 				}
 			}
 		}
+		annotations, err := pm.Paved.GetValue("metadata.annotations")
+		if err != nil {
+			return errors.Wrapf(err, `cannot get "metadata.annotations" of the example manifest for resource: %s`, rn)
+		}
+		for _, s := range secrets.manifests(annotations.(map[string]string)[annotationExampleGroup]) {
+			if err := writeObject(&buff, s); err != nil {
+				return errors.Wrapf(err, "cannot store Secret manifest for resource: %s", rn)
+			}
+		}
 
-		newBuff := bytes.TrimSuffix(buff.Bytes(), []byte("\n---\n\n"))
+		newBuff := bytes.TrimSuffix(buff.Bytes(), []byte(documentSeparator))
 
+		// Unchanged manifests are not rewritten.
+		if old, err := os.ReadFile(filepath.Clean(pm.ManifestPath)); err == nil && bytes.Equal(old, newBuff) {
+			continue
+		}
 		// no sensitive info in the example manifest
 		if err := os.WriteFile(pm.ManifestPath, newBuff, 0600); err != nil {
 			return errors.Wrapf(err, "cannot write example manifest file %s for resource %s", pm.ManifestPath, rn)
@@ -115,10 +164,21 @@ func (eg *Generator) StoreExamples() error { // nolint:gocyclo
 	return nil
 }
 
-func paveCRManifest(exampleParams map[string]any, r *config.Resource, eName, group, version, eGroup string) *reference.PavedWithManifest {
+// ManifestPaths returns the paths of the example manifests that are stored
+// by StoreExamples.
+func (eg *Generator) ManifestPaths() []string {
+	paths := make([]string, 0, len(eg.resources))
+	for _, pm := range eg.resources {
+		paths = append(paths, pm.ManifestPath)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func paveCRManifest(exampleParams map[string]any, r *config.Resource, eName, group, version, eGroup string, secrets *exampleSecrets) *reference.PavedWithManifest {
 	delete(exampleParams, "depends_on")
 	delete(exampleParams, "lifecycle")
-	transformFields(r, exampleParams, r.ExternalName.OmittedFields, "")
+	transformFields(r, exampleParams, r.ExternalName.OmittedFields, "", sensitiveSecretName(r, eName), secrets)
 	metadata := map[string]any{
 		"labels": map[string]string{
 			labelExampleName: eName,
@@ -135,7 +195,7 @@ func paveCRManifest(exampleParams map[string]any, r *config.Resource, eName, gro
 			"forProvider": exampleParams,
 		},
 	}
-	if len(r.MetaResource.ExternalName) != 0 {
+	if r.MetaResource != nil && len(r.MetaResource.ExternalName) != 0 {
 		metadata["annotations"].(map[string]string)[xpmeta.AnnotationKeyExternalName] = r.MetaResource.ExternalName
 	}
 	return &reference.PavedWithManifest{
@@ -163,7 +223,10 @@ func (eg *Generator) writeManifest(writer io.Writer, pm *reference.PavedWithMani
 	if err := pm.Paved.SetValue("metadata.name", pm.ExampleName); err != nil {
 		return errors.Wrapf(err, `cannot set "metadata.name" for resource %q:%s`, pm.Config.Name, pm.ExampleName)
 	}
-	u := pm.Paved.UnstructuredContent()
+	return writeObject(writer, pm.Paved.UnstructuredContent())
+}
+
+func writeObject(writer io.Writer, u map[string]any) error {
 	buff, err := yaml.Marshal(u)
 	if err != nil {
 		return errors.Wrap(err, "cannot marshal example resource manifest")
@@ -171,23 +234,37 @@ func (eg *Generator) writeManifest(writer io.Writer, pm *reference.PavedWithMani
 	if _, err := writer.Write(buff); err != nil {
 		return errors.Wrap(err, "cannot write resource manifest to the underlying stream")
 	}
-	_, err = writer.Write([]byte("\n---\n\n"))
+	_, err = writer.Write([]byte(documentSeparator))
 	return errors.Wrap(err, "cannot write YAML document separator to the underlying stream")
 }
 
+// ManifestPath returns the path of the example manifest of the specified
+// Terraform resource.
+func (eg *Generator) ManifestPath(group string, r *config.Resource) string {
+	groupPrefix := strings.ToLower(strings.Split(group, ".")[0])
+	return filepath.Join(eg.rootDir, "examples-generated", groupPrefix, fmt.Sprintf("%s.yaml", strings.ToLower(r.Kind)))
+}
+
 // Generate generates an example manifest for the specified Terraform resource.
+// If the resource has no example in the Terraform registry, the example
+// manifest is synthesized if the Generator is configured to do so.
 func (eg *Generator) Generate(group, version string, r *config.Resource) error {
+	groupPrefix := strings.ToLower(strings.Split(group, ".")[0])
+	// e.g. gvk = ec2/v1beta1/instance
+	gvk := fmt.Sprintf("%s/%s/%s", groupPrefix, version, strings.ToLower(r.Kind))
 	rm := eg.configResources[r.Name].MetaResource
 	if rm == nil || len(rm.Examples) == 0 {
+		if eg.synthesize {
+			eg.synthesizeExample(group, version, gvk, r)
+		}
 		return nil
 	}
-	groupPrefix := strings.ToLower(strings.Split(group, ".")[0])
-	// e.g. gvk = ec2/v1beta1/instance
-	gvk := fmt.Sprintf("%s/%s/%s", groupPrefix, version, strings.ToLower(r.Kind))
-	pm := paveCRManifest(rm.Examples[0].Paved.UnstructuredContent(), r, rm.Examples[0].Name, group, version, gvk)
-	manifestDir := filepath.Join(eg.rootDir, "examples-generated", groupPrefix)
-	pm.ManifestPath = filepath.Join(manifestDir, fmt.Sprintf("%s.yaml", strings.ToLower(r.Kind)))
-	eg.resources[fmt.Sprintf("%s.%s", r.Name, reference.Wildcard)] = pm
+	secrets := newExampleSecrets(eg.secretNamespace)
+	pm := paveCRManifest(rm.Examples[0].Paved.UnstructuredContent(), r, rm.Examples[0].Name, group, version, gvk, secrets)
+	pm.ManifestPath = eg.ManifestPath(group, r)
+	rn := fmt.Sprintf("%s.%s", r.Name, reference.Wildcard)
+	eg.resources[rn] = pm
+	eg.secrets[rn] = secrets
 	return nil
 }
 
@@ -206,7 +283,7 @@ func isStatus(r *config.Resource, attr string) bool {
 	return tjtypes.IsObservation(s)
 }
 
-func transformFields(r *config.Resource, params map[string]any, omittedFields []string, namePrefix string) { // nolint:gocyclo
+func transformFields(r *config.Resource, params map[string]any, omittedFields []string, namePrefix, secretName string, secrets *exampleSecrets) { // nolint:gocyclo
 	for n := range params {
 		hName := getHierarchicalName(namePrefix, n)
 		if isStatus(r, hName) {
@@ -224,7 +301,7 @@ func transformFields(r *config.Resource, params map[string]any, omittedFields []
 	for n, v := range params {
 		switch pT := v.(type) {
 		case map[string]any:
-			transformFields(r, pT, omittedFields, getHierarchicalName(namePrefix, n))
+			transformFields(r, pT, omittedFields, getHierarchicalName(namePrefix, n), secretName, secrets)
 
 		case []any:
 			for _, e := range pT {
@@ -232,7 +309,7 @@ func transformFields(r *config.Resource, params map[string]any, omittedFields []
 				if !ok {
 					continue
 				}
-				transformFields(r, eM, omittedFields, getHierarchicalName(namePrefix, n))
+				transformFields(r, eM, omittedFields, getHierarchicalName(namePrefix, n), secretName, secrets)
 			}
 		}
 	}
@@ -249,10 +326,11 @@ func transformFields(r *config.Resource, params map[string]any, omittedFields []
 		fn := name.NewFromSnake(n)
 		switch {
 		case sch.Sensitive:
-			secretName, secretKey := getSecretRef(v)
+			refName, secretKey := getSecretRef(v, secretName, fieldPath)
+			secrets.add(refName, secretKey, v)
 			params[fn.LowerCamelComputed+"SecretRef"] = getRefField(v, map[string]any{
-				"name":      secretName,
-				"namespace": defaultNamespace,
+				"name":      refName,
+				"namespace": secrets.namespace,
 				"key":       secretKey,
 			})
 		case r.References[fieldPath] != config.Reference{}:
@@ -309,9 +387,19 @@ func getRefField(v any, ref map[string]any) any {
 	}
 }
 
-func getSecretRef(v any) (string, string) {
-	secretName := "example-secret"
-	secretKey := "example-key"
+// sensitiveSecretName returns the name of the Secret holding the sensitive
+// values of the example of the given resource that are not references to
+// the attributes of other resources.
+func sensitiveSecretName(r *config.Resource, eName string) string {
+	return fmt.Sprintf("%s-%s-sensitive", dns1123Name(eName), strings.ToLower(r.Kind))
+}
+
+// getSecretRef returns the Secret name and key referred by the given value of
+// the sensitive field at the given path. The references to the attributes of
+// other resources are derived from the referred attributes, while the other
+// values are stored under the field path in the given Secret.
+func getSecretRef(v any, secretName, fieldPath string) (string, string) {
+	secretKey := strings.ToLower(fieldPath)
 	s, ok := v.(string)
 	if !ok {
 		return secretName, secretKey
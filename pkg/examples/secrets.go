@@ -0,0 +1,87 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package examples
+
+import (
+	"sort"
+
+	"github.com/upbound/upjet/pkg/registry/reference"
+)
+
+const (
+	placeholderSecretValue = "REPLACE-WITH-A-SECRET-VALUE"
+)
+
+// exampleSecrets are the Secrets referred by the secret references of the
+// sensitive fields in an example manifest.
+type exampleSecrets struct {
+	// namespace is the namespace of the Secrets.
+	namespace string
+	// data maps the Secret names to their data.
+	data map[string]map[string]string
+}
+
+func newExampleSecrets(namespace string) *exampleSecrets {
+	return &exampleSecrets{
+		namespace: namespace,
+		data:      map[string]map[string]string{},
+	}
+}
+
+// add adds the given key of the given Secret using the given value of the
+// sensitive field from the HCL example. A placeholder is used if the value
+// is not a literal string, e.g. if it's a reference to another resource.
+// The literal values are not overridden by the placeholders.
+func (s *exampleSecrets) add(name, key string, v any) {
+	if s.data[name] == nil {
+		s.data[name] = map[string]string{}
+	}
+	value, ok := v.(string)
+	if !ok || value == "" || reference.ReRef.MatchString(value) {
+		value = placeholderSecretValue
+	}
+	if old, ok := s.data[name][key]; ok && old != placeholderSecretValue {
+		return
+	}
+	s.data[name][key] = value
+}
+
+// merge adds the Secrets in the given exampleSecrets.
+func (s *exampleSecrets) merge(o *exampleSecrets) {
+	if o == nil {
+		return
+	}
+	for name, data := range o.data {
+		for k, v := range data {
+			s.add(name, k, v)
+		}
+	}
+}
+
+// manifests returns the manifests of the Secrets sorted by their names.
+func (s *exampleSecrets) manifests(eGroup string) []map[string]any {
+	names := make([]string, 0, len(s.data))
+	for n := range s.data {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	manifests := make([]map[string]any, 0, len(names))
+	for _, n := range names {
+		manifests = append(manifests, map[string]any{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata": map[string]any{
+				"name":      n,
+				"namespace": s.namespace,
+				"annotations": map[string]string{
+					annotationExampleGroup: eGroup,
+				},
+			},
+			"type":       "Opaque",
+			"stringData": s.data[n],
+		})
+	}
+	return manifests
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package examples
+
+import (
+	"os"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/upbound/upjet/pkg/config"
+	"github.com/upbound/upjet/pkg/registry"
+	"github.com/upbound/upjet/pkg/resource/json"
+)
+
+func TestExampleSecrets(t *testing.T) {
+	manifest := `{"engine": "postgres", "password": "s3cr3t", "admin_token": "t0k3n", "master_password": "${random_password.pw.result}"}`
+	var params map[string]any
+	if err := json.TFParser.Unmarshal([]byte(manifest), &params); err != nil {
+		t.Fatal(err)
+	}
+	r := config.DefaultResource("test_database", &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"engine":          {Type: schema.TypeString, Required: true},
+			"password":        {Type: schema.TypeString, Optional: true, Sensitive: true},
+			"admin_token":     {Type: schema.TypeString, Optional: true, Sensitive: true},
+			"master_password": {Type: schema.TypeString, Optional: true, Sensitive: true},
+		},
+	}, &registry.Resource{
+		Examples: []registry.ResourceExample{{Name: "example", Manifest: manifest, Paved: *fieldpath.Pave(params)}},
+	})
+	r.ShortGroup = "test"
+
+	dir := t.TempDir()
+	eg := NewGenerator(dir, "github.com/upbound/provider-test", "test", map[string]*config.Resource{
+		"test_database": r,
+	}, WithSecretNamespace("crossplane-system"))
+	if err := eg.Generate("test.upbound.io", "v1alpha1", r); err != nil {
+		t.Fatalf("Generate(...): %s", err)
+	}
+	if err := eg.StoreExamples(); err != nil {
+		t.Fatalf("StoreExamples(): %s", err)
+	}
+	got, err := os.ReadFile(eg.ManifestPath("test.upbound.io", r))
+	if err != nil {
+		t.Fatalf("cannot read the example manifest: %s", err)
+	}
+	want := `apiVersion: test.upbound.io/v1alpha1
+kind: Database
+metadata:
+  annotations:
+    meta.upbound.io/example-id: test/v1alpha1/database
+  labels:
+    testing.upbound.io/example-name: example
+  name: example
+spec:
+  forProvider:
+    adminTokenSecretRef:
+      key: admin_token
+      name: example-database-sensitive
+      namespace: crossplane-system
+    engine: postgres
+    masterPasswordSecretRef:
+      key: attribute.result
+      name: example-password
+      namespace: crossplane-system
+    passwordSecretRef:
+      key: password
+      name: example-database-sensitive
+      namespace: crossplane-system
+
+---
+
+apiVersion: v1
+kind: Secret
+metadata:
+  annotations:
+    meta.upbound.io/example-id: test/v1alpha1/database
+  name: example-database-sensitive
+  namespace: crossplane-system
+stringData:
+  admin_token: t0k3n
+  password: s3cr3t
+type: Opaque
+
+---
+
+apiVersion: v1
+kind: Secret
+metadata:
+  annotations:
+    meta.upbound.io/example-id: test/v1alpha1/database
+  name: example-password
+  namespace: crossplane-system
+stringData:
+  attribute.result: REPLACE-WITH-A-SECRET-VALUE
+type: Opaque
+`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("StoreExamples(): -want, +got:\n%s", diff)
+	}
+}
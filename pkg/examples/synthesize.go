@@ -0,0 +1,109 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package examples
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/upbound/upjet/pkg/config"
+	"github.com/upbound/upjet/pkg/registry/reference"
+)
+
+const (
+	placeholderString = "example"
+	placeholderMapKey = "example-key"
+)
+
+// synthesizeExample synthesizes the example manifest of the specified
+// resource from the required fields of its Terraform schema.
+func (eg *Generator) synthesizeExample(group, version, gvk string, r *config.Resource) {
+	params := eg.requiredParams(r, r.TerraformResource, "")
+	secrets := newExampleSecrets(eg.secretNamespace)
+	pm := paveCRManifest(params, r, defaultExampleName, group, version, gvk, secrets)
+	pm.ManifestPath = eg.ManifestPath(group, r)
+	rn := fmt.Sprintf("%s.%s", r.Name, reference.Wildcard)
+	eg.resources[rn] = pm
+	eg.secrets[rn] = secrets
+}
+
+// requiredParams returns the example parameters of the required fields of
+// the given Terraform schema in the form of the scraped HCL examples, i.e.
+// the references to the other resources and the sensitive values are HCL
+// references that are transformed while paving the example manifest.
+func (eg *Generator) requiredParams(r *config.Resource, res *schema.Resource, namePrefix string) map[string]any {
+	params := map[string]any{}
+	for n, s := range res.Schema {
+		hName := getHierarchicalName(namePrefix, n)
+		if !s.Required || isOmitted(r, hName) {
+			continue
+		}
+		params[n] = eg.placeholder(r, s, hName)
+	}
+	return params
+}
+
+// placeholder returns the example value of the given field.
+func (eg *Generator) placeholder(r *config.Resource, s *schema.Schema, hName string) any {
+	switch s.Type { // nolint:exhaustive
+	case schema.TypeList, schema.TypeSet:
+		if res, ok := s.Elem.(*schema.Resource); ok {
+			return []any{eg.requiredParams(r, res, hName)}
+		}
+		return []any{eg.scalarPlaceholder(r, s, hName)}
+	case schema.TypeMap:
+		return map[string]any{placeholderMapKey: eg.scalarPlaceholder(r, s, hName)}
+	}
+	return eg.scalarPlaceholder(r, s, hName)
+}
+
+// scalarPlaceholder returns the example value of the given scalar field, or
+// of the elements of the given list, set or map field.
+func (eg *Generator) scalarPlaceholder(r *config.Resource, s *schema.Schema, hName string) any {
+	switch {
+	case s.Sensitive:
+		// e.g. ${aws_db_instance.example.password}, which is transformed
+		// into a reference to the example-db-instance Secret.
+		return fmt.Sprintf("${%s.%s.%s}", r.Name, defaultExampleName, hName)
+	case r.References[hName] != config.Reference{}:
+		// the reference is transformed into a selector or a reference to
+		// the example of the referenced resource.
+		target := r.References[hName].TerraformName
+		if target == "" {
+			return placeholderString
+		}
+		return fmt.Sprintf("${%s.%s.id}", target, eg.exampleName(target))
+	}
+	t := s.Type
+	if e, ok := s.Elem.(*schema.Schema); ok && (t == schema.TypeList || t == schema.TypeSet || t == schema.TypeMap) {
+		t = e.Type
+	}
+	switch t { // nolint:exhaustive
+	case schema.TypeBool:
+		return true
+	case schema.TypeInt, schema.TypeFloat:
+		return 1
+	}
+	return placeholderString
+}
+
+// exampleName returns the name of the example of the specified Terraform
+// resource.
+func (eg *Generator) exampleName(tfName string) string {
+	if r, ok := eg.configResources[tfName]; ok && r.MetaResource != nil && len(r.MetaResource.Examples) != 0 {
+		return r.MetaResource.Examples[0].Name
+	}
+	return defaultExampleName
+}
+
+func isOmitted(r *config.Resource, hName string) bool {
+	for _, f := range r.ExternalName.OmittedFields {
+		if f == hName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package examples
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/upbound/upjet/pkg/config"
+	"github.com/upbound/upjet/pkg/registry"
+)
+
+func TestSynthesizedExample(t *testing.T) {
+	instance := config.DefaultResource("test_instance", &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"name":      {Type: schema.TypeString, Required: true},
+			"size":      {Type: schema.TypeInt, Required: true},
+			"enabled":   {Type: schema.TypeBool, Required: true},
+			"password":  {Type: schema.TypeString, Required: true, Sensitive: true},
+			"subnet_id": {Type: schema.TypeString, Required: true},
+			"zone":      {Type: schema.TypeString, Optional: true},
+			"arn":       {Type: schema.TypeString, Computed: true},
+			"tags":      {Type: schema.TypeMap, Required: true, Elem: &schema.Schema{Type: schema.TypeString}},
+			"rule": {Type: schema.TypeList, Required: true, Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"port":        {Type: schema.TypeInt, Required: true},
+					"description": {Type: schema.TypeString, Optional: true},
+				},
+			}},
+		},
+	}, nil)
+	instance.ShortGroup = "test"
+	instance.References["subnet_id"] = config.Reference{TerraformName: "test_subnet"}
+	instance.ExternalName.OmittedFields = []string{"name"}
+	subnet := config.DefaultResource("test_subnet", &schema.Resource{}, &registry.Resource{
+		Examples: []registry.ResourceExample{{Name: "main"}},
+	})
+
+	dir := t.TempDir()
+	eg := NewGenerator(dir, "github.com/upbound/provider-test", "test", map[string]*config.Resource{
+		"test_instance": instance,
+		"test_subnet":   subnet,
+	}, WithSynthesizedExamples())
+	if err := eg.Generate("test.upbound.io", "v1alpha1", instance); err != nil {
+		t.Fatalf("Generate(...): %s", err)
+	}
+	if err := eg.StoreExamples(); err != nil {
+		t.Fatalf("StoreExamples(): %s", err)
+	}
+	got, err := os.ReadFile(eg.ManifestPath("test.upbound.io", instance))
+	if err != nil {
+		t.Fatalf("cannot read the example manifest: %s", err)
+	}
+	want := `apiVersion: test.upbound.io/v1alpha1
+kind: Instance
+metadata:
+  annotations:
+    meta.upbound.io/example-id: test/v1alpha1/instance
+  labels:
+    testing.upbound.io/example-name: example
+  name: example
+spec:
+  forProvider:
+    enabled: true
+    passwordSecretRef:
+      key: attribute.password
+      name: example-instance
+      namespace: upbound-system
+    rule:
+    - port: 1
+    size: 1
+    subnetIdSelector:
+      matchLabels:
+        testing.upbound.io/example-name: main
+    tags:
+      example-key: example
+
+---
+
+apiVersion: v1
+kind: Secret
+metadata:
+  annotations:
+    meta.upbound.io/example-id: test/v1alpha1/instance
+  name: example-instance
+  namespace: upbound-system
+stringData:
+  attribute.password: REPLACE-WITH-A-SECRET-VALUE
+type: Opaque
+`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("StoreExamples(): -want, +got:\n%s", diff)
+	}
+}
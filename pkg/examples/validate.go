@@ -0,0 +1,321 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package examples
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	errUnknownField    = "unknown field"
+	errFmtTypeMismatch = "expected %s, found %s"
+	errMissingRequired = "missing required field"
+)
+
+var reRequiredParameter = regexp.MustCompile(`^spec\.forProvider\.(\S+) is a required parameter$`)
+
+// ValidationError is an invalid field of an example manifest.
+type ValidationError struct {
+	// Path is the path of the example manifest.
+	Path string
+	// Kind and Name are the kind and the name of the invalid object in the
+	// example manifest.
+	Kind string
+	Name string
+	// FieldPath is the path of the invalid field, e.g.
+	// spec.forProvider.rule[0].name.
+	FieldPath string
+	// Message describes why the field is invalid.
+	Message string
+	// Pruned is set if the field has been removed from the example manifest.
+	Pruned bool
+}
+
+// ValidationErrors is the report of all the invalid fields of the example
+// manifests.
+type ValidationErrors []ValidationError
+
+// Error returns the report with one invalid field per line.
+func (e ValidationErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, ve := range e {
+		lines[i] = fmt.Sprintf("%s: %s/%s: %s: %s", ve.Path, ve.Kind, ve.Name, ve.FieldPath, ve.Message)
+		if ve.Pruned {
+			lines[i] += " (pruned)"
+		}
+	}
+	return fmt.Sprintf("%d invalid example manifest field(s):\n%s", len(e), strings.Join(lines, "\n"))
+}
+
+// ValidateExamples validates the example manifests stored by StoreExamples
+// against the OpenAPI schemas of the CRDs in the given directory, e.g.
+// package/crds, and returns a ValidationErrors report of the unknown fields,
+// the fields with mismatching types and the missing required fields. If prune
+// is set, the unknown fields and the fields with mismatching types are removed
+// from the example manifests. The objects whose CRDs are not found in the
+// directory, e.g. the Secrets, are not validated.
+func (eg *Generator) ValidateExamples(crdDir string, prune bool) error {
+	schemas, err := loadCRDSchemas(crdDir)
+	if err != nil {
+		return err
+	}
+	return eg.ValidateExamplesWithSchemas(schemas, prune)
+}
+
+// ValidateExamplesWithSchemas validates the example manifests stored by
+// StoreExamples against the given OpenAPI schemas keyed by
+// <group>/<version>/<kind>, e.g. the schemas built from the types generated
+// in the same run. See ValidateExamples for the report and prune.
+func (eg *Generator) ValidateExamplesWithSchemas(schemas map[string]*extv1.JSONSchemaProps, prune bool) error {
+	var errs ValidationErrors
+	for _, p := range eg.ManifestPaths() {
+		ve, err := validateManifest(p, schemas, prune)
+		if err != nil {
+			return errors.Wrapf(err, "cannot validate example manifest %s", p)
+		}
+		errs = append(errs, ve...)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// loadCRDSchemas returns the OpenAPI schemas of the CRDs in the given
+// directory keyed by their <API version>/<kind>.
+func loadCRDSchemas(dir string) (map[string]*extv1.JSONSchemaProps, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot list the CRDs in %s", dir)
+	}
+	schemas := map[string]*extv1.JSONSchemaProps{}
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Clean(f))
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read CRD file %s", f)
+		}
+		crd := &extv1.CustomResourceDefinition{}
+		if err := yaml.Unmarshal(data, crd); err != nil {
+			return nil, errors.Wrapf(err, "cannot unmarshal CRD file %s", f)
+		}
+		for _, v := range crd.Spec.Versions {
+			if v.Schema == nil || v.Schema.OpenAPIV3Schema == nil {
+				continue
+			}
+			schemas[fmt.Sprintf("%s/%s/%s", crd.Spec.Group, v.Name, crd.Spec.Names.Kind)] = v.Schema.OpenAPIV3Schema
+		}
+	}
+	return schemas, nil
+}
+
+func validateManifest(path string, schemas map[string]*extv1.JSONSchemaProps, prune bool) (ValidationErrors, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read example manifest")
+	}
+	docs := bytes.Split(data, []byte(documentSeparator))
+	var errs ValidationErrors
+	pruned := false
+	for i, d := range docs {
+		u := map[string]any{}
+		if err := yaml.Unmarshal(d, &u); err != nil {
+			return nil, errors.Wrap(err, "cannot unmarshal example manifest")
+		}
+		kind, _ := u["kind"].(string)
+		s, ok := schemas[fmt.Sprintf("%v/%s", u["apiVersion"], kind)]
+		if !ok {
+			continue
+		}
+		name := ""
+		if m, ok := u["metadata"].(map[string]any); ok {
+			name, _ = m["name"].(string)
+		}
+		v := &validator{prune: prune}
+		if fs, ok := s.Properties["spec"]; ok && u["spec"] != nil {
+			v.validate(u, "spec", "spec", &fs)
+		}
+		v.validateRequiredParameters(u, s)
+		for _, fe := range v.errs {
+			errs = append(errs, ValidationError{Path: path, Kind: kind, Name: name, FieldPath: fe.path, Message: fe.message, Pruned: fe.pruned})
+			pruned = pruned || fe.pruned
+		}
+		if !v.modified() {
+			continue
+		}
+		if docs[i], err = yaml.Marshal(u); err != nil {
+			return nil, errors.Wrap(err, "cannot marshal pruned example manifest")
+		}
+	}
+	if !pruned {
+		return errs, nil
+	}
+	return errs, errors.Wrap(os.WriteFile(path, bytes.Join(docs, []byte(documentSeparator)), 0600), "cannot write pruned example manifest")
+}
+
+type fieldError struct {
+	path    string
+	message string
+	pruned  bool
+}
+
+// validator validates an object against an OpenAPI schema.
+type validator struct {
+	prune bool
+	errs  []fieldError
+}
+
+func (v *validator) modified() bool {
+	for _, e := range v.errs {
+		if e.pruned {
+			return true
+		}
+	}
+	return false
+}
+
+// report records an invalid field and reports whether it should be removed.
+func (v *validator) report(path, message string, prunable bool) bool {
+	p := v.prune && prunable
+	v.errs = append(v.errs, fieldError{path: path, message: message, pruned: p})
+	return p
+}
+
+// validate validates the field with the given key of the given object and
+// removes it if it's pruned.
+func (v *validator) validate(obj map[string]any, key, path string, s *extv1.JSONSchemaProps) {
+	val, keep := v.validValue(obj[key], path, s)
+	if !keep {
+		delete(obj, key)
+		return
+	}
+	obj[key] = val
+}
+
+// validValue validates the given value and returns it without its pruned
+// fields, and whether it should be kept.
+func (v *validator) validValue(val any, path string, s *extv1.JSONSchemaProps) (any, bool) { // nolint:gocyclo
+	if s.XIntOrString || s.XPreserveUnknownFields != nil && *s.XPreserveUnknownFields && s.Type == "" {
+		return val, true
+	}
+	if val == nil && s.Nullable {
+		return val, true
+	}
+	if t := valueType(val); s.Type != "" && !typeMatches(s.Type, t) {
+		return val, !v.report(path, fmt.Sprintf(errFmtTypeMismatch, s.Type, t), true)
+	}
+	switch s.Type {
+	case "object":
+		obj := val.(map[string]any)
+		for _, k := range sortedKeys(obj) {
+			fp := path + "." + k
+			switch fs, ok := s.Properties[k]; {
+			case ok:
+				v.validate(obj, k, fp, &fs)
+			case s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil:
+				v.validate(obj, k, fp, s.AdditionalProperties.Schema)
+			case s.AdditionalProperties != nil && s.AdditionalProperties.Allows,
+				s.XPreserveUnknownFields != nil && *s.XPreserveUnknownFields:
+			default:
+				if v.report(fp, errUnknownField, true) {
+					delete(obj, k)
+				}
+			}
+		}
+		for _, r := range s.Required {
+			if _, ok := obj[r]; !ok {
+				v.report(path+"."+r, errMissingRequired, false)
+			}
+		}
+	case "array":
+		if s.Items == nil || s.Items.Schema == nil {
+			return val, true
+		}
+		arr := val.([]any)
+		result := make([]any, 0, len(arr))
+		for i, e := range arr {
+			if e, keep := v.validValue(e, fmt.Sprintf("%s[%d]", path, i), s.Items.Schema); keep {
+				result = append(result, e)
+			}
+		}
+		return result, true
+	}
+	return val, true
+}
+
+// validateRequiredParameters checks the spec.forProvider fields that are
+// required via the CEL validation rules of the generated CRDs. The fields
+// can also be set in spec.initProvider.
+func (v *validator) validateRequiredParameters(u map[string]any, s *extv1.JSONSchemaProps) {
+	spec, ok := u["spec"].(map[string]any)
+	if !ok {
+		return
+	}
+	for _, r := range s.Properties["spec"].XValidations {
+		m := reRequiredParameter.FindStringSubmatch(r.Message)
+		if m == nil {
+			continue
+		}
+		if hasField(spec["forProvider"], m[1]) || hasField(spec["initProvider"], m[1]) {
+			continue
+		}
+		v.report("spec.forProvider."+m[1], errMissingRequired, false)
+	}
+}
+
+func hasField(params any, name string) bool {
+	m, ok := params.(map[string]any)
+	if !ok {
+		return false
+	}
+	_, ok = m[name]
+	return ok
+}
+
+// valueType returns the OpenAPI type of the given unmarshaled value.
+func valueType(val any) string {
+	switch t := val.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int64, int:
+		return "integer"
+	case float64:
+		if t == float64(int64(t)) {
+			return "integer"
+		}
+		return "number"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", val)
+}
+
+func typeMatches(schemaType, valueType string) bool {
+	return schemaType == valueType || schemaType == "number" && valueType == "integer"
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package examples
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+func TestValidateManifest(t *testing.T) {
+	params := extv1.JSONSchemaProps{
+		Type: "object",
+		Properties: map[string]extv1.JSONSchemaProps{
+			"instanceType": {Type: "string"},
+			"size":         {Type: "number"},
+			"tags": {Type: "object", AdditionalProperties: &extv1.JSONSchemaPropsOrBool{
+				Schema: &extv1.JSONSchemaProps{Type: "string"},
+			}},
+			"rule": {Type: "array", Items: &extv1.JSONSchemaPropsOrArray{Schema: &extv1.JSONSchemaProps{
+				Type:       "object",
+				Required:   []string{"port"},
+				Properties: map[string]extv1.JSONSchemaProps{"port": {Type: "integer"}},
+			}}},
+		},
+	}
+	schemas := map[string]*extv1.JSONSchemaProps{
+		"test.upbound.io/v1alpha1/Instance": {
+			Type: "object",
+			Properties: map[string]extv1.JSONSchemaProps{
+				"spec": {
+					Type:     "object",
+					Required: []string{"forProvider"},
+					Properties: map[string]extv1.JSONSchemaProps{
+						"forProvider":  params,
+						"initProvider": params,
+					},
+					XValidations: extv1.ValidationRules{
+						{Message: "spec.forProvider.instanceType is a required parameter"},
+					},
+				},
+			},
+		},
+	}
+	const header = `apiVersion: test.upbound.io/v1alpha1
+kind: Instance
+metadata:
+  name: example
+`
+	const secret = `apiVersion: v1
+kind: Secret
+metadata:
+  name: example-secret
+stringData:
+  foo: bar
+`
+	cases := map[string]struct {
+		reason   string
+		manifest string
+		prune    bool
+		want     ValidationErrors
+		// wantManifest is the manifest after validation, if it differs.
+		wantManifest string
+	}{
+		"Valid": {
+			reason: "A manifest conforming to the schema should be valid.",
+			manifest: header + `spec:
+  forProvider:
+    rule:
+    - port: 80
+    size: 1.5
+    tags:
+      foo: bar
+  initProvider:
+    instanceType: t2.micro
+`,
+		},
+		"Invalid": {
+			reason: "The unknown fields, the fields with mismatching types and the missing required fields should be reported.",
+			manifest: header + `spec:
+  forProvider:
+    arn: foo
+    rule:
+    - port: "80"
+    - {}
+    tags:
+      foo: 1
+`,
+			want: ValidationErrors{
+				{Kind: "Instance", Name: "example", FieldPath: "spec.forProvider.arn", Message: errUnknownField},
+				{Kind: "Instance", Name: "example", FieldPath: "spec.forProvider.rule[0].port", Message: "expected integer, found string"},
+				{Kind: "Instance", Name: "example", FieldPath: "spec.forProvider.rule[1].port", Message: errMissingRequired},
+				{Kind: "Instance", Name: "example", FieldPath: "spec.forProvider.tags.foo", Message: "expected string, found integer"},
+				{Kind: "Instance", Name: "example", FieldPath: "spec.forProvider.instanceType", Message: errMissingRequired},
+			},
+		},
+		"Pruned": {
+			reason: "The unknown fields and the fields with mismatching types should be removed if pruning is enabled.",
+			manifest: header + `spec:
+  forProvider:
+    arn: foo
+    instanceType: t2.micro
+    rule:
+    - port: "80"
+
+---
+
+` + secret,
+			prune: true,
+			want: ValidationErrors{
+				{Kind: "Instance", Name: "example", FieldPath: "spec.forProvider.arn", Message: errUnknownField, Pruned: true},
+				{Kind: "Instance", Name: "example", FieldPath: "spec.forProvider.rule[0].port", Message: "expected integer, found string", Pruned: true},
+				{Kind: "Instance", Name: "example", FieldPath: "spec.forProvider.rule[0].port", Message: errMissingRequired},
+			},
+			wantManifest: header + `spec:
+  forProvider:
+    instanceType: t2.micro
+    rule:
+    - {}
+
+---
+
+` + secret,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "instance.yaml")
+			if err := os.WriteFile(path, []byte(tc.manifest), 0600); err != nil {
+				t.Fatal(err)
+			}
+			for i := range tc.want {
+				tc.want[i].Path = path
+			}
+			got, err := validateManifest(path, schemas, tc.prune)
+			if err != nil {
+				t.Fatalf("\n%s\nvalidateManifest(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nvalidateManifest(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tc.manifest
+			if tc.wantManifest != "" {
+				want = tc.wantManifest
+			}
+			if diff := cmp.Diff(want, string(data)); diff != "" {
+				t.Errorf("\n%s\nvalidateManifest(...): -want manifest, +got manifest:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
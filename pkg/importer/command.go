@@ -0,0 +1,94 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package importer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/upbound/upjet/pkg/config"
+	"github.com/upbound/upjet/pkg/resource/json"
+)
+
+// Main is the entrypoint of the importer command of a provider. Its state
+// subcommand reads a Terraform state file and its hcl subcommand reads the
+// Terraform configuration files in a directory, and they write the manifests
+// of the managed resources. A provider can expose it with a main package such
+// as:
+//
+//	func main() {
+//		importer.Main(config.GetProvider())
+//	}
+func Main(pc *config.Provider) {
+	var (
+		app             = kingpin.New(filepath.Base(os.Args[0]), "Converts the resources managed by Terraform into Crossplane managed resource manifests.").DefaultEnvars()
+		outPath         = app.Flag("out", "Path of the output manifest file. The manifests are written to the standard output by default.").Short('o').String()
+		observeOnly     = app.Flag("observe-only", "Set the management policies of the managed resources to Observe").Bool()
+		secretNamespace = app.Flag("secret-namespace", "Namespace of the Secrets holding the sensitive attributes").Default(defaultSecretNamespace).String()
+		stateCmd        = app.Command("state", "Converts the resources in a Terraform state file.")
+		statePath       = stateCmd.Flag("state", "Path of the Terraform state file").Short('s').Default("terraform.tfstate").ExistingFile()
+		hclCmd          = app.Command("hcl", "Converts the resource blocks in the Terraform configuration files of a directory.")
+		hclDir          = hclCmd.Flag("dir", "Directory of the Terraform configuration files").Short('d').Default(".").ExistingDir()
+	)
+	cmd := kingpin.MustParse(app.Parse(os.Args[1:]))
+
+	var opts []Option
+	if *observeOnly {
+		opts = append(opts, WithObserveOnly())
+	}
+	opts = append(opts, WithSecretNamespace(*secretNamespace))
+	i := New(pc, opts...)
+	switch cmd {
+	case stateCmd.FullCommand():
+		kingpin.FatalIfError(Run(i.importStateFile, *statePath, *outPath), "Failed to import the Terraform state")
+	case hclCmd.FullCommand():
+		kingpin.FatalIfError(Run(i.ConvertHCL, *hclDir, *outPath), "Failed to convert the Terraform configuration")
+	}
+}
+
+// Run converts the input at the given path with the given function and
+// writes the manifests to the file at the given output path, or to the
+// standard output if it's empty. The skipped resources and the issues are
+// reported to the standard error.
+func Run(convertFn func(path string) (*Result, error), path, outPath string) error {
+	res, err := convertFn(path)
+	if err != nil {
+		return err
+	}
+	for _, s := range res.Skipped {
+		fmt.Fprintf(os.Stderr, "WARNING: skipped %s: no managed resource is configured for its type\n", s)
+	}
+	for _, is := range res.Issues {
+		fmt.Fprintf(os.Stderr, "WARNING: %s: %s\n", is.Address, is.Message)
+	}
+	out := os.Stdout
+	if outPath != "" {
+		f, err := os.OpenFile(filepath.Clean(outPath), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			return errors.Wrap(err, "cannot open the output file")
+		}
+		defer f.Close() // nolint:errcheck
+		out = f
+	}
+	return errors.Wrap(Write(out, res.Manifests), "cannot write the manifests")
+}
+
+// importStateFile imports the Terraform state file at the given path.
+func (i *Importer) importStateFile(path string) (*Result, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read the Terraform state file")
+	}
+	st := &json.StateV4{}
+	if err := json.JSParser.Unmarshal(data, st); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal the Terraform state")
+	}
+	res, err := i.Import(st)
+	return res, errors.Wrap(err, "cannot import the Terraform state")
+}
@@ -0,0 +1,285 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package importer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	xpmeta "github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/pkg/errors"
+	"github.com/tmccombs/hcl2json/convert"
+
+	"github.com/upbound/upjet/pkg/config"
+	"github.com/upbound/upjet/pkg/registry/reference"
+	"github.com/upbound/upjet/pkg/resource/json"
+	"github.com/upbound/upjet/pkg/types/name"
+)
+
+const (
+	blockResource = "resource"
+
+	errFmtParseHCL           = "cannot parse the Terraform configuration file %s"
+	errFmtConvertBlock       = "cannot convert the resource block %s to JSON"
+	errFmtUnsupportedMeta    = "the %q meta-argument is not supported and ignored"
+	errFmtUnconvertibleExpr  = "cannot convert the expression %s of %s"
+	errFmtUnknownExtractor   = "the attribute extracted by the reference extractor %s is unknown"
+	errFmtExtractedAttribute = "the reference resolves to the %q attribute instead of %q"
+)
+
+var (
+	// unsupportedMetaArguments are the meta-arguments that cannot be
+	// expressed with managed resources.
+	unsupportedMetaArguments = []string{"count", "for_each", "provider", "provisioner", "connection"}
+	// ignoredMetaArguments are the meta-arguments that are not needed by
+	// the managed resources.
+	ignoredMetaArguments = []string{"depends_on", "lifecycle"}
+)
+
+// resourceBlock is a resource block in a Terraform configuration.
+type resourceBlock struct {
+	address string
+	tfType  string
+	name    string
+	attrs   map[string]any
+}
+
+// ConvertHCL converts the resource blocks in the Terraform configuration
+// files, i.e. the .tf files, in the given directory into managed resource
+// manifests. The references to the attributes of the other resources are
+// converted into the reference fields of the managed resources if the
+// references are configured for the fields. The expressions that cannot be
+// converted, e.g. the variables and the function calls, and the unsupported
+// meta-arguments, e.g. count and for_each, are reported as Issues.
+func (i *Importer) ConvertHCL(dir string) (*Result, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot list the Terraform configuration files in %s", dir)
+	}
+	sort.Strings(files)
+	var blocks []*resourceBlock
+	for _, f := range files {
+		b, err := parseResourceBlocks(f)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, b...)
+	}
+	resolver := &expressionResolver{resources: make(map[string]string, len(blocks))}
+	for _, b := range blocks {
+		if _, ok := i.provider.Resources[b.tfType]; ok {
+			resolver.resources[b.address] = dns1123Name(b.name)
+		}
+	}
+	res := &Result{}
+	for _, b := range blocks {
+		cfg, ok := i.provider.Resources[b.tfType]
+		if !ok {
+			res.Skipped = append(res.Skipped, b.address)
+			continue
+		}
+		resolver.address = b.address
+		resolver.issues = nil
+		for _, m := range unsupportedMetaArguments {
+			if _, ok := b.attrs[m]; ok {
+				resolver.report(fmt.Sprintf(errFmtUnsupportedMeta, m))
+			}
+		}
+		for _, m := range append(unsupportedMetaArguments, ignoredMetaArguments...) {
+			delete(b.attrs, m)
+		}
+		annotations := map[string]string{}
+		if en, ok := externalName(cfg, b.attrs); ok {
+			annotations[xpmeta.AnnotationKeyExternalName] = en
+		}
+		res.Manifests = append(res.Manifests, i.manifests(cfg, dns1123Name(b.name), annotations, b.attrs, resolver)...)
+		res.Issues = append(res.Issues, resolver.issues...)
+	}
+	return res, nil
+}
+
+// parseResourceBlocks returns the resource blocks in the given Terraform
+// configuration file with their attributes converted to JSON values. The
+// expressions are converted into strings in the ${<expression>} form.
+func parseResourceBlocks(path string) ([]*resourceBlock, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read the Terraform configuration file %s", path)
+	}
+	f, diag := hclparse.NewParser().ParseHCL(data, filepath.Base(path))
+	if diag.HasErrors() {
+		return nil, errors.Wrapf(diag, errFmtParseHCL, path)
+	}
+	body, ok := f.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, errors.Errorf(errFmtParseHCL, path)
+	}
+	var blocks []*resourceBlock
+	for _, b := range body.Blocks {
+		if b.Type != blockResource || len(b.Labels) != 2 {
+			continue
+		}
+		address := b.Labels[0] + "." + b.Labels[1]
+		buff, err := convert.File(&hcl.File{Body: b.Body, Bytes: f.Bytes}, convert.Options{})
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtConvertBlock, address)
+		}
+		attrs := map[string]any{}
+		if err := json.JSParser.Unmarshal(buff, &attrs); err != nil {
+			return nil, errors.Wrapf(err, errFmtConvertBlock, address)
+		}
+		blocks = append(blocks, &resourceBlock{address: address, tfType: b.Labels[0], name: b.Labels[1], attrs: attrs})
+	}
+	return blocks, nil
+}
+
+// externalName returns the external name of the resource if its identifier
+// argument, i.e. the argument set by the SetIdentifierArgumentFn of its
+// external name configuration, is a literal in the given attributes.
+func externalName(cfg *config.Resource, attrs map[string]any) (string, bool) {
+	if cfg.ExternalName.SetIdentifierArgumentFn == nil {
+		return "", false
+	}
+	const probe = "external-name-probe"
+	base := map[string]any{}
+	cfg.ExternalName.SetIdentifierArgumentFn(base, probe)
+	for k, v := range base {
+		if v != probe {
+			continue
+		}
+		if s, ok := attrs[k].(string); ok && !hasExpression(s) {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// expressionResolver converts the HCL expressions of a resource into the
+// reference fields of its managed resource.
+type expressionResolver struct {
+	// resources maps the addresses of the converted resources to the names
+	// of their managed resources.
+	resources map[string]string
+	// address is the address of the resource being converted.
+	address string
+	issues  []Issue
+}
+
+func (r *expressionResolver) report(msg string) {
+	r.issues = append(r.issues, Issue{Address: r.address, Message: msg})
+}
+
+// resolve returns the reference field and its value for the given field with
+// the given value that contains expressions. The value can be converted if
+// the field has a configured reference and the value consists of the
+// references to the attribute extracted by the reference from the other
+// converted resources of the referenced type.
+func (r *expressionResolver) resolve(cfg *config.Resource, hName string, fn name.Name, v any) (string, any, bool) {
+	ref, ok := cfg.References[hName]
+	if !ok {
+		r.reportUnconvertible(expressionString(v), hName, "")
+		return "", nil, false
+	}
+	switch t := v.(type) {
+	case string:
+		mrName, reason, ok := r.target(ref, t)
+		if !ok {
+			r.reportUnconvertible(t, hName, reason)
+			return "", nil, false
+		}
+		return name.ReferenceFieldName(fn, false, ref.RefFieldName).LowerCamelComputed, map[string]any{"name": mrName}, true
+	case []any:
+		refs := make([]any, 0, len(t))
+		for _, e := range t {
+			s, _ := e.(string)
+			mrName, reason, ok := r.target(ref, s)
+			if !ok {
+				r.reportUnconvertible(expressionString(v), hName, reason)
+				return "", nil, false
+			}
+			refs = append(refs, map[string]any{"name": mrName})
+		}
+		return name.ReferenceFieldName(fn, true, ref.RefFieldName).LowerCamelComputed, refs, true
+	}
+	r.reportUnconvertible(expressionString(v), hName, "")
+	return "", nil, false
+}
+
+// reportUnconvertible reports the given expression of the given field as
+// unconvertible for the given reason, if any.
+func (r *expressionResolver) reportUnconvertible(expr, hName, reason string) {
+	msg := fmt.Sprintf(errFmtUnconvertibleExpr, expr, hName)
+	if reason != "" {
+		msg += ": " + reason
+	}
+	r.report(msg)
+}
+
+// target returns the name of the managed resource referred by the given
+// expression, which must be a reference to the attribute extracted by the
+// given reference from another converted resource of its type. Otherwise,
+// it returns false and the reason, if it's worth reporting.
+func (r *expressionResolver) target(ref config.Reference, expr string) (string, string, bool) {
+	if !strings.HasPrefix(expr, "${") || strings.Count(expr, "${") != 1 || !strings.HasSuffix(expr, "}") {
+		return "", "", false
+	}
+	parts := reference.MatchRefParts(expr)
+	if parts == nil || ref.TerraformName != "" && parts.Resource != ref.TerraformName {
+		return "", "", false
+	}
+	mrName, ok := r.resources[parts.Resource+"."+parts.ExampleName]
+	if !ok {
+		return "", "", false
+	}
+	// The reference resolves to the value its extractor returns, which
+	// must be the value the expression refers to.
+	attr, ok := reference.ExtractedAttribute(ref.Extractor)
+	switch {
+	case !ok:
+		return "", fmt.Sprintf(errFmtUnknownExtractor, ref.Extractor), false
+	case attr != parts.Attribute:
+		return "", fmt.Sprintf(errFmtExtractedAttribute, attr, parts.Attribute), false
+	}
+	return mrName, "", true
+}
+
+// hasExpression reports whether the given converted HCL value contains an
+// expression.
+func hasExpression(v any) bool {
+	switch t := v.(type) {
+	case string:
+		return reference.ReRef.MatchString(t)
+	case []any:
+		for _, e := range t {
+			if hasExpression(e) {
+				return true
+			}
+		}
+	case map[string]any:
+		for _, e := range t {
+			if hasExpression(e) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func expressionString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, err := json.JSParser.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package importer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/upbound/upjet/pkg/config"
+)
+
+const testConfiguration = `
+variable "zone" {}
+
+resource "test_network" "main" {
+  cidr = "10.0.0.0/16"
+}
+
+resource "test_instance" "web_server" {
+  count = 2
+
+  name               = "web"
+  network_id         = test_network.main.id
+  network_arn        = test_network.main.arn
+  peer_network_arn   = test_network.main.arn
+  security_group_ids = [test_group.a.id]
+  zone               = var.zone
+  password           = "s3cr3t"
+
+  rule {
+    port       = 80
+    network_id = test_network.main.id
+  }
+
+  depends_on = [test_network.main]
+}
+
+resource "test_unknown" "foo" {}
+`
+
+func TestConvertHCL(t *testing.T) {
+	instance := config.DefaultResource("test_instance", &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"name":               {Type: schema.TypeString, Required: true},
+			"network_id":         {Type: schema.TypeString, Optional: true},
+			"network_arn":        {Type: schema.TypeString, Optional: true},
+			"peer_network_arn":   {Type: schema.TypeString, Optional: true},
+			"security_group_ids": {Type: schema.TypeSet, Optional: true, Elem: &schema.Schema{Type: schema.TypeString}},
+			"zone":               {Type: schema.TypeString, Optional: true},
+			"password":           {Type: schema.TypeString, Optional: true, Sensitive: true},
+			"rule": {Type: schema.TypeList, Optional: true, Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"port":       {Type: schema.TypeInt, Required: true},
+					"network_id": {Type: schema.TypeString, Optional: true},
+				},
+			}},
+		},
+	}, nil)
+	instance.References["network_id"] = config.Reference{TerraformName: "test_network"}
+	instance.References["network_arn"] = config.Reference{TerraformName: "test_network"}
+	instance.References["peer_network_arn"] = config.Reference{TerraformName: "test_network", Extractor: `github.com/upbound/upjet/pkg/resource.ExtractParamPath("arn",true)`}
+	instance.References["rule.network_id"] = config.Reference{TerraformName: "test_network"}
+	instance.References["security_group_ids"] = config.Reference{TerraformName: "test_group"}
+	network := config.DefaultResource("test_network", &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"cidr": {Type: schema.TypeString, Required: true},
+		},
+	}, nil)
+	network.ExternalName = config.IdentifierFromProvider
+	pc := &config.Provider{
+		RootGroup: "upbound.io",
+		Resources: map[string]*config.Resource{"test_instance": instance, "test_network": network},
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.tf"), []byte(testConfiguration), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := New(pc).ConvertHCL(dir)
+	if err != nil {
+		t.Fatalf("ConvertHCL(...): %s", err)
+	}
+	if diff := cmp.Diff([]string{"test_unknown.foo"}, res.Skipped); diff != "" {
+		t.Errorf("ConvertHCL(...): -want skipped, +got skipped:\n%s", diff)
+	}
+	wantIssues := []Issue{
+		{Address: "test_instance.web_server", Message: `the "count" meta-argument is not supported and ignored`},
+		{Address: "test_instance.web_server", Message: `cannot convert the expression ${test_network.main.arn} of network_arn: the reference resolves to the "id" attribute instead of "arn"`},
+		{Address: "test_instance.web_server", Message: `cannot convert the expression ["${test_group.a.id}"] of security_group_ids`},
+		{Address: "test_instance.web_server", Message: `cannot convert the expression ${var.zone} of zone`},
+	}
+	if diff := cmp.Diff(wantIssues, res.Issues); diff != "" {
+		t.Errorf("ConvertHCL(...): -want issues, +got issues:\n%s", diff)
+	}
+	buff := &bytes.Buffer{}
+	if err := Write(buff, res.Manifests); err != nil {
+		t.Fatalf("Write(...): %s", err)
+	}
+	want := `apiVersion: test.upbound.io/v1alpha1
+kind: Network
+metadata:
+  name: main
+spec:
+  forProvider:
+    cidr: 10.0.0.0/16
+
+---
+
+apiVersion: test.upbound.io/v1alpha1
+kind: Instance
+metadata:
+  annotations:
+    crossplane.io/external-name: web
+  name: web-server
+spec:
+  forProvider:
+    networkIdRef:
+      name: main
+    passwordSecretRef:
+      key: password
+      name: web-server-test-instance-sensitive
+      namespace: upbound-system
+    peerNetworkArnRef:
+      name: main
+    rule:
+    - networkIdRef:
+        name: main
+      port: 80
+
+---
+
+apiVersion: v1
+kind: Secret
+metadata:
+  name: web-server-test-instance-sensitive
+  namespace: upbound-system
+stringData:
+  password: s3cr3t
+type: Opaque
+`
+	if diff := cmp.Diff(want, buff.String()); diff != "" {
+		t.Errorf("ConvertHCL(...): -want manifests, +got manifests:\n%s", diff)
+	}
+}
@@ -0,0 +1,387 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+// Package importer converts the resources managed by Terraform into the
+// manifests of the corresponding Crossplane managed resources.
+package importer
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	xpmeta "github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"github.com/upbound/upjet/pkg/config"
+	"github.com/upbound/upjet/pkg/resource"
+	"github.com/upbound/upjet/pkg/resource/json"
+	tjtypes "github.com/upbound/upjet/pkg/types"
+	"github.com/upbound/upjet/pkg/types/name"
+)
+
+const (
+	defaultSecretNamespace  = "upbound-system"
+	managementPolicyObserve = "Observe"
+	modeManaged             = "managed"
+	suffixSecretRef         = "SecretRef"
+	suffixSensitiveSecret   = "-sensitive"
+	documentSeparator       = "\n---\n\n"
+
+	errFmtUnmarshalAttributes = "cannot unmarshal the attributes of %s"
+	errFmtExternalName        = "cannot get the external name of %s"
+)
+
+var reInvalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// Option configures an Importer.
+type Option func(*Importer)
+
+// WithObserveOnly configures the Importer to set the management policies of
+// the managed resources to Observe, so that Crossplane does not modify or
+// delete the imported resources.
+func WithObserveOnly() Option {
+	return func(i *Importer) {
+		i.observeOnly = true
+	}
+}
+
+// WithSecretNamespace configures the namespace of the Secrets holding the
+// sensitive attributes. Defaults to upbound-system.
+func WithSecretNamespace(ns string) Option {
+	return func(i *Importer) {
+		i.secretNamespace = ns
+	}
+}
+
+// Importer converts the resources in a Terraform state into the manifests of
+// the managed resources of a provider.
+type Importer struct {
+	provider        *config.Provider
+	observeOnly     bool
+	secretNamespace string
+}
+
+// New returns a new Importer for the managed resources of the given
+// provider.
+func New(pc *config.Provider, opts ...Option) *Importer {
+	i := &Importer{
+		provider:        pc,
+		secretNamespace: defaultSecretNamespace,
+	}
+	for _, o := range opts {
+		o(i)
+	}
+	return i
+}
+
+// Result is the outcome of an import.
+type Result struct {
+	// Manifests are the manifests of the managed resources and the Secrets
+	// holding their sensitive attributes.
+	Manifests []map[string]any
+	// Skipped are the addresses of the resource instances that are not
+	// imported since the provider has no managed resources for them.
+	Skipped []string
+	// Issues are the parts of the input that could not be converted.
+	Issues []Issue
+}
+
+// Issue is a part of a resource that could not be converted.
+type Issue struct {
+	// Address is the Terraform address of the resource.
+	Address string
+	// Message describes what could not be converted.
+	Message string
+}
+
+// Import converts the managed resource instances in the given Terraform
+// state into managed resource manifests. The data sources and the deposed
+// instances are not imported.
+func (i *Importer) Import(st *json.StateV4) (*Result, error) {
+	res := &Result{}
+	for _, r := range st.Resources {
+		if r.Mode != modeManaged {
+			continue
+		}
+		for _, inst := range r.Instances {
+			if inst.Deposed != "" {
+				continue
+			}
+			address := instanceAddress(r, inst)
+			cfg, ok := i.provider.Resources[r.Type]
+			if !ok {
+				res.Skipped = append(res.Skipped, address)
+				continue
+			}
+			attrs := map[string]any{}
+			if err := json.TFParser.Unmarshal(inst.AttributesRaw, &attrs); err != nil {
+				return nil, errors.Wrapf(err, errFmtUnmarshalAttributes, address)
+			}
+			externalName, err := cfg.ExternalName.GetExternalNameFn(attrs)
+			if err != nil {
+				return nil, errors.Wrapf(err, errFmtExternalName, address)
+			}
+			annotations := map[string]string{
+				xpmeta.AnnotationKeyExternalName: externalName,
+			}
+			if len(inst.PrivateRaw) != 0 {
+				annotations[resource.AnnotationKeyPrivateRawAttribute] = string(inst.PrivateRaw)
+			}
+			res.Manifests = append(res.Manifests, i.manifests(cfg, resourceName(r, inst), annotations, attrs, nil)...)
+		}
+	}
+	return res, nil
+}
+
+// manifests returns the manifest of the managed resource with the given name
+// whose parameters are the given Terraform attributes, followed by the
+// manifests of the Secrets holding its sensitive parameters. The HCL
+// expressions in the attributes are converted with the given resolver, if
+// any.
+func (i *Importer) manifests(cfg *config.Resource, mrName string, annotations map[string]string, attrs map[string]any, resolver *expressionResolver) []map[string]any {
+	// The managed resources of different types may have the same name, so
+	// the names of their Secrets include the Terraform resource type.
+	secretPrefix := dns1123Name(mrName + "-" + cfg.Name)
+	c := &converter{
+		resource:        cfg,
+		secretNamespace: i.secretNamespace,
+		secretName:      secretPrefix + suffixSensitiveSecret,
+		secretPrefix:    secretPrefix,
+		secrets:         map[string]map[string]any{},
+		resolver:        resolver,
+	}
+	spec := map[string]any{
+		"forProvider": c.parameters(cfg.TerraformResource, attrs, "", ""),
+	}
+	if i.observeOnly {
+		spec["managementPolicies"] = []any{managementPolicyObserve}
+	}
+	metadata := map[string]any{
+		"name": mrName,
+	}
+	if len(annotations) != 0 {
+		metadata["annotations"] = annotations
+	}
+	mr := map[string]any{
+		"apiVersion": fmt.Sprintf("%s/%s", i.group(cfg), cfg.Version),
+		"kind":       cfg.Kind,
+		"metadata":   metadata,
+		"spec":       spec,
+	}
+	result := []map[string]any{mr}
+	for _, n := range sortedKeys(c.secrets) {
+		result = append(result, map[string]any{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata": map[string]any{
+				"name":      n,
+				"namespace": i.secretNamespace,
+			},
+			"type":       "Opaque",
+			"stringData": c.secrets[n],
+		})
+	}
+	return result
+}
+
+// group returns the API group of the given resource.
+func (i *Importer) group(cfg *config.Resource) string {
+	if cfg.ShortGroup == "" {
+		return i.provider.RootGroup
+	}
+	return strings.ToLower(cfg.ShortGroup) + "." + i.provider.RootGroup
+}
+
+// converter converts the Terraform attributes of a resource into the
+// parameters of a managed resource.
+type converter struct {
+	resource *config.Resource
+	// secretPrefix is the prefix of the names of the Secrets holding the
+	// sensitive maps.
+	secretPrefix    string
+	secretNamespace string
+	// secretName is the name of the Secret holding the sensitive string
+	// parameters.
+	secretName string
+	// secrets are the data of the Secrets keyed by their names.
+	secrets map[string]map[string]any
+	// resolver converts the HCL expressions, if set.
+	resolver *expressionResolver
+}
+
+// parameters returns the parameters of the given attributes of the given
+// Terraform schema. The observation fields, the omitted fields and the
+// unset values are dropped and the sensitive values are moved into Secrets.
+// The tfPrefix is the path of the attributes including the list indices,
+// and the hPrefix is their hierarchical name, e.g. "rule.0." and "rule.".
+func (c *converter) parameters(res *schema.Resource, attrs map[string]any, tfPrefix, hPrefix string) map[string]any { // nolint:gocyclo
+	params := map[string]any{}
+	for _, n := range sortedKeys(attrs) {
+		s, ok := res.Schema[n]
+		if !ok || tjtypes.IsObservation(s) || isEmpty(attrs[n]) || isOmitted(c.resource, tfPrefix+n) {
+			continue
+		}
+		fn := name.NewFromSnake(n)
+		tfPath := tfPrefix + n
+		_, block := s.Elem.(*schema.Resource)
+		if c.resolver != nil && !block && hasExpression(attrs[n]) {
+			if k, v, ok := c.resolver.resolve(c.resource, hPrefix+n, fn, attrs[n]); ok {
+				params[k] = v
+			}
+			continue
+		}
+		if s.Sensitive {
+			if ref := c.secretRef(attrs[n], tfPath); ref != nil {
+				params[fn.LowerCamelComputed+suffixSecretRef] = ref
+			}
+			continue
+		}
+		switch v := attrs[n].(type) {
+		case []any:
+			elemRes, ok := s.Elem.(*schema.Resource)
+			if !ok {
+				params[fn.LowerCamelComputed] = v
+				continue
+			}
+			l := make([]any, 0, len(v))
+			for j, e := range v {
+				if m, ok := e.(map[string]any); ok {
+					l = append(l, c.parameters(elemRes, m, fmt.Sprintf("%s.%d.", tfPath, j), hPrefix+n+"."))
+				}
+			}
+			params[fn.LowerCamelComputed] = l
+		default:
+			params[fn.LowerCamelComputed] = v
+		}
+	}
+	return params
+}
+
+// secretRef stores the given sensitive value in a Secret and returns the
+// reference to it: a key of the Secret for strings and lists of strings, or
+// a dedicated Secret for maps.
+func (c *converter) secretRef(v any, tfPath string) any {
+	switch t := v.(type) {
+	case map[string]any:
+		secretName := dns1123Name(c.secretPrefix + "-" + tfPath)
+		c.secrets[secretName] = t
+		return map[string]any{
+			"name":      secretName,
+			"namespace": c.secretNamespace,
+		}
+	case []any:
+		refs := make([]any, 0, len(t))
+		for j, e := range t {
+			refs = append(refs, c.secretKeyRef(e, fmt.Sprintf("%s.%d", tfPath, j)))
+		}
+		return refs
+	case string:
+		return c.secretKeyRef(t, tfPath)
+	}
+	return nil
+}
+
+func (c *converter) secretKeyRef(v any, key string) map[string]any {
+	if c.secrets[c.secretName] == nil {
+		c.secrets[c.secretName] = map[string]any{}
+	}
+	c.secrets[c.secretName][key] = fmt.Sprint(v)
+	return map[string]any{
+		"name":      c.secretName,
+		"namespace": c.secretNamespace,
+		"key":       key,
+	}
+}
+
+// Write writes the given manifests as a multi-document YAML stream.
+func Write(w io.Writer, manifests []map[string]any) error {
+	for j, m := range manifests {
+		if j != 0 {
+			if _, err := io.WriteString(w, documentSeparator); err != nil {
+				return errors.Wrap(err, "cannot write YAML document separator")
+			}
+		}
+		data, err := yaml.Marshal(m)
+		if err != nil {
+			return errors.Wrap(err, "cannot marshal manifest")
+		}
+		if _, err := w.Write(data); err != nil {
+			return errors.Wrap(err, "cannot write manifest")
+		}
+	}
+	return nil
+}
+
+// instanceAddress returns the Terraform address of the given resource
+// instance, e.g. module.vpc.aws_subnet.private["a"].
+func instanceAddress(r json.ResourceStateV4, inst json.InstanceObjectStateV4) string {
+	address := r.Type + "." + r.Name
+	if r.Module != "" {
+		address = r.Module + "." + address
+	}
+	switch k := inst.IndexKey.(type) {
+	case nil:
+	case string:
+		address += fmt.Sprintf("[%q]", k)
+	default:
+		address += fmt.Sprintf("[%v]", k)
+	}
+	return address
+}
+
+// resourceName returns the name of the managed resource of the given
+// resource instance, which is the DNS-1123 form of its Terraform name
+// prefixed with its module path and suffixed with its index key, if any.
+func resourceName(r json.ResourceStateV4, inst json.InstanceObjectStateV4) string {
+	parts := make([]string, 0, 3)
+	if r.Module != "" {
+		parts = append(parts, strings.ReplaceAll(r.Module, "module.", ""))
+	}
+	parts = append(parts, r.Name)
+	if inst.IndexKey != nil {
+		parts = append(parts, fmt.Sprint(inst.IndexKey))
+	}
+	return dns1123Name(strings.Join(parts, "-"))
+}
+
+func dns1123Name(s string) string {
+	return strings.Trim(reInvalidNameChars.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+func isOmitted(r *config.Resource, tfPath string) bool {
+	for _, f := range r.ExternalName.OmittedFields {
+		if f == tfPath {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmpty reports whether the given attribute value is unset in the
+// Terraform state.
+func isEmpty(v any) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case []any:
+		return len(t) == 0
+	case map[string]any:
+		return len(t) == 0
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
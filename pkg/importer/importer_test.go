@@ -0,0 +1,183 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package importer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/upbound/upjet/pkg/config"
+	"github.com/upbound/upjet/pkg/resource/json"
+)
+
+const testState = `{
+  "version": 4,
+  "resources": [
+    {
+      "mode": "managed",
+      "type": "test_instance",
+      "name": "web_server",
+      "module": "module.app",
+      "provider": "provider[\"registry.terraform.io/upbound/test\"]",
+      "instances": [
+        {
+          "index_key": 0,
+          "schema_version": 1,
+          "attributes": {
+            "id": "i-12345",
+            "name": "web",
+            "arn": "arn:test:instance/i-12345",
+            "size": 2,
+            "zone": null,
+            "password": "s3cr3t",
+            "tags": {"env": "dev"},
+            "labels": {},
+            "rule": [{"port": 80, "rule_id": "r-1"}]
+          },
+          "private": "eyJzY2hlbWFfdmVyc2lvbiI6IjEifQ=="
+        }
+      ]
+    },
+    {
+      "mode": "managed",
+      "type": "test_unknown",
+      "name": "foo",
+      "instances": [{"schema_version": 0, "attributes": {"id": "foo"}}]
+    },
+    {
+      "mode": "data",
+      "type": "test_instance",
+      "name": "existing",
+      "instances": [{"schema_version": 0, "attributes": {"id": "i-0"}}]
+    }
+  ]
+}`
+
+func TestImport(t *testing.T) {
+	r := config.DefaultResource("test_instance", &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"name":     {Type: schema.TypeString, Required: true},
+			"arn":      {Type: schema.TypeString, Computed: true},
+			"size":     {Type: schema.TypeInt, Optional: true},
+			"zone":     {Type: schema.TypeString, Optional: true},
+			"password": {Type: schema.TypeString, Optional: true, Sensitive: true},
+			"tags":     {Type: schema.TypeMap, Optional: true, Elem: &schema.Schema{Type: schema.TypeString}},
+			"labels":   {Type: schema.TypeMap, Optional: true, Elem: &schema.Schema{Type: schema.TypeString}},
+			"rule": {Type: schema.TypeList, Optional: true, Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"port":    {Type: schema.TypeInt, Required: true},
+					"rule_id": {Type: schema.TypeString, Computed: true},
+				},
+			}},
+		},
+	}, nil)
+	r.ShortGroup = "compute"
+	pc := &config.Provider{
+		RootGroup: "test.upbound.io",
+		Resources: map[string]*config.Resource{"test_instance": r},
+	}
+	st := &json.StateV4{}
+	if err := json.JSParser.Unmarshal([]byte(testState), st); err != nil {
+		t.Fatal(err)
+	}
+	res, err := New(pc, WithObserveOnly(), WithSecretNamespace("crossplane-system")).Import(st)
+	if err != nil {
+		t.Fatalf("Import(...): %s", err)
+	}
+	if diff := cmp.Diff([]string{"test_unknown.foo"}, res.Skipped); diff != "" {
+		t.Errorf("Import(...): -want skipped, +got skipped:\n%s", diff)
+	}
+	buff := &bytes.Buffer{}
+	if err := Write(buff, res.Manifests); err != nil {
+		t.Fatalf("Write(...): %s", err)
+	}
+	want := `apiVersion: compute.test.upbound.io/v1alpha1
+kind: Instance
+metadata:
+  annotations:
+    crossplane.io/external-name: i-12345
+    upjet.crossplane.io/provider-meta: '{"schema_version":"1"}'
+  name: app-web-server-0
+spec:
+  forProvider:
+    passwordSecretRef:
+      key: password
+      name: app-web-server-0-test-instance-sensitive
+      namespace: crossplane-system
+    rule:
+    - port: 80
+    size: 2
+    tags:
+      env: dev
+  managementPolicies:
+  - Observe
+
+---
+
+apiVersion: v1
+kind: Secret
+metadata:
+  name: app-web-server-0-test-instance-sensitive
+  namespace: crossplane-system
+stringData:
+  password: s3cr3t
+type: Opaque
+`
+	if diff := cmp.Diff(want, buff.String()); diff != "" {
+		t.Errorf("Import(...): -want manifests, +got manifests:\n%s", diff)
+	}
+}
+
+func TestImportSecretNames(t *testing.T) {
+	newResource := func(name string) *config.Resource {
+		return config.DefaultResource(name, &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"password": {Type: schema.TypeString, Optional: true, Sensitive: true},
+				"secrets":  {Type: schema.TypeMap, Optional: true, Sensitive: true, Elem: &schema.Schema{Type: schema.TypeString}},
+			},
+		}, nil)
+	}
+	pc := &config.Provider{
+		RootGroup: "test.upbound.io",
+		Resources: map[string]*config.Resource{
+			"test_user":     newResource("test_user"),
+			"test_database": newResource("test_database"),
+		},
+	}
+	st := &json.StateV4{}
+	if err := json.JSParser.Unmarshal([]byte(`{
+  "version": 4,
+  "resources": [
+    {"mode": "managed", "type": "test_user", "name": "main", "instances": [{"schema_version": 0, "attributes": {"id": "u", "password": "user", "secrets": {"k": "user"}}}]},
+    {"mode": "managed", "type": "test_database", "name": "main", "instances": [{"schema_version": 0, "attributes": {"id": "d", "password": "database", "secrets": {"k": "database"}}}]}
+  ]
+}`), st); err != nil {
+		t.Fatal(err)
+	}
+	res, err := New(pc).Import(st)
+	if err != nil {
+		t.Fatalf("Import(...): %s", err)
+	}
+	// The resources of different types with the same Terraform name must not
+	// share their Secrets.
+	got := map[string]any{}
+	for _, m := range res.Manifests {
+		if m["kind"] == "Secret" {
+			got[m["metadata"].(map[string]any)["name"].(string)] = m["stringData"]
+		}
+	}
+	want := map[string]any{
+		"main-test-user-sensitive":     map[string]any{"password": "user"},
+		"main-test-user-secrets":       map[string]any{"k": "user"},
+		"main-test-database-sensitive": map[string]any{"password": "database"},
+		"main-test-database-secrets":   map[string]any{"k": "database"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Import(...): -want secrets, +got secrets:\n%s", diff)
+	}
+}
@@ -20,6 +20,26 @@ import (
 )
 
 const (
+	// SchedulerOperationStart is the provider scheduler operation label
+	// for starting a native provider runner.
+	SchedulerOperationStart = "start"
+	// SchedulerOperationStop is the provider scheduler operation label for
+	// stopping an expired or idle native provider runner.
+	SchedulerOperationStop = "stop"
+	// SchedulerOperationEvict is the provider scheduler operation label for
+	// evicting the least recently used native provider runner.
+	SchedulerOperationEvict = "evict"
+	// SchedulerOperationRestart is the provider scheduler operation label
+	// for restarting an unhealthy or unavailable native provider runner.
+	SchedulerOperationRestart = "restart"
+	// SchedulerOperationRecycle is the provider scheduler operation label
+	// for recycling a native provider runner exceeding the maximum
+	// resident set size.
+	SchedulerOperationRecycle = "recycle"
+	// SchedulerOperationRetry is the provider scheduler operation label for
+	// asking a caller to retry scheduling.
+	SchedulerOperationRetry = "retry"
+
 	promNSUpjet     = "upjet"
 	promSysTF       = "terraform"
 	promSysResource = "resource"
@@ -43,6 +63,25 @@ var (
 		Help:      "The number of active (running) Terraform CLI invocations",
 	}, []string{"subcommand", "mode"})
 
+	// CLIQueueWaitTime is the histogram of the times the Terraform CLI
+	// invocations wait for the concurrency limits.
+	CLIQueueWaitTime = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: promNSUpjet,
+		Subsystem: promSysTF,
+		Name:      "cli_queue_wait_duration",
+		Help:      "Measures in seconds how long a Terraform CLI invocation waits for the concurrency limits",
+		Buckets:   []float64{0.1, 0.5, 1, 5, 10, 30, 60, 120, 300},
+	}, []string{"subcommand", "class"})
+
+	// CLIQueuedInvocations are the number of the Terraform CLI invocations
+	// waiting for the concurrency limits.
+	CLIQueuedInvocations = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: promNSUpjet,
+		Subsystem: promSysTF,
+		Name:      "queued_cli_invocations",
+		Help:      "The number of the Terraform CLI invocations waiting for the concurrency limits",
+	}, []string{"class"})
+
 	// TFProcesses are the active number of
 	// terraform CLI & Terraform provider processes running.
 	TFProcesses = prometheus.NewGaugeVec(prometheus.GaugeOpts{
@@ -52,6 +91,25 @@ var (
 		Help:      "The number of running Terraform CLI and Terraform provider processes",
 	}, []string{"type"})
 
+	// ProviderSchedulerOperations are the numbers of the operations of the
+	// shared native provider scheduler.
+	ProviderSchedulerOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: promNSUpjet,
+		Subsystem: promSysTF,
+		Name:      "provider_scheduler_operations_total",
+		Help:      "The number of the native provider runner starts, stops, evictions, restarts, recycles and scheduling retries",
+	}, []string{"operation"})
+
+	// RefreshBatchSize is the histogram of the numbers of the managed
+	// resources refreshed together by the batched refreshes.
+	RefreshBatchSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: promNSUpjet,
+		Subsystem: promSysTF,
+		Name:      "refresh_batch_size",
+		Help:      "The number of the managed resources refreshed together by a batched Terraform CLI invocation",
+		Buckets:   []float64{1, 2, 5, 10, 20, 50, 100, 200},
+	})
+
 	// TTRMeasurements are the time-to-readiness measurements for
 	// the managed resources.
 	TTRMeasurements = prometheus.NewHistogramVec(prometheus.HistogramOpts{
@@ -64,5 +122,5 @@ var (
 )
 
 func init() {
-	metrics.Registry.MustRegister(CLITime, CLIExecutions, TFProcesses, TTRMeasurements)
+	metrics.Registry.MustRegister(CLITime, CLIExecutions, CLIQueueWaitTime, CLIQueuedInvocations, TFProcesses, ProviderSchedulerOperations, RefreshBatchSize, TTRMeasurements)
 }
@@ -0,0 +1,352 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	twtypes "github.com/muvaf/typewriter/pkg/types"
+	"github.com/pkg/errors"
+
+	tjpkg "github.com/upbound/upjet/pkg"
+	"github.com/upbound/upjet/pkg/config"
+	"github.com/upbound/upjet/pkg/pipeline/templates"
+	tjtypes "github.com/upbound/upjet/pkg/types"
+	"github.com/upbound/upjet/pkg/types/name"
+)
+
+const (
+	apiReferenceRoot = "docs-generated"
+)
+
+var (
+	// markdownGenStatement is printed in an HTML comment on every generated
+	// Markdown file.
+	markdownGenStatement = strings.TrimPrefix(GenStatement, "// ")
+
+	reRequiredParameter = regexp.MustCompile(`message="spec\.forProvider\.(\S+) is a required parameter"`)
+)
+
+// NewAPIReferenceGenerator returns a new APIReferenceGenerator.
+func NewAPIReferenceGenerator(rootDir string) *APIReferenceGenerator {
+	return &APIReferenceGenerator{
+		LocalDirectoryPath: filepath.Join(rootDir, apiReferenceRoot),
+	}
+}
+
+// APIReferenceGenerator generates the Markdown API reference pages of the
+// managed resources and an index page per API group.
+type APIReferenceGenerator struct {
+	LocalDirectoryPath string
+}
+
+// resourceReference is the API reference of a managed resource.
+type resourceReference struct {
+	GenStatement     string
+	Group            string
+	Version          string
+	Kind             string
+	TerraformName    string
+	DataSource       bool
+	Description      string
+	ExternalName     string
+	ImportStatements []string
+	Sections         []referenceSection
+	References       []referenceTarget
+	// Example is the generated example manifest of the resource.
+	Example string
+
+	resource *config.Resource
+}
+
+// referenceSection is the field tree of a parameters or an observation type.
+type referenceSection struct {
+	Name   string
+	Fields []referenceField
+}
+
+// referenceField is a field of the field tree of a managed resource.
+type referenceField struct {
+	// Path is the path of the field relative to its section, e.g.
+	// "rule[].name".
+	Path        string
+	Type        string
+	Required    bool
+	Sensitive   bool
+	Description string
+}
+
+// referenceTarget is a cross resource reference of a managed resource.
+type referenceTarget struct {
+	Field  string
+	Target string
+}
+
+// FilePath returns the path of the API reference page of the given
+// resource.
+func (g *APIReferenceGenerator) FilePath(group, version string, cfg *config.Resource) string {
+	return filepath.Join(g.LocalDirectoryPath, strings.ToLower(strings.Split(group, ".")[0]), version, strings.ToLower(cfg.Kind)+".md")
+}
+
+// Generate writes the API reference page of the given resource using the
+// example manifest at the given path, if it exists.
+func (g *APIReferenceGenerator) Generate(ref *resourceReference, examplePath string) error {
+	if data, err := os.ReadFile(filepath.Clean(examplePath)); err == nil {
+		ref.Example = strings.TrimSpace(string(data))
+	}
+	return errors.Wrap(writeMarkdown(templates.APIReferenceTemplate, g.FilePath(ref.Group, ref.Version, ref.resource), ref),
+		"cannot write the API reference page")
+}
+
+// GenerateIndex writes the index page of the given API group listing the
+// given resources.
+func (g *APIReferenceGenerator) GenerateIndex(group string, resources []*config.Resource) error {
+	type indexEntry struct {
+		Kind          string
+		Version       string
+		TerraformName string
+		Description   string
+		Link          string
+	}
+	entries := make([]indexEntry, 0, len(resources))
+	for _, r := range resources {
+		entries = append(entries, indexEntry{
+			Kind:          r.Kind,
+			Version:       r.Version,
+			TerraformName: r.Name,
+			Description:   tableCell(firstSentence(resourceDescription(r))),
+			Link:          fmt.Sprintf("%s/%s.md", r.Version, strings.ToLower(r.Kind)),
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Kind != entries[j].Kind {
+			return entries[i].Kind < entries[j].Kind
+		}
+		return entries[i].Version < entries[j].Version
+	})
+	path := filepath.Join(g.LocalDirectoryPath, strings.ToLower(strings.Split(group, ".")[0]), "index.md")
+	return errors.Wrap(writeMarkdown(templates.APIReferenceIndexTemplate, path, map[string]any{
+		"GenStatement": markdownGenStatement,
+		"Group":        group,
+		"Resources":    entries,
+	}), "cannot write the API reference index page")
+}
+
+func writeMarkdown(tmpl, path string, data any) error {
+	t, err := template.New(filepath.Base(path)).Parse(tmpl)
+	if err != nil {
+		return errors.Wrap(err, "cannot parse the template")
+	}
+	buff := &bytes.Buffer{}
+	if err := t.Execute(buff, data); err != nil {
+		return errors.Wrap(err, "cannot execute the template")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return errors.Wrap(err, "cannot mkdir directory of the file")
+	}
+	return errors.Wrap(os.WriteFile(path, buff.Bytes(), 0600), "cannot write file")
+}
+
+// newResourceReference builds the API reference of the given resource from
+// its generated types.
+func newResourceReference(group, version string, cfg *config.Resource, gen *tjtypes.Generated) *resourceReference {
+	ref := &resourceReference{
+		GenStatement:  markdownGenStatement,
+		Group:         group,
+		Version:       version,
+		Kind:          cfg.Kind,
+		TerraformName: cfg.Name,
+		DataSource:    cfg.DataSource,
+		Description:   resourceDescription(cfg),
+		ExternalName:  externalNameDescription(cfg),
+		resource:      cfg,
+	}
+	if cfg.MetaResource != nil {
+		ref.ImportStatements = cfg.MetaResource.ImportStatements
+	}
+	required := map[string]bool{}
+	for _, m := range reRequiredParameter.FindAllStringSubmatch(gen.ValidationRules, -1) {
+		required[m[1]] = true
+	}
+	w := &fieldWalker{comments: gen.Comments, schema: cfg.TerraformResource}
+	// The arguments of the data sources, e.g. filters, are documented under
+	// spec.forProvider but they are not created, hence no spec.initProvider.
+	ref.Sections = append(ref.Sections, referenceSection{Name: "spec.forProvider", Fields: w.walk(gen.ForProviderType, "", "", required)})
+	if !cfg.DataSource {
+		ref.Sections = append(ref.Sections, referenceSection{Name: "spec.initProvider", Fields: w.walk(gen.InitProviderType, "", "", nil)})
+	}
+	ref.Sections = append(ref.Sections, referenceSection{Name: "status.atProvider", Fields: w.walk(gen.AtProviderType, "", "", nil)})
+	for _, f := range sortedKeys(cfg.References) {
+		target := cfg.References[f].TerraformName
+		if target == "" {
+			target = cfg.References[f].Type
+		}
+		ref.References = append(ref.References, referenceTarget{Field: crdFieldPath(f), Target: target})
+	}
+	return ref
+}
+
+// fieldWalker builds the field trees of the generated types.
+type fieldWalker struct {
+	comments twtypes.Comments
+	schema   *schema.Resource
+}
+
+// walk returns the fields of the given struct type and its nested types.
+// The top-level fields are required if they are in the given set, i.e. if
+// the CRD requires them. The nested fields are required if they are required
+// in the Terraform schema.
+func (w *fieldWalker) walk(t types.Type, prefix, tfPrefix string, required map[string]bool) []referenceField {
+	n, ok := deref(t).(*types.Named)
+	if !ok {
+		return nil
+	}
+	s, ok := n.Underlying().(*types.Struct)
+	if !ok {
+		return nil
+	}
+	var fields []referenceField
+	for i := 0; i < s.NumFields(); i++ {
+		tag := reflect.StructTag(s.Tag(i))
+		jsonName := strings.Split(tag.Get("json"), ",")[0]
+		if jsonName == "" || jsonName == "-" {
+			continue
+		}
+		tfPath := tfPrefix + strings.Split(tag.Get("tf"), ",")[0]
+		req := required[jsonName]
+		if tfPrefix != "" && w.schema != nil {
+			if sch := config.GetSchema(w.schema, tfPath); sch != nil {
+				req = sch.Required
+			}
+		}
+		typeName, nested, sensitive := describeType(s.Field(i).Type())
+		fields = append(fields, referenceField{
+			Path:        prefix + jsonName,
+			Type:        typeName,
+			Required:    req,
+			Sensitive:   sensitive,
+			Description: tableCell(fieldDescription(w.comments[twtypes.QualifiedFieldPath(n.Obj(), s.Field(i).Name())])),
+		})
+		if nested != nil {
+			sep := "."
+			if strings.HasPrefix(typeName, "[]") {
+				sep = "[]."
+			}
+			fields = append(fields, w.walk(nested, prefix+jsonName+sep, tfPath+".", nil)...)
+		}
+	}
+	return fields
+}
+
+// describeType returns the documented name of the given field type, the
+// nested generated type to be walked, if any, and whether it's a secret
+// reference.
+func describeType(t types.Type) (string, types.Type, bool) {
+	switch tt := deref(t).(type) {
+	case *types.Slice:
+		n, nested, sensitive := describeType(tt.Elem())
+		return "[]" + n, nested, sensitive
+	case *types.Map:
+		n, nested, sensitive := describeType(tt.Elem())
+		return "map[string]" + n, nested, sensitive
+	case *types.Named:
+		if tt.Obj().Pkg() != nil && tt.Obj().Pkg().Path() == tjtypes.PackagePathXPCommonAPIs {
+			n := tt.Obj().Name()
+			return n, nil, n == "SecretKeySelector" || n == "SecretReference"
+		}
+		if _, ok := tt.Underlying().(*types.Struct); ok {
+			return "object", tt, false
+		}
+		return describeType(tt.Underlying())
+	case *types.Basic:
+		if n := scalarColumnType(tt); n != "" {
+			return n, nil, false
+		}
+		return tt.Name(), nil, false
+	}
+	return t.String(), nil, false
+}
+
+// fieldDescription returns the description in the given generated field
+// comment without the markers.
+func fieldDescription(comment string) string {
+	var lines []string
+	for _, l := range strings.Split(comment, "\n") {
+		l = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(l), "//"))
+		if l != "" && !strings.HasPrefix(l, "+") {
+			lines = append(lines, l)
+		}
+	}
+	return strings.Join(lines, " ")
+}
+
+func resourceDescription(cfg *config.Resource) string {
+	if cfg.MetaResource == nil {
+		return ""
+	}
+	return tjpkg.FilterDescription(cfg.MetaResource.Description, tjpkg.TerraformKeyword)
+}
+
+func externalNameDescription(cfg *config.Resource) string {
+	var sb strings.Builder
+	if cfg.ExternalName.DisableNameInitializer {
+		sb.WriteString("The external name is not initialized from `metadata.name`. It's set once the resource is created, or it can be set via the `crossplane.io/external-name` annotation to import an existing resource.")
+	} else {
+		sb.WriteString("The external name defaults to `metadata.name`. It can be set via the `crossplane.io/external-name` annotation to import an existing resource.")
+	}
+	if len(cfg.ExternalName.IdentifierFields) != 0 {
+		paths := make([]string, len(cfg.ExternalName.IdentifierFields))
+		for i, f := range cfg.ExternalName.IdentifierFields {
+			paths[i] = "`spec.forProvider." + crdFieldPath(f) + "`"
+		}
+		fmt.Fprintf(&sb, " The Terraform ID is built from the external name and %s.", strings.Join(paths, ", "))
+	}
+	return sb.String()
+}
+
+// crdFieldPath converts the given Terraform field path to the corresponding
+// field path of the CRD.
+func crdFieldPath(tfPath string) string {
+	segments := strings.Split(tfPath, ".")
+	for i, s := range segments {
+		if s == "*" {
+			continue
+		}
+		segments[i] = name.NewFromSnake(s).LowerCamelComputed
+	}
+	return strings.Join(segments, ".")
+}
+
+func firstSentence(s string) string {
+	if i := strings.Index(s, ". "); i != -1 {
+		return s[:i+1]
+	}
+	return s
+}
+
+// tableCell escapes the given text for a Markdown table cell.
+func tableCell(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package pipeline
+
+import (
+	"go/types"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/upbound/upjet/pkg/config"
+	"github.com/upbound/upjet/pkg/registry"
+	tjtypes "github.com/upbound/upjet/pkg/types"
+)
+
+func TestAPIReference(t *testing.T) {
+	sch := &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"instance_type": {Type: schema.TypeString, Required: true, Description: "The type | of the instance."},
+			"password":      {Type: schema.TypeString, Optional: true, Sensitive: true},
+			"subnet_id":     {Type: schema.TypeString, Optional: true},
+			"arn":           {Type: schema.TypeString, Computed: true},
+			"rule": {Type: schema.TypeList, Optional: true, Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"name": {Type: schema.TypeString, Required: true},
+				},
+			}},
+		},
+	}
+	cfg := config.DefaultResource("test_instance", sch, &registry.Resource{
+		Description:      "Manages an instance. Has more details.",
+		ImportStatements: []string{"terraform import test_instance.example i-12345678"},
+	})
+	cfg.References["subnet_id"] = config.Reference{TerraformName: "test_subnet", Type: "Subnet"}
+	gen, err := tjtypes.NewBuilder(types.NewPackage("github.com/upbound/provider-test/apis/test/v1alpha1", "v1alpha1")).Build(cfg)
+	if err != nil {
+		t.Fatalf("cannot build the types: %s", err)
+	}
+
+	want := &resourceReference{
+		GenStatement:     markdownGenStatement,
+		Group:            "test.upbound.io",
+		Version:          "v1alpha1",
+		Kind:             "Instance",
+		TerraformName:    "test_instance",
+		Description:      "Manages an instance. Has more details.",
+		ExternalName:     "The external name defaults to `metadata.name`. It can be set via the `crossplane.io/external-name` annotation to import an existing resource.",
+		ImportStatements: []string{"terraform import test_instance.example i-12345678"},
+		Sections: []referenceSection{
+			{Name: "spec.forProvider", Fields: []referenceField{
+				{Path: "instanceType", Type: "string", Required: true, Description: `The type \| of the instance.`},
+				{Path: "passwordSecretRef", Type: "SecretKeySelector", Sensitive: true},
+				{Path: "rule", Type: "[]object"},
+				{Path: "rule[].name", Type: "string", Required: true},
+				{Path: "subnetId", Type: "string"},
+				{Path: "subnetIdRef", Type: "Reference", Description: "Reference to a Subnet to populate subnetId."},
+				{Path: "subnetIdSelector", Type: "Selector", Description: "Selector for a Subnet to populate subnetId."},
+			}},
+			{Name: "spec.initProvider", Fields: []referenceField{
+				{Path: "instanceType", Type: "string", Description: `The type \| of the instance.`},
+				{Path: "rule", Type: "[]object"},
+				{Path: "rule[].name", Type: "string", Required: true},
+			}},
+			{Name: "status.atProvider", Fields: []referenceField{
+				{Path: "arn", Type: "string"},
+				{Path: "instanceType", Type: "string", Description: `The type \| of the instance.`},
+				{Path: "rule", Type: "[]object"},
+				{Path: "rule[].name", Type: "string", Required: true},
+				{Path: "subnetId", Type: "string"},
+			}},
+		},
+		References: []referenceTarget{{Field: "subnetId", Target: "test_subnet"}},
+	}
+	got := newResourceReference("test.upbound.io", "v1alpha1", cfg, &gen)
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(resourceReference{}), cmpopts.IgnoreFields(resourceReference{}, "resource")); diff != "" {
+		t.Errorf("newResourceReference(...): -want, +got:\n%s", diff)
+	}
+
+	dir := t.TempDir()
+	g := NewAPIReferenceGenerator(dir)
+	example := dir + "/instance.yaml"
+	if err := os.WriteFile(example, []byte("kind: Instance\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Generate(got, example); err != nil {
+		t.Fatalf("Generate(...): %s", err)
+	}
+	page, err := os.ReadFile(g.FilePath("test.upbound.io", "v1alpha1", cfg))
+	if err != nil {
+		t.Fatalf("cannot read the API reference page: %s", err)
+	}
+	for _, s := range []string{
+		"# Instance\n",
+		"| `rule[].name` | string | Yes |  |\n",
+		"| `passwordSecretRef` | SecretKeySelector (sensitive) | No |  |\n",
+		"| `subnetId` | `test_subnet` |\n",
+		"```yaml\nkind: Instance\n```\n",
+	} {
+		if !strings.Contains(string(page), s) {
+			t.Errorf("Generate(...): the API reference page does not contain %q:\n%s", s, page)
+		}
+	}
+}
+
+func TestAPIReferenceDataSource(t *testing.T) {
+	sch := &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"filter": {Type: schema.TypeString, Optional: true},
+			"arn":    {Type: schema.TypeString, Computed: true},
+		},
+	}
+	cfg := config.DefaultDataSource("test_image", sch)
+	gen, err := tjtypes.NewBuilder(types.NewPackage("github.com/upbound/provider-test/apis/test/v1alpha1", "v1alpha1")).Build(cfg)
+	if err != nil {
+		t.Fatalf("cannot build the types: %s", err)
+	}
+
+	want := []referenceSection{
+		{Name: "spec.forProvider", Fields: []referenceField{
+			{Path: "filter", Type: "string"},
+		}},
+		{Name: "status.atProvider", Fields: []referenceField{
+			{Path: "arn", Type: "string"},
+			{Path: "filter", Type: "string"},
+		}},
+	}
+	got := newResourceReference("test.upbound.io", "v1alpha1", cfg, &gen)
+	if diff := cmp.Diff(want, got.Sections); diff != "" {
+		t.Errorf("newResourceReference(...): -want sections, +got sections:\n%s", diff)
+	}
+}
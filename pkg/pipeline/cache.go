@@ -0,0 +1,357 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/upbound/upjet/pkg/config"
+	"github.com/upbound/upjet/pkg/pipeline/templates"
+	tjtypes "github.com/upbound/upjet/pkg/types"
+)
+
+const (
+	upjetModulePath = "github.com/upbound/upjet"
+
+	errReadCache  = "cannot read the code generation cache"
+	errWriteCache = "cannot write the code generation cache"
+)
+
+// generationCache keeps the hashes of the inputs of the resources and the
+// API versions generated by a previous run together with the files generated
+// for them, so that the unchanged resources are not generated again.
+type generationCache struct {
+	// Key is the hash of the inputs shared by all the resources, such as
+	// the templates, the code generator and the provider-level
+	// configuration. The whole cache is invalidated if it changes.
+	Key string `json:"key"`
+	// Entries are the cache entries keyed by "<group>/<version>" for the
+	// files shared by the resources of an API version and by
+	// "<group>/<version>/<resource>" for the files of a resource.
+	Entries map[string]*cacheEntry `json:"entries"`
+	// Examples are the paths of the example manifests.
+	Examples []string `json:"examples,omitempty"`
+
+	path string
+}
+
+// cacheEntry is the cache entry of a resource or an API version.
+type cacheEntry struct {
+	// Hash is the hash of the inputs of the generated files, i.e. the
+	// effective configuration, the Terraform schema and the generated type
+	// names of a resource, or the hashes of all the resources of an API
+	// version.
+	Hash string `json:"hash"`
+	// Files are the paths of the generated files.
+	Files []string `json:"files"`
+}
+
+// resourceCacheKey returns the cache key of the given resource.
+func resourceCacheKey(group, version, name string) string {
+	return group + "/" + version + "/" + name
+}
+
+// loadGenerationCache reads the cache at the given path. A missing cache or a
+// cache with a different key yields an empty cache.
+func loadGenerationCache(path, key string) (*generationCache, error) {
+	c := &generationCache{
+		Key:     key,
+		Entries: map[string]*cacheEntry{},
+		path:    path,
+	}
+	data, err := os.ReadFile(filepath.Clean(path))
+	if errors.Is(err, iofs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, errReadCache)
+	}
+	stored := &generationCache{}
+	if err := json.Unmarshal(data, stored); err != nil {
+		return nil, errors.Wrap(err, errReadCache)
+	}
+	// The files generated by the previous run are still removed if they
+	// are not generated anymore, even if the cache has been invalidated.
+	if stored.Key != key {
+		for k, e := range stored.Entries {
+			c.Entries[k] = &cacheEntry{Files: e.Files}
+		}
+		c.Examples = stored.Examples
+		return c, nil
+	}
+	stored.path = path
+	if stored.Entries == nil {
+		stored.Entries = map[string]*cacheEntry{}
+	}
+	return stored, nil
+}
+
+// upToDate returns true if the entry with the given key has been generated
+// with the same hash and all the files of the given entry exist.
+func (c *generationCache) upToDate(key string, entry *cacheEntry) bool {
+	e, ok := c.Entries[key]
+	if !ok || e.Hash != entry.Hash {
+		return false
+	}
+	for _, f := range entry.Files {
+		if _, err := os.Stat(f); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// update records the generated files of the resources and the API versions
+// and removes the files that were generated by the previous run but not by
+// this one.
+func (c *generationCache) update(entries map[string]*cacheEntry, examples []string) error {
+	current := map[string]bool{}
+	for _, e := range entries {
+		for _, f := range e.Files {
+			current[f] = true
+		}
+	}
+	for _, f := range examples {
+		current[f] = true
+	}
+	var stale []string
+	for _, e := range c.Entries {
+		stale = append(stale, e.Files...)
+	}
+	stale = append(stale, c.Examples...)
+	for _, f := range stale {
+		if current[f] {
+			continue
+		}
+		if err := os.Remove(f); err != nil && !errors.Is(err, iofs.ErrNotExist) {
+			return errors.Wrapf(err, "cannot remove the stale generated file %s", f)
+		}
+		// Remove the directory as well if it was dedicated to the file, e.g.
+		// the package of a controller.
+		_ = os.Remove(filepath.Dir(f))
+	}
+	c.Entries = entries
+	c.Examples = examples
+	return nil
+}
+
+func (c *generationCache) store() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, errWriteCache)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0750); err != nil {
+		return errors.Wrap(err, errWriteCache)
+	}
+	return errors.Wrap(os.WriteFile(c.path, data, 0600), errWriteCache)
+}
+
+// generationKey returns the hash of the inputs that are shared by all the
+// resources of the provider. It returns false if the version of the code
+// generator cannot be determined, in which case the cache cannot be used.
+func generationKey(pc *config.Provider, rootDir string) (string, bool) {
+	// The generated files depend on the code generator itself, too.
+	generator, ok := generatorVersion(rootDir)
+	if !ok {
+		return "", false
+	}
+	h := sha256.New()
+	_, _ = io.WriteString(h, generator)
+	for _, t := range []string{templates.CRDTypesTemplate, templates.GroupVersionInfoTemplate,
+		templates.TerraformedTemplate, templates.ControllerTemplate, templates.APIReferenceTemplate,
+		pc.Templates.CRDTypes, pc.Templates.Terraformed, pc.Templates.Controller} {
+		_, _ = io.WriteString(h, t)
+	}
+	if header, err := os.ReadFile(filepath.Join(rootDir, "hack", "boilerplate.go.txt")); err == nil {
+		_, _ = h.Write(header)
+	}
+	_, _ = fmt.Fprintf(h, "%s|%s|%s|%s|%s", pc.ModulePath, pc.RootGroup, pc.ShortName, pc.FeaturesPackage, GenStatement)
+	return fmt.Sprintf("%x", h.Sum(nil)), true
+}
+
+// generatorVersion returns an identifier of the version of the code
+// generator, i.e. the Upjet module the running binary is built with. The
+// version of a module replaced with a local directory, e.g. "../upjet",
+// does not change with its sources, so the Go sources and the templates in
+// the directory are hashed instead. The relative directories are resolved
+// against the given root directory of the provider. It returns false if the
+// version cannot be determined, e.g. if Upjet is the main module.
+func generatorVersion(rootDir string) (string, bool) {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "", false
+	}
+	for _, d := range bi.Deps {
+		if d.Path != upjetModulePath {
+			continue
+		}
+		switch {
+		case d.Replace == nil:
+			return d.Version + d.Sum, true
+		case d.Replace.Version != "":
+			return d.Replace.Path + d.Replace.Version + d.Replace.Sum, true
+		}
+		dir := d.Replace.Path
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(rootDir, dir)
+		}
+		sum, err := hashSources(dir)
+		if err != nil {
+			return "", false
+		}
+		return sum, true
+	}
+	return "", false
+}
+
+// hashSources returns the hash of the Go sources and the templates in the
+// given directory tree, excluding the tests.
+func hashSources(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && (strings.HasPrefix(d.Name(), ".") || d.Name() == "testdata") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(path, "_test.go") || !strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, ".tmpl") {
+			return nil
+		}
+		data, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(h, "%s:%d:", filepath.ToSlash(strings.TrimPrefix(path, dir)), len(data))
+		_, _ = h.Write(data)
+		return nil
+	})
+	return fmt.Sprintf("%x", h.Sum(nil)), errors.Wrapf(err, "cannot hash the sources in %s", dir)
+}
+
+// resourceHash returns the hash of the effective configuration, the
+// Terraform schema and the names of the generated types of the given
+// resource. The functions in the configuration cannot be hashed, so only
+// whether they are set is taken into account. The type names are hashed
+// because they depend on the other resources of the API version.
+func resourceHash(key string, r *config.Resource, gen *tjtypes.Generated) string {
+	h := sha256.New()
+	_, _ = io.WriteString(h, key)
+	hashValue(h, reflect.ValueOf(r), map[uintptr]bool{})
+	if gen != nil {
+		for _, t := range gen.Types {
+			_, _ = io.WriteString(h, t.Obj().Name()+",")
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// versionHash returns the hash of the files shared by the resources of an
+// API version, given the hashes of the resources keyed by their names.
+func versionHash(key string, resourceHashes map[string]string) string {
+	h := sha256.New()
+	_, _ = io.WriteString(h, key)
+	for _, name := range sortedKeys(resourceHashes) {
+		_, _ = io.WriteString(h, name+"="+resourceHashes[name]+",")
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// hashValue writes a deterministic representation of the given value,
+// including its unexported fields, to the writer.
+func hashValue(w io.Writer, v reflect.Value, visiting map[uintptr]bool) { //nolint:gocyclo // a flat switch of the kinds
+	switch v.Kind() { //nolint:exhaustive // the rest of the kinds are not hashed
+	case reflect.Invalid:
+		_, _ = io.WriteString(w, "<nil>")
+	case reflect.Bool:
+		_, _ = io.WriteString(w, strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		_, _ = io.WriteString(w, strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		_, _ = io.WriteString(w, strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		_, _ = io.WriteString(w, strconv.FormatFloat(v.Float(), 'g', -1, 64))
+	case reflect.String:
+		_, _ = io.WriteString(w, strconv.Quote(v.String()))
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		_, _ = io.WriteString(w, strconv.FormatBool(v.IsNil()))
+	case reflect.Interface:
+		if v.IsNil() {
+			_, _ = io.WriteString(w, "<nil>")
+			return
+		}
+		_, _ = io.WriteString(w, v.Elem().Type().String())
+		hashValue(w, v.Elem(), visiting)
+	case reflect.Pointer:
+		if v.IsNil() {
+			_, _ = io.WriteString(w, "<nil>")
+			return
+		}
+		if visiting[v.Pointer()] {
+			_, _ = io.WriteString(w, "<cycle>")
+			return
+		}
+		visiting[v.Pointer()] = true
+		hashValue(w, v.Elem(), visiting)
+		delete(visiting, v.Pointer())
+	case reflect.Struct:
+		_, _ = io.WriteString(w, "{")
+		for i := 0; i < v.NumField(); i++ {
+			_, _ = io.WriteString(w, v.Type().Field(i).Name+":")
+			hashValue(w, v.Field(i), visiting)
+			_, _ = io.WriteString(w, ",")
+		}
+		_, _ = io.WriteString(w, "}")
+	case reflect.Slice, reflect.Array:
+		_, _ = fmt.Fprintf(w, "[%d:", v.Len())
+		for i := 0; i < v.Len(); i++ {
+			hashValue(w, v.Index(i), visiting)
+			_, _ = io.WriteString(w, ",")
+		}
+		_, _ = io.WriteString(w, "]")
+	case reflect.Map:
+		// The map entries are written in the order of the representations
+		// of their keys.
+		entries := make([][2]string, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			k, e := &hashBuffer{}, &hashBuffer{}
+			hashValue(k, iter.Key(), visiting)
+			hashValue(e, iter.Value(), visiting)
+			entries = append(entries, [2]string{string(*k), string(*e)})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i][0] < entries[j][0]
+		})
+		_, _ = fmt.Fprintf(w, "map[%d:", len(entries))
+		for _, e := range entries {
+			_, _ = io.WriteString(w, e[0]+"="+e[1]+",")
+		}
+		_, _ = io.WriteString(w, "]")
+	}
+}
+
+type hashBuffer []byte
+
+func (b *hashBuffer) Write(p []byte) (int, error) {
+	*b = append(*b, p...)
+	return len(p), nil
+}
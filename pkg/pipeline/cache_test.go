@@ -0,0 +1,163 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package pipeline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/upbound/upjet/pkg/config"
+)
+
+func testResource(opts ...config.ResourceOption) map[string]*config.Resource {
+	sch := &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"name": {Type: schema.TypeString, Required: true},
+			"tags": {Type: schema.TypeMap, Optional: true, Elem: &schema.Schema{Type: schema.TypeString}},
+		},
+	}
+	return map[string]*config.Resource{
+		"test_a": config.DefaultResource("test_a", sch, nil, opts...),
+	}
+}
+
+func TestResourceHash(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		a      map[string]*config.Resource
+		b      map[string]*config.Resource
+		equal  bool
+	}{
+		"Same": {
+			reason: "The hashes of the same configurations should be equal.",
+			a:      testResource(),
+			b:      testResource(),
+			equal:  true,
+		},
+		"FunctionsIgnored": {
+			reason: "The functions in the configurations should not change the hash as long as they are set.",
+			a:      testResource(),
+			b: testResource(func(r *config.Resource) {
+				r.ExternalName.GetIDFn = func(_ context.Context, _ string, _ map[string]any, _ map[string]any) (string, error) {
+					return "", nil
+				}
+			}),
+			equal: true,
+		},
+		"ConfigurationChange": {
+			reason: "A change in the configuration should change the hash.",
+			a:      testResource(),
+			b: testResource(func(r *config.Resource) {
+				r.LateInitializer.IgnoredFields = []string{"tags"}
+			}),
+		},
+		"SchemaChange": {
+			reason: "A change in the Terraform schema should change the hash.",
+			a:      testResource(),
+			b: testResource(func(r *config.Resource) {
+				r.TerraformResource.Schema["name"].Sensitive = true
+			}),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			equal := resourceHash("key", tc.a["test_a"], nil) == resourceHash("key", tc.b["test_a"], nil)
+			if diff := cmp.Diff(tc.equal, equal); diff != "" {
+				t.Errorf("\n%s\nresourceHash(...): -want equal, +got equal:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestGenerationCache(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache.json")
+	kept, stale := filepath.Join(dir, "zz_kept.go"), filepath.Join(dir, "stale", "zz_stale.go")
+	if err := os.MkdirAll(filepath.Dir(stale), 0750); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{kept, stale} {
+		if err := os.WriteFile(f, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := loadGenerationCache(path, "key")
+	if err != nil {
+		t.Fatalf("loadGenerationCache(...): %s", err)
+	}
+	if err := c.update(map[string]*cacheEntry{
+		"a/v1": {Hash: "h1", Files: []string{kept}},
+		"b/v1": {Hash: "h2", Files: []string{stale}},
+	}, nil); err != nil {
+		t.Fatalf("update(...): %s", err)
+	}
+	if err := c.store(); err != nil {
+		t.Fatalf("store(...): %s", err)
+	}
+
+	c, err = loadGenerationCache(path, "key")
+	if err != nil {
+		t.Fatalf("loadGenerationCache(...): %s", err)
+	}
+	if !c.upToDate("a/v1", &cacheEntry{Hash: "h1", Files: []string{kept}}) ||
+		c.upToDate("a/v1", &cacheEntry{Hash: "other", Files: []string{kept}}) ||
+		c.upToDate("a/v1", &cacheEntry{Hash: "h1", Files: []string{kept, filepath.Join(dir, "missing.md")}}) ||
+		c.upToDate("c/v1", &cacheEntry{Hash: "h1"}) {
+		t.Errorf("upToDate(...): unexpected result for the stored cache entries")
+	}
+	if err := c.update(map[string]*cacheEntry{
+		"a/v1": {Hash: "h1", Files: []string{kept}},
+	}, nil); err != nil {
+		t.Fatalf("update(...): %s", err)
+	}
+	if _, err := os.Stat(kept); err != nil {
+		t.Errorf("update(...): the file of a generated version should be kept: %s", err)
+	}
+	if _, err := os.Stat(filepath.Dir(stale)); !os.IsNotExist(err) {
+		t.Errorf("update(...): the stale file and its directory should be removed: %v", err)
+	}
+
+	c, err = loadGenerationCache(path, "other-key")
+	if err != nil {
+		t.Fatalf("loadGenerationCache(...): %s", err)
+	}
+	if c.upToDate("a/v1", &cacheEntry{Hash: "h1", Files: []string{kept}}) {
+		t.Errorf("loadGenerationCache(...): a cache with a different key should be invalidated")
+	}
+}
+
+func TestHashSources(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash := func() string {
+		h, err := hashSources(dir)
+		if err != nil {
+			t.Fatalf("hashSources(...): %s", err)
+		}
+		return h
+	}
+	write("a.go", "package a")
+	write("crd.go.tmpl", "package {{ .Package }}")
+	h := hash()
+	write("a_test.go", "package a")
+	write("README.md", "# a")
+	if hash() != h {
+		t.Errorf("hashSources(...): the tests and the other files should not change the hash")
+	}
+	write("crd.go.tmpl", "package {{ .CRD.APIVersion }}")
+	if hash() == h {
+		t.Errorf("hashSources(...): a change in a template should change the hash")
+	}
+}
@@ -5,7 +5,6 @@ Copyright 2021 Upbound Inc.
 package pipeline
 
 import (
-	"os"
 	"path/filepath"
 	"strings"
 
@@ -32,38 +31,55 @@ type ControllerGenerator struct {
 	ControllerGroupDir string
 	ModulePath         string
 	LicenseHeaderPath  string
+	// Template overrides the default controller template. The template of a
+	// resource configuration takes precedence over it.
+	Template string
+}
+
+// PackagePath returns the Go package path of the controller of the given
+// resource.
+func (cg *ControllerGenerator) PackagePath(cfg *config.Resource) string {
+	return filepath.Join(cg.ModulePath, "internal", "controller", strings.ToLower(strings.Split(cg.Group, ".")[0]), strings.ToLower(cfg.Kind))
+}
+
+// FilePath returns the path of the controller file of the given resource.
+func (cg *ControllerGenerator) FilePath(cfg *config.Resource) string {
+	return filepath.Join(cg.ControllerGroupDir, strings.ToLower(cfg.Kind), "zz_controller.go")
 }
 
 // Generate writes controller setup functions.
 func (cg *ControllerGenerator) Generate(cfg *config.Resource, typesPkgPath string, featuresPkgPath string) (pkgPath string, err error) {
-	controllerPkgPath := filepath.Join(cg.ModulePath, "internal", "controller", strings.ToLower(strings.Split(cg.Group, ".")[0]), strings.ToLower(cfg.Kind))
-	ctrlFile := wrapper.NewFile(controllerPkgPath, strings.ToLower(cfg.Kind), templates.ControllerTemplate,
+	controllerPkgPath := cg.PackagePath(cfg)
+	ctrlFile := wrapper.NewFile(controllerPkgPath, strings.ToLower(cfg.Kind), templateOrDefault(cfg.Templates.Controller, cg.Template, templates.ControllerTemplate),
 		wrapper.WithGenStatement(GenStatement),
 		wrapper.WithHeaderPath(cg.LicenseHeaderPath),
 	)
 
-	vars := map[string]any{
-		"Package": strings.ToLower(cfg.Kind),
-		"CRD": map[string]string{
-			"Kind": cfg.Kind,
+	vars := ControllerTemplateVars{
+		Package: strings.ToLower(cfg.Kind),
+		CRD: CRDVars{
+			Kind: cfg.Kind,
 		},
-		"DisableNameInitializer": cfg.ExternalName.DisableNameInitializer,
-		"TypePackageAlias":       ctrlFile.Imports.UsePackage(typesPkgPath),
-		"UseAsync":               cfg.UseAsync,
-		"ResourceType":           cfg.Name,
-		"Initializers":           cfg.InitializerFns,
+		DisableNameInitializer: cfg.ExternalName.DisableNameInitializer,
+		TypePackageAlias:       ctrlFile.Imports.UsePackage(typesPkgPath),
+		UseAsync:               cfg.UseAsync,
+		ResourceType:           cfg.Name,
+		Initializers:           cfg.InitializerFns,
+		ProviderResources:      "Resources",
+	}
+	if cfg.DataSource {
+		vars.ProviderResources = "DataSources"
 	}
 
 	// If the provider has a features package, add it to the controller template.
 	// This is to ensure we don't break existing providers that don't have a
 	// features package (yet).
 	if featuresPkgPath != "" {
-		vars["FeaturesPackageAlias"] = ctrlFile.Imports.UsePackage(featuresPkgPath)
+		vars.FeaturesPackageAlias = ctrlFile.Imports.UsePackage(featuresPkgPath)
 	}
 
-	filePath := filepath.Join(cg.ControllerGroupDir, strings.ToLower(cfg.Kind), "zz_controller.go")
 	return controllerPkgPath, errors.Wrap(
-		ctrlFile.Write(filePath, vars, os.ModePerm),
+		writeFormatted(ctrlFile, cg.FilePath(cfg), templateVars(vars)),
 		"cannot write controller file",
 	)
 }
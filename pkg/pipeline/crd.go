@@ -7,7 +7,6 @@ package pipeline
 import (
 	"fmt"
 	"go/types"
-	"os"
 	"path/filepath"
 	"strings"
 
@@ -27,6 +26,8 @@ const (
 	GenStatement = "// Code generated by upjet. DO NOT EDIT."
 
 	apiRoot = "apis"
+
+	errFmtOmittedFieldBlock = "cannot omit field %q: %q is not a block in the Terraform schema"
 )
 
 // NewCRDGenerator returns a new CRDGenerator.
@@ -47,19 +48,32 @@ type CRDGenerator struct {
 	Group              string
 	ProviderShortName  string
 	LicenseHeaderPath  string
-	Generated          *tjtypes.Generated
+	// Template overrides the default CRD types template. The template of a
+	// resource configuration takes precedence over it.
+	Template  string
+	Generated *tjtypes.Generated
 
 	pkg *types.Package
 }
 
 // Generate builds and writes a new CRD out of Terraform resource definition.
 func (cg *CRDGenerator) Generate(cfg *config.Resource) (string, error) {
-	file := wrapper.NewFile(cg.pkg.Path(), cg.pkg.Name(), templates.CRDTypesTemplate,
-		wrapper.WithGenStatement(GenStatement),
-		wrapper.WithHeaderPath(cg.LicenseHeaderPath),
-	)
+	paramTypeName, err := cg.Build(cfg)
+	if err != nil {
+		return "", err
+	}
+	return paramTypeName, cg.Write(cfg)
+}
 
-	deleteOmittedFields(cfg.TerraformResource.Schema, cfg.ExternalName.OmittedFields)
+// Build builds the types of the CRD of the given resource in the package of
+// the generator and stores them in Generated, without writing them. The
+// names of the types depend on the types built before in the same package,
+// so the resources of an API version must always be built in the same
+// order. It returns the name of the parameters type.
+func (cg *CRDGenerator) Build(cfg *config.Resource) (string, error) {
+	if err := deleteOmittedFields(cfg.TerraformResource.Schema, cfg.ExternalName.OmittedFields); err != nil {
+		return "", errors.Wrapf(err, "cannot delete the omitted fields of %s", cfg.Kind)
+	}
 	cfg.TerraformResource.Schema["id"] = &schema.Schema{
 		Type:     schema.TypeString,
 		Computed: true,
@@ -70,6 +84,17 @@ func (cg *CRDGenerator) Generate(cfg *config.Resource) (string, error) {
 		return "", errors.Wrapf(err, "cannot build types for %s", cfg.Kind)
 	}
 	cg.Generated = &gen
+	return gen.ForProviderType.Obj().Name(), nil
+}
+
+// Write writes the CRD types file of the given resource whose types have
+// just been built by Build.
+func (cg *CRDGenerator) Write(cfg *config.Resource) error {
+	gen := cg.Generated
+	file := wrapper.NewFile(cg.pkg.Path(), cg.pkg.Name(), templateOrDefault(cfg.Templates.CRDTypes, cg.Template, templates.CRDTypesTemplate),
+		wrapper.WithGenStatement(GenStatement),
+		wrapper.WithHeaderPath(cg.LicenseHeaderPath),
+	)
 
 	// TODO(muvaf): TypePrinter uses the given scope to see if the type exists
 	// before printing. We should ideally load the package in file system but
@@ -80,34 +105,48 @@ func (cg *CRDGenerator) Generate(cfg *config.Resource) (string, error) {
 	typePrinter := twtypes.NewPrinter(file.Imports, pkg.Scope(), twtypes.WithComments(gen.Comments))
 	typesStr, err := typePrinter.Print(gen.Types)
 	if err != nil {
-		return "", errors.Wrap(err, "cannot print the type list")
+		return errors.Wrap(err, "cannot print the type list")
+	}
+	columns, err := printerColumns(cfg, gen)
+	if err != nil {
+		return errors.Wrapf(err, "cannot build the printer columns for %s", cfg.Kind)
 	}
-	vars := map[string]any{
-		"Types": typesStr,
-		"CRD": map[string]string{
-			"APIVersion":       cfg.Version,
-			"Group":            cg.Group,
-			"Kind":             cfg.Kind,
-			"ForProviderType":  gen.ForProviderType.Obj().Name(),
-			"InitProviderType": gen.InitProviderType.Obj().Name(),
-			"AtProviderType":   gen.AtProviderType.Obj().Name(),
-			"ValidationRules":  gen.ValidationRules,
-			"Path":             cfg.Path,
+	vars := CRDTypesTemplateVars{
+		Types: typesStr,
+		CRD: CRDVars{
+			APIVersion:       cfg.Version,
+			Group:            cg.Group,
+			Kind:             cfg.Kind,
+			ForProviderType:  gen.ForProviderType.Obj().Name(),
+			InitProviderType: gen.InitProviderType.Obj().Name(),
+			AtProviderType:   gen.AtProviderType.Obj().Name(),
+			ValidationRules:  gen.ValidationRules,
+			Path:             cfg.Path,
+			PrinterColumns:   columns,
+			ShortNames:       cfg.ShortNames,
+			Categories:       cfg.Categories,
 		},
-		"Provider": map[string]string{
-			"ShortName": cg.ProviderShortName,
+		Provider: ProviderVars{
+			ShortName: cg.ProviderShortName,
 		},
-		"XPCommonAPIsPackageAlias": file.Imports.UsePackage(tjtypes.PackagePathXPCommonAPIs),
+		XPCommonAPIsPackageAlias: file.Imports.UsePackage(tjtypes.PackagePathXPCommonAPIs),
 	}
 	if cfg.MetaResource != nil {
 		// remove sentences with the `terraform` keyword in them
-		vars["CRD"].(map[string]string)["Description"] = tjpkg.FilterDescription(cfg.MetaResource.Description, tjpkg.TerraformKeyword)
+		vars.CRD.Description = tjpkg.FilterDescription(cfg.MetaResource.Description, tjpkg.TerraformKeyword)
 	}
-	filePath := filepath.Join(cg.LocalDirectoryPath, fmt.Sprintf("zz_%s_types.go", strings.ToLower(cfg.Kind)))
-	return gen.ForProviderType.Obj().Name(), errors.Wrap(file.Write(filePath, vars, os.ModePerm), "cannot write crd file")
+	return errors.Wrap(writeFormatted(file, cg.FilePath(cfg), templateVars(vars)), "cannot write crd file")
 }
 
-func deleteOmittedFields(sch map[string]*schema.Schema, omittedFields []string) {
+// FilePath returns the path of the CRD types file of the given resource.
+func (cg *CRDGenerator) FilePath(cfg *config.Resource) string {
+	return filepath.Join(cg.LocalDirectoryPath, fmt.Sprintf("zz_%s_types.go", strings.ToLower(cfg.Kind)))
+}
+
+// deleteOmittedFields deletes the given omitted fields from the schema. An
+// omitted leaf field that does not exist is ignored, but a field whose parent
+// is not a block in the schema is an error.
+func deleteOmittedFields(sch map[string]*schema.Schema, omittedFields []string) error {
 	for _, omit := range omittedFields {
 		fields := strings.Split(omit, ".")
 		current := sch
@@ -116,7 +155,15 @@ func deleteOmittedFields(sch map[string]*schema.Schema, omittedFields []string)
 				delete(current, f)
 				break
 			}
-			current = current[f].Elem.(*schema.Resource).Schema
+			var res *schema.Resource
+			if s := current[f]; s != nil {
+				res, _ = s.Elem.(*schema.Resource)
+			}
+			if res == nil {
+				return errors.Errorf(errFmtOmittedFieldBlock, omit, strings.Join(fields[:i+1], "."))
+			}
+			current = res.Schema
 		}
 	}
+	return nil
 }
@@ -7,7 +7,9 @@ package pipeline
 import (
 	"testing"
 
+	"github.com/crossplane/crossplane-runtime/pkg/test"
 	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
@@ -19,6 +21,7 @@ func TestDeleteOmittedFields(t *testing.T) {
 	}
 	type want struct {
 		sch map[string]*schema.Schema
+		err error
 	}
 
 	cases := map[string]struct {
@@ -149,11 +152,64 @@ func TestDeleteOmittedFields(t *testing.T) {
 				},
 			},
 		},
+		"UnknownParent": {
+			reason: "Should return an error if the parent of an omitted field does not exist.",
+			args: args{
+				sch: map[string]*schema.Schema{
+					"top_level_a": {},
+				},
+				omittedFields: []string{
+					"nosuch.field",
+				},
+			},
+			want: want{
+				sch: map[string]*schema.Schema{
+					"top_level_a": {},
+				},
+				err: errors.Errorf(errFmtOmittedFieldBlock, "nosuch.field", "nosuch"),
+			},
+		},
+		"ParentNotBlock": {
+			reason: "Should return an error if the parent of an omitted field is not a block.",
+			args: args{
+				sch: map[string]*schema.Schema{
+					"top_level_a": {
+						Elem: &schema.Resource{
+							Schema: map[string]*schema.Schema{
+								"down_one": {
+									Elem: &schema.Schema{Type: schema.TypeString},
+								},
+							},
+						},
+					},
+				},
+				omittedFields: []string{
+					"top_level_a.down_one.field",
+				},
+			},
+			want: want{
+				sch: map[string]*schema.Schema{
+					"top_level_a": {
+						Elem: &schema.Resource{
+							Schema: map[string]*schema.Schema{
+								"down_one": {
+									Elem: &schema.Schema{Type: schema.TypeString},
+								},
+							},
+						},
+					},
+				},
+				err: errors.Errorf(errFmtOmittedFieldBlock, "top_level_a.down_one.field", "top_level_a.down_one"),
+			},
+		},
 	}
 
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
-			deleteOmittedFields(tc.args.sch, tc.args.omittedFields)
+			err := deleteOmittedFields(tc.args.sch, tc.args.omittedFields)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ndeleteOmittedFields(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
 			if diff := cmp.Diff(tc.want.sch, tc.args.sch); diff != "" {
 				t.Errorf("\n%s\ndeleteOmittedFields(...): -want, +got:\n%s", tc.reason, diff)
 			}
@@ -0,0 +1,92 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Stage is a stage of the code generation pipelines.
+type Stage string
+
+const (
+	// StageValidation validates the provider configuration.
+	StageValidation Stage = "validation"
+	// StageReferences resolves the types of the cross resource references.
+	StageReferences Stage = "references"
+	// StageCRD generates the CRD types of a resource.
+	StageCRD Stage = "crd"
+	// StageController generates the controller of a resource.
+	StageController Stage = "controller"
+	// StageExample generates the example manifest of a resource.
+	StageExample Stage = "example"
+	// StageTerraformed generates the resource.Terraformed implementations of
+	// an API version.
+	StageTerraformed Stage = "terraformed"
+	// StageVersion generates the files of an API version.
+	StageVersion Stage = "version"
+	// StageStoreExamples writes the example manifests.
+	StageStoreExamples Stage = "store-examples"
+	// StageExampleValidation validates the example manifests against the
+	// CRD schemas.
+	StageExampleValidation Stage = "example-validation"
+	// StageRegister generates the API registration file.
+	StageRegister Stage = "register"
+	// StageSetup generates the controller setup files.
+	StageSetup Stage = "setup"
+	// StageAPIReference generates the API reference pages.
+	StageAPIReference Stage = "api-reference"
+	// StageCache reads and writes the incremental generation cache.
+	StageCache Stage = "cache"
+)
+
+// GenerationError is the failure of a code generation stage.
+type GenerationError struct {
+	// Stage is the failing stage.
+	Stage Stage
+	// Resource is the Terraform name of the resource whose generation has
+	// failed. It's empty for the stages that are not specific to a
+	// resource.
+	Resource string
+	// Group and Version are the API group and version being generated, if
+	// the failing stage is specific to an API version.
+	Group   string
+	Version string
+	// Err is the cause of the failure.
+	Err error
+}
+
+// Error returns the error message prefixed with the failing stage and the
+// resource or the API version.
+func (e *GenerationError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(string(e.Stage))
+	switch {
+	case e.Resource != "":
+		fmt.Fprintf(&sb, " [%s]", e.Resource)
+	case e.Group != "":
+		fmt.Fprintf(&sb, " [%s/%s]", e.Group, e.Version)
+	}
+	fmt.Fprintf(&sb, ": %s", e.Err)
+	return sb.String()
+}
+
+// Unwrap returns the cause of the failure.
+func (e *GenerationError) Unwrap() error {
+	return e.Err
+}
+
+// GenerationErrors are the aggregated failures of a code generation run.
+type GenerationErrors []*GenerationError
+
+// Error returns the messages of all the failures, one per line.
+func (e GenerationErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, ge := range e {
+		lines[i] = ge.Error()
+	}
+	return fmt.Sprintf("code generation failed with %d error(s):\n%s", len(e), strings.Join(lines, "\n"))
+}
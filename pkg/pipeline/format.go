@@ -0,0 +1,31 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/muvaf/typewriter/pkg/wrapper"
+	"github.com/pkg/errors"
+	"golang.org/x/tools/imports"
+)
+
+// writeFormatted renders the given file and writes it to the given path after
+// formatting it and fixing its imports in the same way goimports does.
+func writeFormatted(f *wrapper.File, path string, vars map[string]any) error {
+	data, err := f.Wrap(vars)
+	if err != nil {
+		return errors.Wrap(err, "cannot wrap file")
+	}
+	data, err = imports.Process(path, data, nil)
+	if err != nil {
+		return errors.Wrapf(err, "cannot format the generated file %s", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return errors.Wrap(err, "cannot mkdir directory of the file")
+	}
+	return errors.Wrap(os.WriteFile(path, data, os.ModePerm), "cannot write file")
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package pipeline
+
+import (
+	"go/types"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/upbound/upjet/pkg/config"
+	tjtypes "github.com/upbound/upjet/pkg/types"
+)
+
+const (
+	errFmtUnknownColumnField = "field path %q of printer column %q does not exist in the generated types"
+	errFmtColumnFieldType    = "field %q of printer column %q is not a string, a number or a boolean, the column type must be set"
+)
+
+var reIndexedSegment = regexp.MustCompile(`^([^\[\]]+)((?:\[\d+\])*)$`)
+
+// printerColumns resolves the printer columns of the given resource against
+// its generated types, inferring the types of the columns if not set.
+func printerColumns(cfg *config.Resource, gen *tjtypes.Generated) ([]PrinterColumnVars, error) {
+	roots := map[string]*types.Named{
+		"spec.forProvider.":  gen.ForProviderType,
+		"spec.initProvider.": gen.InitProviderType,
+		"status.atProvider.": gen.AtProviderType,
+	}
+	result := make([]PrinterColumnVars, 0, len(cfg.PrinterColumns))
+	for _, c := range cfg.PrinterColumns {
+		var t types.Type
+		for prefix, root := range roots {
+			if strings.HasPrefix(c.FieldPath, prefix) {
+				t = fieldType(root, strings.Split(strings.TrimPrefix(c.FieldPath, prefix), "."))
+				break
+			}
+		}
+		if t == nil {
+			return nil, errors.Errorf(errFmtUnknownColumnField, c.FieldPath, c.Name)
+		}
+		columnType := c.Type
+		if columnType == "" {
+			columnType = scalarColumnType(t)
+		}
+		if columnType == "" {
+			return nil, errors.Errorf(errFmtColumnFieldType, c.FieldPath, c.Name)
+		}
+		result = append(result, PrinterColumnVars{
+			Name:     c.Name,
+			Type:     columnType,
+			JSONPath: "." + c.FieldPath,
+			Priority: c.Priority,
+		})
+	}
+	return result, nil
+}
+
+// fieldType returns the type of the field at the given path of JSON field
+// names, optionally indexed, e.g. "rule[0]". It returns nil if there is no
+// such field.
+func fieldType(t types.Type, path []string) types.Type {
+	for _, seg := range path {
+		m := reIndexedSegment.FindStringSubmatch(seg)
+		if m == nil {
+			return nil
+		}
+		s, ok := deref(t).Underlying().(*types.Struct)
+		if !ok {
+			return nil
+		}
+		t = nil
+		for i := 0; i < s.NumFields(); i++ {
+			name := strings.Split(reflect.StructTag(s.Tag(i)).Get("json"), ",")[0]
+			if name == m[1] {
+				t = s.Field(i).Type()
+				break
+			}
+		}
+		if t == nil {
+			return nil
+		}
+		for range strings.Split(m[2], "]")[1:] {
+			sl, ok := deref(t).Underlying().(*types.Slice)
+			if !ok {
+				return nil
+			}
+			t = sl.Elem()
+		}
+	}
+	return t
+}
+
+// scalarColumnType returns the printer column type of the given field type,
+// or an empty string if it's not a scalar.
+func scalarColumnType(t types.Type) string {
+	b, ok := deref(t).Underlying().(*types.Basic)
+	if !ok {
+		return ""
+	}
+	switch {
+	case b.Info()&types.IsString != 0:
+		return config.PrinterColumnTypeString
+	case b.Info()&types.IsInteger != 0:
+		return config.PrinterColumnTypeInteger
+	case b.Info()&types.IsFloat != 0:
+		return config.PrinterColumnTypeNumber
+	case b.Info()&types.IsBoolean != 0:
+		return config.PrinterColumnTypeBoolean
+	}
+	return ""
+}
+
+func deref(t types.Type) types.Type {
+	if p, ok := t.(*types.Pointer); ok {
+		return p.Elem()
+	}
+	return t
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package pipeline
+
+import (
+	"go/types"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+
+	"github.com/upbound/upjet/pkg/config"
+	tjtypes "github.com/upbound/upjet/pkg/types"
+)
+
+func TestPrinterColumns(t *testing.T) {
+	sch := &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"instance_type": {Type: schema.TypeString, Required: true},
+			"size":          {Type: schema.TypeInt, Optional: true},
+			"arn":           {Type: schema.TypeString, Computed: true},
+			"tags":          {Type: schema.TypeMap, Optional: true, Elem: &schema.Schema{Type: schema.TypeString}},
+			"rule": {Type: schema.TypeList, Optional: true, Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"name": {Type: schema.TypeString, Optional: true},
+				},
+			}},
+		},
+	}
+	cases := map[string]struct {
+		reason  string
+		columns []config.PrinterColumn
+		want    []PrinterColumnVars
+		err     error
+	}{
+		"InferredTypes": {
+			reason: "The types of the columns should be inferred from the types of the scalar fields.",
+			columns: []config.PrinterColumn{
+				{Name: "INSTANCE-TYPE", FieldPath: "spec.forProvider.instanceType"},
+				{Name: "SIZE", FieldPath: "spec.initProvider.size", Priority: 1},
+				{Name: "ARN", FieldPath: "status.atProvider.arn"},
+				{Name: "RULE", FieldPath: "spec.forProvider.rule[0].name"},
+			},
+			want: []PrinterColumnVars{
+				{Name: "INSTANCE-TYPE", Type: "string", JSONPath: ".spec.forProvider.instanceType"},
+				{Name: "SIZE", Type: "integer", JSONPath: ".spec.initProvider.size", Priority: 1},
+				{Name: "ARN", Type: "string", JSONPath: ".status.atProvider.arn"},
+				{Name: "RULE", Type: "string", JSONPath: ".spec.forProvider.rule[0].name"},
+			},
+		},
+		"ExplicitType": {
+			reason: "A non-scalar field should be printed if the type of the column is set.",
+			columns: []config.PrinterColumn{
+				{Name: "TAGS", FieldPath: "spec.forProvider.tags", Type: config.PrinterColumnTypeString},
+			},
+			want: []PrinterColumnVars{
+				{Name: "TAGS", Type: "string", JSONPath: ".spec.forProvider.tags"},
+			},
+		},
+		"UnknownField": {
+			reason: "A field path that does not exist in the generated types should be reported.",
+			columns: []config.PrinterColumn{
+				{Name: "ARN", FieldPath: "spec.forProvider.arn"},
+			},
+			err: errors.Errorf(errFmtUnknownColumnField, "spec.forProvider.arn", "ARN"),
+		},
+		"NotIndexed": {
+			reason: "A field in a list should be addressed with an index.",
+			columns: []config.PrinterColumn{
+				{Name: "RULE", FieldPath: "spec.forProvider.rule.name"},
+			},
+			err: errors.Errorf(errFmtUnknownColumnField, "spec.forProvider.rule.name", "RULE"),
+		},
+		"NonScalar": {
+			reason: "The type of a column printing a non-scalar field should be set.",
+			columns: []config.PrinterColumn{
+				{Name: "TAGS", FieldPath: "spec.forProvider.tags"},
+			},
+			err: errors.Errorf(errFmtColumnFieldType, "spec.forProvider.tags", "TAGS"),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg := config.DefaultResource("test_a", sch, nil)
+			cfg.PrinterColumns = tc.columns
+			gen, err := tjtypes.NewBuilder(types.NewPackage("github.com/upbound/provider-test/apis/test/v1alpha1", "v1alpha1")).Build(cfg)
+			if err != nil {
+				t.Fatalf("cannot build the types: %s", err)
+			}
+			got, err := printerColumns(cfg, &gen)
+			if diff := cmp.Diff(tc.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nprinterColumns(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nprinterColumns(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -5,7 +5,6 @@ Copyright 2021 Upbound Inc.
 package pipeline
 
 import (
-	"os"
 	"path/filepath"
 	"sort"
 
@@ -47,5 +46,5 @@ func (rg *RegisterGenerator) Generate(versionPkgList []string) error {
 		"Aliases": aliases,
 	}
 	filePath := filepath.Join(rg.LocalDirectoryPath, "zz_register.go")
-	return errors.Wrap(registerFile.Write(filePath, vars, os.ModePerm), "cannot write register file")
+	return errors.Wrap(writeFormatted(registerFile, filePath, vars), "cannot write register file")
 }
@@ -6,12 +6,14 @@ package pipeline
 
 import (
 	"fmt"
-	"os/exec"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 
 	"github.com/upbound/upjet/pkg/config"
 	"github.com/upbound/upjet/pkg/examples"
@@ -22,17 +24,139 @@ type terraformedInput struct {
 	ParametersTypeName string
 }
 
-// Run runs the Upjet code generation pipelines.
-func Run(pc *config.Provider, rootDir string) { // nolint:gocyclo
+// RunOption configures the code generation pipelines.
+type RunOption func(*runOptions)
+
+type runOptions struct {
+	failOnValidationErrors bool
+	cachePath              string
+	parallelism            int
+	apiReference           bool
+	synthesizedExamples    bool
+	exampleSecretNamespace string
+	validateExamples       bool
+	pruneExamples          bool
+}
+
+// WithFailOnValidationErrors makes the code generation pipelines fail if the
+// provider configuration has any invalid resource configuration reported by
+// config.Provider.Validate. By default, the report is only printed.
+func WithFailOnValidationErrors() RunOption {
+	return func(o *runOptions) {
+		o.failOnValidationErrors = true
+	}
+}
+
+// WithGenerationCache enables incremental code generation using the cache
+// file at the given path, e.g. ".work/generation-cache.json". The resources
+// whose configurations and Terraform schemas have not changed since the
+// previous run are not generated again and the files of the removed
+// resources are deleted.
+// The functions in the resource configurations, e.g. the external name
+// functions, are not taken into account while detecting the changes.
+func WithGenerationCache(path string) RunOption {
+	return func(o *runOptions) {
+		o.cachePath = path
+	}
+}
+
+// WithParallelism configures the number of the API groups generated in
+// parallel. Defaults to GOMAXPROCS.
+func WithParallelism(n int) RunOption {
+	return func(o *runOptions) {
+		o.parallelism = n
+	}
+}
+
+// WithAPIReference enables the generation of the Markdown API reference
+// pages of the managed resources under docs-generated, together with an
+// index page per API group.
+func WithAPIReference() RunOption {
+	return func(o *runOptions) {
+		o.apiReference = true
+	}
+}
+
+// WithSynthesizedExamples enables synthesizing a minimal example manifest
+// from the required fields of the Terraform schema for the resources that
+// have no example in the Terraform registry.
+func WithSynthesizedExamples() RunOption {
+	return func(o *runOptions) {
+		o.synthesizedExamples = true
+	}
+}
+
+// WithExampleSecretNamespace configures the namespace of the Secrets referred
+// by the sensitive fields in the example manifests. Defaults to
+// upbound-system.
+func WithExampleSecretNamespace(ns string) RunOption {
+	return func(o *runOptions) {
+		o.exampleSecretNamespace = ns
+	}
+}
+
+// WithExampleValidation enables validating the generated example manifests
+// against the OpenAPI schemas of the CRDs, built from the types generated by
+// the same run since the CRDs are generated by controller-gen after the code
+// generation pipelines. The invalid fields are reported like the invalid
+// resource configurations, see WithFailOnValidationErrors.
+func WithExampleValidation() RunOption {
+	return func(o *runOptions) {
+		o.validateExamples = true
+	}
+}
+
+// WithPruneInvalidExampleFields makes the example validation remove the
+// unknown fields and the fields with mismatching types from the example
+// manifests.
+func WithPruneInvalidExampleFields() RunOption {
+	return func(o *runOptions) {
+		o.pruneExamples = true
+	}
+}
+
+// Run runs the Upjet code generation pipelines. It panics on errors, see
+// RunE for an error-returning variant.
+func Run(pc *config.Provider, rootDir string, opts ...RunOption) {
+	if err := RunE(pc, rootDir, opts...); err != nil {
+		panic(err)
+	}
+}
+
+// RunE runs the Upjet code generation pipelines. The generation of the
+// other resources and API versions continues when a resource fails, and all
+// the failures are returned as GenerationErrors.
+func RunE(pc *config.Provider, rootDir string, opts ...RunOption) error { // nolint:gocyclo
 	// Note(turkenh): nolint reasoning - this is the main function of the code
 	// generation pipeline. We didn't want to split it into multiple functions
 	// for better readability considering the straightforward logic here.
+	o := &runOptions{}
+	for _, f := range opts {
+		f(o)
+	}
+	// The schemas are validated before the CRD generation removes the
+	// omitted fields from them.
+	if err := pc.Validate(); err != nil {
+		if o.failOnValidationErrors {
+			return GenerationErrors{{Stage: StageValidation, Err: err}}
+		}
+		fmt.Printf("WARNING: %s\n", err.Error())
+	}
 
 	// Group resources based on their Group and API Versions.
 	// An example entry in the tree would be:
 	// ec2.aws.upbound.io -> v1beta1 -> aws_vpc
 	resourcesGroups := map[string]map[string]map[string]*config.Resource{}
-	for name, resource := range pc.Resources {
+	// Data sources are keyed by their reference names so that they don't
+	// collide with the managed resources having the same Terraform name.
+	allResources := make(map[string]*config.Resource, len(pc.Resources)+len(pc.DataSources))
+	for name, r := range pc.Resources {
+		allResources[name] = r
+	}
+	for name, r := range pc.DataSources {
+		allResources[config.DataSourceReferencePrefix+name] = r
+	}
+	for name, resource := range allResources {
 		group := pc.RootGroup
 		if resource.ShortGroup != "" {
 			group = strings.ToLower(resource.ShortGroup) + "." + pc.RootGroup
@@ -46,9 +170,16 @@ func Run(pc *config.Provider, rootDir string) { // nolint:gocyclo
 		resourcesGroups[group][resource.Version][name] = resource
 	}
 
-	exampleGen := examples.NewGenerator(rootDir, pc.ModulePath, pc.ShortName, pc.Resources)
-	if err := exampleGen.SetReferenceTypes(pc.Resources); err != nil {
-		panic(errors.Wrap(err, "cannot set reference types for resources"))
+	var exampleOpts []examples.GeneratorOption
+	if o.synthesizedExamples {
+		exampleOpts = append(exampleOpts, examples.WithSynthesizedExamples())
+	}
+	if o.exampleSecretNamespace != "" {
+		exampleOpts = append(exampleOpts, examples.WithSecretNamespace(o.exampleSecretNamespace))
+	}
+	exampleGen := examples.NewGenerator(rootDir, pc.ModulePath, pc.ShortName, pc.Resources, exampleOpts...)
+	if err := exampleGen.SetReferenceTypes(allResources); err != nil {
+		return GenerationErrors{{Stage: StageReferences, Err: errors.Wrap(err, "cannot set reference types for resources")}}
 	}
 	// Add ProviderConfig API package to the list of API version packages.
 	apiVersionPkgList := make([]string, 0)
@@ -87,82 +218,300 @@ func Run(pc *config.Provider, rootDir string) { // nolint:gocyclo
 			controllerPkgMap[config.PackageNameMonolith] = append(controllerPkgMap[config.PackageNameMonolith], path)
 		}
 	}
-	count := 0
-	for group, versions := range resourcesGroups {
-		for version, resources := range versions {
-			var tfResources []*terraformedInput
-			versionGen := NewVersionGenerator(rootDir, pc.ModulePath, group, version)
-			crdGen := NewCRDGenerator(versionGen.Package(), rootDir, pc.ShortName, group, version)
-			tfGen := NewTerraformedGenerator(versionGen.Package(), rootDir, group, version)
-			ctrlGen := NewControllerGenerator(rootDir, pc.ModulePath, group)
-
-			for _, name := range sortedResources(resources) {
-				paramTypeName, err := crdGen.Generate(resources[name])
-				if err != nil {
-					panic(errors.Wrapf(err, "cannot generate crd for resource %s", name))
-				}
-				tfResources = append(tfResources, &terraformedInput{
-					Resource:           resources[name],
-					ParametersTypeName: paramTypeName,
-				})
-
-				featuresPkgPath := ""
-				if pc.FeaturesPackage != "" {
-					featuresPkgPath = filepath.Join(pc.ModulePath, pc.FeaturesPackage)
-				}
-				ctrlPkgPath, err := ctrlGen.Generate(resources[name], versionGen.Package().Path(), featuresPkgPath)
-				if err != nil {
-					panic(errors.Wrapf(err, "cannot generate controller for resource %s", name))
+	var cache *generationCache
+	if o.cachePath != "" {
+		key, ok := generationKey(pc, rootDir)
+		if !ok {
+			fmt.Println("WARNING: the generation cache is disabled since the version of the code generator cannot be determined")
+		} else {
+			var err error
+			if cache, err = loadGenerationCache(o.cachePath, key); err != nil {
+				return GenerationErrors{{Stage: StageCache, Err: err}}
+			}
+		}
+	}
+	// The API groups are generated in parallel. The versions of a group are
+	// generated sequentially since they share the controller packages. The
+	// results are merged in order so that the output is deterministic.
+	groups := make([]string, 0, len(resourcesGroups))
+	for g := range resourcesGroups {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+	results := make([][]*versionResult, len(groups))
+	workers := o.parallelism
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	var wg sync.WaitGroup
+	queue := make(chan int)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for gi := range queue {
+				group := groups[gi]
+				versions := make([]string, 0, len(resourcesGroups[group]))
+				for v := range resourcesGroups[group] {
+					versions = append(versions, v)
 				}
-				sGroup := strings.Split(group, ".")[0]
-				controllerPkgMap[sGroup] = append(controllerPkgMap[sGroup], ctrlPkgPath)
-				controllerPkgMap[config.PackageNameMonolith] = append(controllerPkgMap[config.PackageNameMonolith], ctrlPkgPath)
-				if err := exampleGen.Generate(group, version, resources[name]); err != nil {
-					panic(errors.Wrapf(err, "cannot generate example manifest for resource %s", name))
+				sort.Strings(versions)
+				for _, version := range versions {
+					results[gi] = append(results[gi], generateVersion(pc, rootDir, group, version, resourcesGroups[group][version], cache, o.apiReference))
 				}
-				count++
 			}
+		}()
+	}
+	for gi := range groups {
+		queue <- gi
+	}
+	close(queue)
+	wg.Wait()
 
-			if err := tfGen.Generate(tfResources, version); err != nil {
-				panic(errors.Wrapf(err, "cannot generate terraformed for resource %s", group))
+	cacheEntries := map[string]*cacheEntry{}
+	var errs GenerationErrors
+	count, unchanged := 0, 0
+	for _, groupResults := range results {
+		for _, r := range groupResults {
+			errs = append(errs, r.errs...)
+			sGroup := strings.Split(r.group, ".")[0]
+			controllerPkgMap[sGroup] = append(controllerPkgMap[sGroup], r.controllerPkgs...)
+			controllerPkgMap[config.PackageNameMonolith] = append(controllerPkgMap[config.PackageNameMonolith], r.controllerPkgs...)
+			// The examples of the unchanged resources are still generated
+			// because the example manifests of the other resources may
+			// refer to them.
+			for _, res := range r.examples {
+				if err := exampleGen.Generate(r.group, r.version, res); err != nil {
+					errs = append(errs, &GenerationError{Stage: StageExample, Resource: res.Name, Group: r.group, Version: r.version, Err: errors.Wrapf(err, "cannot generate example manifest for resource %s", res.Name)})
+				}
 			}
-
-			if err := versionGen.Generate(); err != nil {
-				panic(errors.Wrap(err, "cannot generate version files"))
+			count += r.generated
+			unchanged += r.unchanged
+			if r.apiVersionPkg == "" {
+				continue
+			}
+			apiVersionPkgList = append(apiVersionPkgList, r.apiVersionPkg)
+			cacheEntries[r.group+"/"+r.version] = r.entry
+			for k, e := range r.entries {
+				cacheEntries[k] = e
 			}
-			apiVersionPkgList = append(apiVersionPkgList, versionGen.Package().Path())
 		}
 	}
 
+	// The files shared by all the API versions are not generated if any of
+	// them has failed.
+	if len(errs) != 0 {
+		return errs
+	}
+
 	if err := exampleGen.StoreExamples(); err != nil {
-		panic(errors.Wrapf(err, "cannot store examples"))
+		return GenerationErrors{{Stage: StageStoreExamples, Err: errors.Wrapf(err, "cannot store examples")}}
+	}
+	if o.validateExamples {
+		schemas := map[string]*extv1.JSONSchemaProps{}
+		for _, groupResults := range results {
+			for _, r := range groupResults {
+				for gvk, s := range r.schemas {
+					schemas[gvk] = s
+				}
+			}
+		}
+		if err := exampleGen.ValidateExamplesWithSchemas(schemas, o.pruneExamples); err != nil {
+			var ve examples.ValidationErrors
+			if !errors.As(err, &ve) || o.failOnValidationErrors {
+				return GenerationErrors{{Stage: StageExampleValidation, Err: err}}
+			}
+			fmt.Printf("WARNING: %s\n", err.Error())
+		}
+	}
+
+	// The API reference pages are generated after the example manifests
+	// are stored so that they can include them.
+	if o.apiReference {
+		refGen := NewAPIReferenceGenerator(rootDir)
+		for gi, groupResults := range results {
+			var groupResources []*config.Resource
+			for _, r := range groupResults {
+				for _, ref := range r.references {
+					if err := refGen.Generate(ref, exampleGen.ManifestPath(r.group, ref.resource)); err != nil {
+						errs = append(errs, &GenerationError{Stage: StageAPIReference, Resource: ref.TerraformName, Group: r.group, Version: r.version, Err: err})
+					}
+				}
+				for _, name := range sortedResources(resourcesGroups[r.group][r.version]) {
+					groupResources = append(groupResources, resourcesGroups[r.group][r.version][name])
+				}
+			}
+			if err := refGen.GenerateIndex(groups[gi], groupResources); err != nil {
+				errs = append(errs, &GenerationError{Stage: StageAPIReference, Group: groups[gi], Err: err})
+			}
+		}
+		if len(errs) != 0 {
+			return errs
+		}
 	}
 
 	if err := NewRegisterGenerator(rootDir, pc.ModulePath).Generate(apiVersionPkgList); err != nil {
-		panic(errors.Wrap(err, "cannot generate register file"))
+		return GenerationErrors{{Stage: StageRegister, Err: errors.Wrap(err, "cannot generate register file")}}
 	}
 	// Generate the provider,
 	// i.e. the setup function and optionally the provider's main program.
-	if err := NewProviderGenerator(rootDir, pc.ModulePath).Generate(controllerPkgMap, pc.MainTemplate); err != nil {
-		panic(errors.Wrap(err, "cannot generate setup file"))
+	providerGen := NewProviderGenerator(rootDir, pc.ModulePath)
+	providerGen.Template = pc.Templates.Setup
+	if err := providerGen.Generate(controllerPkgMap, pc.MainTemplate); err != nil {
+		return GenerationErrors{{Stage: StageSetup, Err: errors.Wrap(err, "cannot generate setup file")}}
+	}
+
+	if cache == nil {
+		fmt.Printf("\nGenerated %d resources!\n", count)
+		return nil
+	}
+
+	if err := cache.update(cacheEntries, exampleGen.ManifestPaths()); err != nil {
+		return GenerationErrors{{Stage: StageCache, Err: err}}
+	}
+	if err := cache.store(); err != nil {
+		return GenerationErrors{{Stage: StageCache, Err: err}}
 	}
+	fmt.Printf("\nGenerated %d resources, %d resources are unchanged!\n", count, unchanged)
+	return nil
+}
 
-	// NOTE(muvaf): gosec linter requires that the whole command is hard-coded.
-	// So, we set the directory of the command instead of passing in the directory
-	// as an argument to "find".
-	apisCmd := exec.Command("bash", "-c", "goimports -w $(find . -iname 'zz_*')")
-	apisCmd.Dir = filepath.Clean(filepath.Join(rootDir, "apis"))
-	if out, err := apisCmd.CombinedOutput(); err != nil {
-		panic(errors.Wrap(err, "cannot run goimports for apis folder: "+string(out)))
+// versionResult is the result of the generation of an API version.
+type versionResult struct {
+	group   string
+	version string
+	// apiVersionPkg is the package path of the API version. It's empty if
+	// the generation of the API version has failed.
+	apiVersionPkg  string
+	controllerPkgs []string
+	// examples are the resources whose example manifests are to be
+	// generated.
+	examples []*config.Resource
+	// references are the API references of the generated resources.
+	references []*resourceReference
+	// entry is the cache entry of the files shared by the resources of the
+	// API version and entries are the cache entries of the resources.
+	entry   *cacheEntry
+	entries map[string]*cacheEntry
+	// schemas are the OpenAPI schemas of the CRDs of the resources keyed by
+	// <group>/<version>/<kind>.
+	schemas   map[string]*extv1.JSONSchemaProps
+	generated int
+	unchanged int
+	errs      GenerationErrors
+}
+
+// generateVersion generates the files of an API version. The files of the
+// resources that the cache reports as up-to-date are not generated again,
+// and neither are the files shared by the resources of the API version if
+// all of them are up-to-date. The API references of the generated resources
+// are collected if apiReference is set.
+// A panic in a stage is recovered and reported as the failure of that stage,
+// so that it does not take down the other API groups generated in parallel.
+func generateVersion(pc *config.Provider, rootDir, group, version string, resources map[string]*config.Resource, cache *generationCache, apiReference bool) (r *versionResult) { //nolint:gocyclo // sequential stages of the generation
+	r = &versionResult{group: group, version: version, entries: map[string]*cacheEntry{}, schemas: map[string]*extv1.JSONSchemaProps{}}
+	stage, resource := StageCRD, ""
+	defer func() {
+		if p := recover(); p != nil {
+			r.apiVersionPkg = ""
+			r.errs = append(r.errs, &GenerationError{Stage: stage, Resource: resource, Group: group, Version: version, Err: errors.Errorf("panic: %v", p)})
+		}
+	}()
+	var tfResources []*terraformedInput
+	versionGen := NewVersionGenerator(rootDir, pc.ModulePath, group, version)
+	crdGen := NewCRDGenerator(versionGen.Package(), rootDir, pc.ShortName, group, version)
+	tfGen := NewTerraformedGenerator(versionGen.Package(), rootDir, group, version)
+	ctrlGen := NewControllerGenerator(rootDir, pc.ModulePath, group)
+	crdGen.Template = pc.Templates.CRDTypes
+	tfGen.Template = pc.Templates.Terraformed
+	ctrlGen.Template = pc.Templates.Controller
+	refGen := NewAPIReferenceGenerator(rootDir)
+	featuresPkgPath := ""
+	if pc.FeaturesPackage != "" {
+		featuresPkgPath = filepath.Join(pc.ModulePath, pc.FeaturesPackage)
 	}
 
-	internalCmd := exec.Command("bash", "-c", "goimports -w $(find . -iname 'zz_*')")
-	internalCmd.Dir = filepath.Clean(filepath.Join(rootDir, "internal"))
-	if out, err := internalCmd.CombinedOutput(); err != nil {
-		panic(errors.Wrap(err, "cannot run goimports for internal folder: "+string(out)))
+	resourceHashes := map[string]string{}
+	for _, name := range sortedResources(resources) {
+		stage, resource = StageCRD, name
+		// The types of all the resources are built, even if they are
+		// up-to-date, since the names of the types of a resource depend on
+		// the types of the resources built before it.
+		paramTypeName, err := crdGen.Build(resources[name])
+		if err != nil {
+			r.errs = append(r.errs, &GenerationError{Stage: StageCRD, Resource: name, Group: group, Version: version, Err: errors.Wrapf(err, "cannot generate crd for resource %s", name)})
+			continue
+		}
+		tfResources = append(tfResources, &terraformedInput{
+			Resource:           resources[name],
+			ParametersTypeName: paramTypeName,
+		})
+		r.schemas[fmt.Sprintf("%s/%s/%s", group, version, resources[name].Kind)] = crdSchema(crdGen.Generated)
+		if !resources[name].DataSource {
+			r.examples = append(r.examples, resources[name])
+		}
+
+		entry := &cacheEntry{Files: []string{crdGen.FilePath(resources[name]), ctrlGen.FilePath(resources[name])}}
+		if apiReference {
+			entry.Files = append(entry.Files, refGen.FilePath(group, version, resources[name]))
+		}
+		key := resourceCacheKey(group, version, name)
+		r.entries[key] = entry
+		// The reference types of the resources have already been set, so
+		// a change in a referenced kind changes the hash, too.
+		if cache != nil {
+			entry.Hash = resourceHash(cache.Key, resources[name], crdGen.Generated)
+			resourceHashes[name] = entry.Hash
+			if cache.upToDate(key, entry) {
+				r.controllerPkgs = append(r.controllerPkgs, ctrlGen.PackagePath(resources[name]))
+				r.unchanged++
+				continue
+			}
+		}
+
+		if err := crdGen.Write(resources[name]); err != nil {
+			r.errs = append(r.errs, &GenerationError{Stage: StageCRD, Resource: name, Group: group, Version: version, Err: errors.Wrapf(err, "cannot generate crd for resource %s", name)})
+			continue
+		}
+		if apiReference {
+			stage = StageAPIReference
+			r.references = append(r.references, newResourceReference(group, version, resources[name], crdGen.Generated))
+		}
+		stage = StageController
+		ctrlPkgPath, err := ctrlGen.Generate(resources[name], versionGen.Package().Path(), featuresPkgPath)
+		if err != nil {
+			r.errs = append(r.errs, &GenerationError{Stage: StageController, Resource: name, Group: group, Version: version, Err: errors.Wrapf(err, "cannot generate controller for resource %s", name)})
+			continue
+		}
+		r.controllerPkgs = append(r.controllerPkgs, ctrlPkgPath)
+		r.generated++
 	}
 
-	fmt.Printf("\nGenerated %d resources!\n", count)
+	resource = ""
+	r.entry = &cacheEntry{
+		Files: []string{tfGen.FilePath(), versionGen.FilePath()},
+	}
+	if cache != nil {
+		r.entry.Hash = versionHash(cache.Key, resourceHashes)
+	}
+	if cache != nil && len(r.errs) == 0 && cache.upToDate(group+"/"+version, r.entry) {
+		r.apiVersionPkg = versionGen.Package().Path()
+		return r
+	}
+
+	stage = StageTerraformed
+	if err := tfGen.Generate(tfResources, version); err != nil {
+		r.errs = append(r.errs, &GenerationError{Stage: StageTerraformed, Group: group, Version: version, Err: errors.Wrapf(err, "cannot generate terraformed for resource %s", group)})
+		return r
+	}
+
+	stage = StageVersion
+	if err := versionGen.Generate(); err != nil {
+		r.errs = append(r.errs, &GenerationError{Stage: StageVersion, Group: group, Version: version, Err: errors.Wrap(err, "cannot generate version files")})
+		return r
+	}
+	r.apiVersionPkg = versionGen.Package().Path()
+	return r
 }
 
 func sortedResources(m map[string]*config.Resource) []string {
@@ -0,0 +1,301 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"github.com/upbound/upjet/pkg/config"
+)
+
+const testProviderSchema = `{"format_version":"1.0","provider_schemas":{"registry.terraform.io/hashicorp/test":{"resource_schemas":{"test_a":{"version":0,"block":{"attributes":{"name":{"type":"string","required":true}}}}}}}}`
+
+const testTwoResourcesSchema = `{"format_version":"1.0","provider_schemas":{"registry.terraform.io/hashicorp/test":{"resource_schemas":{"test_a":{"version":0,"block":{"attributes":{"name":{"type":"string","required":true}}}},"test_b":{"version":0,"block":{"attributes":{"name":{"type":"string","required":true}}}}}}}}`
+
+func TestRunE(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		opts   []config.ProviderOption
+		want   error
+	}{
+		"ValidationFailure": {
+			reason: "Invalid resource configurations should fail the generation at the validation stage.",
+			opts: []config.ProviderOption{
+				config.WithDefaultResourceOptions(func(r *config.Resource) {
+					r.LateInitializer.IgnoredFields = []string{"missing"}
+				}),
+			},
+			want: GenerationErrors{{
+				Stage: StageValidation,
+				Err: config.ValidationErrors{{
+					Resource: "test_a",
+					Message:  `late-initialization ignored field "missing" does not exist in the Terraform schema`,
+				}},
+			}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			pc, err := config.NewProviderE([]byte(testProviderSchema), "test", "github.com/upbound/provider-test", nil, tc.opts...)
+			if err != nil {
+				t.Fatalf("cannot build the provider: %s", err)
+			}
+			err = RunE(pc, t.TempDir(), WithFailOnValidationErrors())
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nRunE(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			var ge GenerationErrors
+			if !errors.As(err, &ge) || ge[0].Stage != StageValidation {
+				t.Errorf("\n%s\nRunE(...): expected a GenerationErrors at the %s stage, got %T", tc.reason, StageValidation, err)
+			}
+		})
+	}
+}
+
+func TestGenerateVersion(t *testing.T) {
+	rootDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(rootDir, "hack"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(rootDir, "hack", "boilerplate.go.txt"), []byte("/*\nCopyright 2023 Upbound Inc.\n*/\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// The generation may modify the resource configurations, so a new
+	// provider is built for each run as in separate code generation runs.
+	newProvider := func(opts ...config.ProviderOption) (*config.Provider, map[string]*config.Resource) {
+		pc, err := config.NewProviderE([]byte(testTwoResourcesSchema), "test", "github.com/upbound/provider-test", nil, opts...)
+		if err != nil {
+			t.Fatalf("cannot build the provider: %s", err)
+		}
+		pc.ConfigureResources()
+		return pc, map[string]*config.Resource{"test_a": pc.Resources["test_a"], "test_b": pc.Resources["test_b"]}
+	}
+	pc, resources := newProvider()
+	group := resources["test_a"].ShortGroup + "." + pc.RootGroup
+	cache, err := loadGenerationCache(filepath.Join(rootDir, "cache.json"), "key")
+	if err != nil {
+		t.Fatalf("loadGenerationCache(...): %s", err)
+	}
+	updateCache := func(r *versionResult) {
+		entries := map[string]*cacheEntry{group + "/v1alpha1": r.entry}
+		for k, e := range r.entries {
+			entries[k] = e
+		}
+		if err := cache.update(entries, nil); err != nil {
+			t.Fatalf("update(...): %s", err)
+		}
+	}
+
+	r := generateVersion(pc, rootDir, group, "v1alpha1", resources, cache, false)
+	if len(r.errs) != 0 {
+		t.Fatalf("generateVersion(...): %s", r.errs)
+	}
+	if diff := cmp.Diff([]int{2, 0, 2}, []int{r.generated, r.unchanged, len(r.controllerPkgs)}); diff != "" {
+		t.Errorf("generateVersion(...): -want generated, unchanged, controllers, +got:\n%s", diff)
+	}
+	for _, e := range append([]*cacheEntry{r.entry}, r.entries[resourceCacheKey(group, "v1alpha1", "test_a")], r.entries[resourceCacheKey(group, "v1alpha1", "test_b")]) {
+		for _, f := range e.Files {
+			if _, err := os.Stat(f); err != nil {
+				t.Errorf("generateVersion(...): the generated file is missing: %s", err)
+			}
+		}
+	}
+	updateCache(r)
+
+	pc, resources = newProvider()
+	r = generateVersion(pc, rootDir, group, "v1alpha1", resources, cache, false)
+	if diff := cmp.Diff([]int{0, 2, 2}, []int{r.generated, r.unchanged, len(r.controllerPkgs)}); diff != "" {
+		t.Errorf("generateVersion(...): an up-to-date version should not be generated again: -want generated, unchanged, controllers, +got:\n%s", diff)
+	}
+	updateCache(r)
+
+	pc, resources = newProvider(config.WithDefaultResourceOptions(func(r *config.Resource) {
+		if r.Name == "test_b" {
+			r.LateInitializer.IgnoredFields = []string{"name"}
+		}
+	}))
+	r = generateVersion(pc, rootDir, group, "v1alpha1", resources, cache, false)
+	if diff := cmp.Diff([]int{1, 1, 2}, []int{r.generated, r.unchanged, len(r.controllerPkgs)}); diff != "" {
+		t.Errorf("generateVersion(...): only the changed resource should be generated again: -want generated, unchanged, controllers, +got:\n%s", diff)
+	}
+}
+
+func TestGenerateVersionTemplates(t *testing.T) {
+	rootDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(rootDir, "hack"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(rootDir, "hack", "boilerplate.go.txt"), []byte("/*\nCopyright 2023 Upbound Inc.\n*/\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	pc, err := config.NewProviderE([]byte(testProviderSchema), "test", "github.com/upbound/provider-test", nil,
+		config.WithTemplates(config.Templates{
+			CRDTypes:   "package {{ .CRD.APIVersion }}\n\nconst ProviderLevel = \"{{ .CRD.Kind }}\"\n",
+			Controller: "package {{ .Package }}\n\nconst Resource = \"{{ .ResourceType }}\"\n",
+		}))
+	if err != nil {
+		t.Fatalf("cannot build the provider: %s", err)
+	}
+	pc.ConfigureResources()
+	r := pc.Resources["test_a"]
+	r.Templates.CRDTypes = "package {{ .CRD.APIVersion }}\n\nconst ResourceLevel = \"{{ .Provider.ShortName }}\"\n"
+	crdGen := NewCRDGenerator(nil, rootDir, pc.ShortName, r.ShortGroup+"."+pc.RootGroup, r.Version)
+	ctrlGen := NewControllerGenerator(rootDir, pc.ModulePath, r.ShortGroup+"."+pc.RootGroup)
+
+	res := generateVersion(pc, rootDir, r.ShortGroup+"."+pc.RootGroup, r.Version, map[string]*config.Resource{"test_a": r}, nil, false)
+	if len(res.errs) != 0 {
+		t.Fatalf("generateVersion(...): %s", res.errs)
+	}
+	want := map[string]string{
+		crdGen.FilePath(r):  "package v1alpha1\n\nconst ResourceLevel = \"test\"\n",
+		ctrlGen.FilePath(r): "package a\n\nconst Resource = \"test_a\"\n",
+	}
+	for f, content := range want {
+		got, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatalf("cannot read the generated file: %s", err)
+		}
+		if diff := cmp.Diff(content, string(got)); diff != "" {
+			t.Errorf("generateVersion(...): %s: -want content, +got content:\n%s", f, diff)
+		}
+	}
+}
+
+func TestGenerateVersionUnknownOmittedField(t *testing.T) {
+	rootDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(rootDir, "hack"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(rootDir, "hack", "boilerplate.go.txt"), []byte("/*\nCopyright 2023 Upbound Inc.\n*/\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	pc, err := config.NewProviderE([]byte(testTwoResourcesSchema), "test", "github.com/upbound/provider-test", nil)
+	if err != nil {
+		t.Fatalf("cannot build the provider: %s", err)
+	}
+	pc.ConfigureResources()
+	pc.Resources["test_a"].ExternalName.OmittedFields = []string{"nosuch.field"}
+	group := pc.Resources["test_a"].ShortGroup + "." + pc.RootGroup
+
+	r := generateVersion(pc, rootDir, group, "v1alpha1", map[string]*config.Resource{"test_a": pc.Resources["test_a"], "test_b": pc.Resources["test_b"]}, nil, false)
+	want := GenerationErrors{{
+		Stage:    StageCRD,
+		Resource: "test_a",
+		Group:    group,
+		Version:  "v1alpha1",
+		Err:      errors.Wrap(errors.Wrap(errors.Errorf(errFmtOmittedFieldBlock, "nosuch.field", "nosuch"), "cannot delete the omitted fields of A"), "cannot generate crd for resource test_a"),
+	}}
+	if diff := cmp.Diff(want, r.errs, test.EquateErrors()); diff != "" {
+		t.Errorf("generateVersion(...): -want errors, +got errors:\n%s", diff)
+	}
+	if diff := cmp.Diff(1, r.generated); diff != "" {
+		t.Errorf("generateVersion(...): the other resources should still be generated: -want generated, +got:\n%s", diff)
+	}
+}
+
+func TestRunEParallel(t *testing.T) {
+	const schema = `{"format_version":"1.0","provider_schemas":{"registry.terraform.io/hashicorp/test":{"resource_schemas":{` +
+		`"test_a":{"version":0,"block":{"attributes":{"name":{"type":"string","required":true}}}},` +
+		`"test_b":{"version":0,"block":{"attributes":{"name":{"type":"string","required":true}}}},` +
+		`"test_c":{"version":0,"block":{"attributes":{"name":{"type":"string","required":true}}}},` +
+		`"test_d":{"version":0,"block":{"attributes":{"name":{"type":"string","required":true}}}}}}}}`
+	// run generates the provider in a new root directory and returns the
+	// directory together with the error of the generation.
+	run := func(configure func(pc *config.Provider)) (string, error) {
+		rootDir := t.TempDir()
+		if err := os.MkdirAll(filepath.Join(rootDir, "hack"), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(rootDir, "hack", "boilerplate.go.txt"), []byte("/*\nCopyright 2023 Upbound Inc.\n*/\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		pc, err := config.NewProviderE([]byte(schema), "test", "github.com/upbound/provider-test", nil)
+		if err != nil {
+			t.Fatalf("cannot build the provider: %s", err)
+		}
+		pc.ConfigureResources()
+		// Each resource is generated in its own API group.
+		for name, r := range pc.Resources {
+			r.ShortGroup = strings.TrimPrefix(name, "test_")
+		}
+		configure(pc)
+		return rootDir, RunE(pc, rootDir, WithParallelism(3))
+	}
+	read := func(rootDir string) map[string]string {
+		files := map[string]string{}
+		for _, f := range []string{filepath.Join("apis", "zz_register.go"), filepath.Join("internal", "controller", "zz_setup.go")} {
+			b, err := os.ReadFile(filepath.Join(rootDir, f))
+			if err != nil {
+				t.Fatalf("cannot read the generated file: %s", err)
+			}
+			files[f] = string(b)
+		}
+		return files
+	}
+
+	first, err := run(func(*config.Provider) {})
+	if err != nil {
+		t.Fatalf("RunE(...): %s", err)
+	}
+	got := read(first)
+	// The packages of the groups are listed in the order of the groups.
+	for f, pkgFmt := range map[string]string{
+		filepath.Join("apis", "zz_register.go"):                "provider-test/apis/%s/v1alpha1\"",
+		filepath.Join("internal", "controller", "zz_setup.go"): "provider-test/internal/controller/%s/%s\"",
+	} {
+		var indices []int
+		for _, g := range []string{"a", "b", "c", "d"} {
+			i := strings.Index(got[f], strings.ReplaceAll(pkgFmt, "%s", g))
+			if i == -1 {
+				t.Fatalf("RunE(...): %s: cannot find the package of group %s", f, g)
+			}
+			indices = append(indices, i)
+		}
+		if !sort.IntsAreSorted(indices) {
+			t.Errorf("RunE(...): %s: the packages of the groups are not sorted", f)
+		}
+	}
+	second, err := run(func(*config.Provider) {})
+	if err != nil {
+		t.Fatalf("RunE(...): %s", err)
+	}
+	if diff := cmp.Diff(got, read(second)); diff != "" {
+		t.Errorf("RunE(...): the output should be deterministic: -first, +second:\n%s", diff)
+	}
+
+	// A failing resource and a panicking resource in separate groups are
+	// reported for their own groups, in the order of the groups.
+	_, err = run(func(pc *config.Provider) {
+		pc.Resources["test_b"].ExternalName.OmittedFields = []string{"nosuch.field"}
+		pc.Resources["test_d"].TerraformResource = nil
+	})
+	var ge GenerationErrors
+	if !errors.As(err, &ge) {
+		t.Fatalf("RunE(...): expected GenerationErrors, got %v", err)
+	}
+	type groupStage struct {
+		Group, Resource string
+		Stage           Stage
+	}
+	var gotErrs []groupStage
+	for _, e := range ge {
+		gotErrs = append(gotErrs, groupStage{Group: e.Group, Resource: e.Resource, Stage: e.Stage})
+	}
+	wantErrs := []groupStage{
+		{Group: "b.test.upbound.io", Resource: "test_b", Stage: StageCRD},
+		{Group: "d.test.upbound.io", Resource: "test_d", Stage: StageCRD},
+	}
+	if diff := cmp.Diff(wantErrs, gotErrs); diff != "" {
+		t.Errorf("RunE(...): -want errors, +got errors:\n%s", diff)
+	}
+}
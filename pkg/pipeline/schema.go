@@ -0,0 +1,96 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package pipeline
+
+import (
+	"fmt"
+	"go/types"
+	"reflect"
+	"strings"
+
+	twtypes "github.com/muvaf/typewriter/pkg/types"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/utils/pointer"
+
+	tjtypes "github.com/upbound/upjet/pkg/types"
+)
+
+const (
+	markerRequired       = "+kubebuilder:validation:Required"
+	fmtRequiredParameter = "spec.forProvider.%s is a required parameter"
+)
+
+// crdSchema returns the OpenAPI schema of the spec of the CRD with the given
+// generated types, as controller-gen would generate it from them. It allows
+// validating the example manifests against the types generated in the same
+// run instead of the CRDs generated by a previous run. The fields of the
+// spec other than the parameters, e.g. providerConfigRef, are not validated.
+func crdSchema(gen *tjtypes.Generated) *extv1.JSONSchemaProps {
+	w := &schemaWalker{comments: gen.Comments}
+	spec := extv1.JSONSchemaProps{
+		Type: "object",
+		Properties: map[string]extv1.JSONSchemaProps{
+			"forProvider":  w.schema(gen.ForProviderType),
+			"initProvider": w.schema(gen.InitProviderType),
+		},
+		XPreserveUnknownFields: pointer.Bool(true),
+	}
+	for _, m := range reRequiredParameter.FindAllStringSubmatch(gen.ValidationRules, -1) {
+		spec.XValidations = append(spec.XValidations, extv1.ValidationRule{Message: fmt.Sprintf(fmtRequiredParameter, m[1])})
+	}
+	return &extv1.JSONSchemaProps{
+		Type:       "object",
+		Properties: map[string]extv1.JSONSchemaProps{"spec": spec},
+	}
+}
+
+// schemaWalker builds the OpenAPI schemas of the generated types.
+type schemaWalker struct {
+	comments twtypes.Comments
+}
+
+// schema returns the OpenAPI schema of the given field type. The types of
+// the Crossplane common APIs, e.g. the references and the selectors, are
+// only checked to be objects.
+func (w *schemaWalker) schema(t types.Type) extv1.JSONSchemaProps {
+	switch tt := deref(t).(type) {
+	case *types.Slice:
+		items := w.schema(tt.Elem())
+		return extv1.JSONSchemaProps{Type: "array", Items: &extv1.JSONSchemaPropsOrArray{Schema: &items}}
+	case *types.Map:
+		elem := w.schema(tt.Elem())
+		return extv1.JSONSchemaProps{Type: "object", AdditionalProperties: &extv1.JSONSchemaPropsOrBool{Allows: true, Schema: &elem}}
+	case *types.Named:
+		if tt.Obj().Pkg() != nil && tt.Obj().Pkg().Path() == tjtypes.PackagePathXPCommonAPIs {
+			return extv1.JSONSchemaProps{Type: "object", XPreserveUnknownFields: pointer.Bool(true)}
+		}
+		if s, ok := tt.Underlying().(*types.Struct); ok {
+			return w.object(tt, s)
+		}
+		return w.schema(tt.Underlying())
+	}
+	if st := scalarColumnType(t); st != "" {
+		return extv1.JSONSchemaProps{Type: st}
+	}
+	// The unknown types are not validated.
+	return extv1.JSONSchemaProps{XPreserveUnknownFields: pointer.Bool(true)}
+}
+
+// object returns the OpenAPI schema of the given generated struct type. The
+// fields with the required marker are required.
+func (w *schemaWalker) object(n *types.Named, s *types.Struct) extv1.JSONSchemaProps {
+	result := extv1.JSONSchemaProps{Type: "object", Properties: map[string]extv1.JSONSchemaProps{}}
+	for i := 0; i < s.NumFields(); i++ {
+		jsonName := strings.Split(reflect.StructTag(s.Tag(i)).Get("json"), ",")[0]
+		if jsonName == "" || jsonName == "-" {
+			continue
+		}
+		result.Properties[jsonName] = w.schema(s.Field(i).Type())
+		if strings.Contains(w.comments[twtypes.QualifiedFieldPath(n.Obj(), s.Field(i).Name())], markerRequired) {
+			result.Required = append(result.Required, jsonName)
+		}
+	}
+	return result
+}
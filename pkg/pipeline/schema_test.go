@@ -0,0 +1,64 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package pipeline
+
+import (
+	"go/types"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/utils/pointer"
+
+	"github.com/upbound/upjet/pkg/config"
+	tjtypes "github.com/upbound/upjet/pkg/types"
+)
+
+func TestCRDSchema(t *testing.T) {
+	sch := &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"instance_type": {Type: schema.TypeString, Required: true},
+			"password":      {Type: schema.TypeString, Optional: true, Sensitive: true},
+			"size":          {Type: schema.TypeFloat, Optional: true},
+			"tags":          {Type: schema.TypeMap, Optional: true, Elem: &schema.Schema{Type: schema.TypeString}},
+			"arn":           {Type: schema.TypeString, Computed: true},
+			"rule": {Type: schema.TypeList, Optional: true, Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"port": {Type: schema.TypeInt, Required: true},
+				},
+			}},
+		},
+	}
+	cfg := config.DefaultResource("test_instance", sch, nil)
+	gen, err := tjtypes.NewBuilder(types.NewPackage("github.com/upbound/provider-test/apis/test/v1alpha1", "v1alpha1")).Build(cfg)
+	if err != nil {
+		t.Fatalf("cannot build the types: %s", err)
+	}
+
+	got := crdSchema(&gen).Properties["spec"]
+	if diff := cmp.Diff(extv1.ValidationRules{{Message: "spec.forProvider.instanceType is a required parameter"}}, got.XValidations); diff != "" {
+		t.Errorf("crdSchema(...): -want validation rules, +got:\n%s", diff)
+	}
+	want := extv1.JSONSchemaProps{
+		Type: "object",
+		Properties: map[string]extv1.JSONSchemaProps{
+			"instanceType":      {Type: "string"},
+			"passwordSecretRef": {Type: "object", XPreserveUnknownFields: pointer.Bool(true)},
+			"size":              {Type: "number"},
+			"tags": {Type: "object", AdditionalProperties: &extv1.JSONSchemaPropsOrBool{
+				Allows: true,
+				Schema: &extv1.JSONSchemaProps{Type: "string"},
+			}},
+			"rule": {Type: "array", Items: &extv1.JSONSchemaPropsOrArray{Schema: &extv1.JSONSchemaProps{
+				Type:       "object",
+				Properties: map[string]extv1.JSONSchemaProps{"port": {Type: "integer"}},
+			}}},
+		},
+	}
+	if diff := cmp.Diff(want, got.Properties["forProvider"]); diff != "" {
+		t.Errorf("crdSchema(...): -want spec.forProvider, +got:\n%s", diff)
+	}
+}
@@ -35,6 +35,8 @@ type ProviderGenerator struct {
 	LocalDirectoryPath string
 	LicenseHeaderPath  string
 	ModulePath         string
+	// Template overrides the default controller setup template.
+	Template string
 }
 
 // Generate writes the setup file and the corresponding provider main file
@@ -85,7 +87,7 @@ func generateProviderMain(providerPath, group string, t *template.Template) erro
 }
 
 func (sg *ProviderGenerator) generate(group string, versionPkgList []string) error {
-	setupFile := wrapper.NewFile(filepath.Join(sg.ModulePath, "apis"), "apis", templates.SetupTemplate,
+	setupFile := wrapper.NewFile(filepath.Join(sg.ModulePath, "apis"), "apis", templateOrDefault(sg.Template, templates.SetupTemplate),
 		wrapper.WithGenStatement(GenStatement),
 		wrapper.WithHeaderPath(sg.LicenseHeaderPath),
 	)
@@ -98,9 +100,9 @@ func (sg *ProviderGenerator) generate(group string, versionPkgList []string) err
 	if len(group) != 0 {
 		g = "_" + group
 	}
-	vars := map[string]any{
-		"Aliases": aliases,
-		"Group":   g,
+	vars := SetupTemplateVars{
+		Aliases: aliases,
+		Group:   g,
 	}
 	filePath := ""
 	if len(group) == 0 {
@@ -108,5 +110,5 @@ func (sg *ProviderGenerator) generate(group string, versionPkgList []string) err
 	} else {
 		filePath = filepath.Join(sg.LocalDirectoryPath, fmt.Sprintf("zz_%s_setup.go", group))
 	}
-	return errors.Wrap(setupFile.Write(filePath, vars, os.ModePerm), "cannot write setup file")
+	return errors.Wrap(writeFormatted(setupFile, filePath, templateVars(vars)), "cannot write setup file")
 }
@@ -0,0 +1,190 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package pipeline
+
+import (
+	"reflect"
+
+	"github.com/upbound/upjet/pkg/config"
+)
+
+// CRDTypesTemplateVars are the variables the CRD types template is executed
+// with.
+type CRDTypesTemplateVars struct {
+	// Types are the printed Go types of the parameters and the observation of
+	// the resource.
+	Types string
+	// CRD is the information of the generated CRD.
+	CRD CRDVars
+	// Provider is the information of the provider.
+	Provider ProviderVars
+	// XPCommonAPIsPackageAlias is the import alias of the Crossplane common
+	// APIs package, including the trailing dot.
+	XPCommonAPIsPackageAlias string
+}
+
+// CRDVars are the template variables of a generated CRD.
+type CRDVars struct {
+	// APIVersion is the API version of the CRD, which is also the name of
+	// its Go package, e.g. v1beta1.
+	APIVersion string
+	// Group is the API group of the CRD.
+	Group string
+	// Kind is the kind of the CRD.
+	Kind string
+	// Description is the description of the Terraform resource.
+	Description string
+	// ForProviderType, InitProviderType and AtProviderType are the names of
+	// the types of the spec.forProvider, spec.initProvider and
+	// status.atProvider fields.
+	ForProviderType  string
+	InitProviderType string
+	AtProviderType   string
+	// ValidationRules are the kubebuilder validation markers of the CRD.
+	ValidationRules string
+	// Path is the resource path of the CRD, if overridden.
+	Path string
+	// PrinterColumns are the additional printer columns of the CRD.
+	PrinterColumns []PrinterColumnVars
+	// ShortNames are the short names of the CRD.
+	ShortNames []string
+	// Categories are the categories of the CRD in addition to the default
+	// ones.
+	Categories []string
+}
+
+// PrinterColumnVars are the template variables of a printer column.
+type PrinterColumnVars struct {
+	// Name is the name of the column.
+	Name string
+	// Type is the type of the column.
+	Type string
+	// JSONPath is the JSON path of the printed field, e.g.
+	// ".status.atProvider.arn".
+	JSONPath string
+	// Priority is the priority of the column.
+	Priority int
+}
+
+// ProviderVars are the template variables of the provider.
+type ProviderVars struct {
+	// ShortName is the short name of the provider, e.g. aws.
+	ShortName string
+}
+
+// ControllerTemplateVars are the variables the controller template is
+// executed with.
+type ControllerTemplateVars struct {
+	// Package is the name of the controller package.
+	Package string
+	// CRD is the information of the reconciled CRD. Only its Kind is set.
+	CRD CRDVars
+	// DisableNameInitializer is set if the name initializer is disabled for
+	// the resource.
+	DisableNameInitializer bool
+	// TypePackageAlias is the import alias of the API version package,
+	// including the trailing dot.
+	TypePackageAlias string
+	// UseAsync is set if the resource is reconciled asynchronously.
+	UseAsync bool
+	// ResourceType is the Terraform resource type, e.g. aws_vpc.
+	ResourceType string
+	// Initializers are the initializer functions of the resource.
+	Initializers []config.NewInitializerFn
+	// ProviderResources is the name of the config.Provider field that
+	// holds the resource configuration, i.e. Resources or DataSources.
+	ProviderResources string
+	// FeaturesPackageAlias is the import alias of the features package of
+	// the provider, including the trailing dot. It's empty if the provider
+	// does not have a features package.
+	FeaturesPackageAlias string
+}
+
+// TerraformedTemplateVars are the variables the terraformed template is
+// executed with.
+type TerraformedTemplateVars struct {
+	// APIVersion is the API version, which is also the name of its Go
+	// package.
+	APIVersion string
+	// Resources are the resources of the API version.
+	Resources []TerraformedResourceVars
+}
+
+// TerraformedResourceVars are the terraformed template variables of a
+// resource.
+type TerraformedResourceVars struct {
+	// CRD is the information of the CRD. Its Kind and ParametersTypeName are
+	// set.
+	CRD TerraformedCRDVars
+	// Terraform is the information of the Terraform resource.
+	Terraform TerraformVars
+	// Sensitive is the information of the sensitive fields.
+	Sensitive SensitiveVars
+	// LateInitializer is the late-initialization configuration.
+	LateInitializer LateInitializerVars
+}
+
+// TerraformedCRDVars are the terraformed template variables of a CRD.
+type TerraformedCRDVars struct {
+	// Kind is the kind of the CRD.
+	Kind string
+	// ParametersTypeName is the name of the type of the spec.forProvider
+	// field.
+	ParametersTypeName string
+}
+
+// TerraformVars are the template variables of a Terraform resource.
+type TerraformVars struct {
+	// ResourceType is the Terraform resource type, e.g. aws_vpc.
+	ResourceType string
+	// SchemaVersion is the version of the Terraform resource schema.
+	SchemaVersion int
+}
+
+// SensitiveVars are the template variables of the sensitive fields.
+type SensitiveVars struct {
+	// Fields maps the Terraform field paths of the sensitive fields to their
+	// paths in the managed resource.
+	Fields map[string]string
+}
+
+// LateInitializerVars are the template variables of the late-initialization
+// configuration.
+type LateInitializerVars struct {
+	// IgnoredFields are the canonical paths of the fields not to be
+	// late-initialized.
+	IgnoredFields []string
+}
+
+// SetupTemplateVars are the variables the setup template is executed with.
+type SetupTemplateVars struct {
+	// Aliases are the import aliases of the controller packages, including
+	// the trailing dots.
+	Aliases []string
+	// Group is the suffix of the setup function, e.g. "_ec2", or empty for
+	// the setup function of the monolithic provider.
+	Group string
+}
+
+// templateVars converts the given template variables struct to the map the
+// file wrapper expects. The top-level fields are the keys of the map.
+func templateVars(v any) map[string]any {
+	rv := reflect.ValueOf(v)
+	vars := make(map[string]any, rv.NumField())
+	for i := 0; i < rv.NumField(); i++ {
+		vars[rv.Type().Field(i).Name] = rv.Field(i).Interface()
+	}
+	return vars
+}
+
+// templateOrDefault returns the first non-empty template.
+func templateOrDefault(candidates ...string) string {
+	for _, t := range candidates {
+		if t != "" {
+			return t
+		}
+	}
+	return ""
+}
@@ -36,3 +36,15 @@ var RegisterTemplate string
 //
 //go:embed setup.go.tmpl
 var SetupTemplate string
+
+// APIReferenceTemplate is populated with the API reference of a managed
+// resource.
+//
+//go:embed apireference.md.tmpl
+var APIReferenceTemplate string
+
+// APIReferenceIndexTemplate is populated with the list of the managed
+// resources of an API group.
+//
+//go:embed apireference_index.md.tmpl
+var APIReferenceIndexTemplate string
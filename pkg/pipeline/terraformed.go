@@ -6,7 +6,6 @@ package pipeline
 
 import (
 	"go/types"
-	"os"
 	"path/filepath"
 	"strings"
 
@@ -30,44 +29,48 @@ func NewTerraformedGenerator(pkg *types.Package, rootDir, group, version string)
 type TerraformedGenerator struct {
 	LocalDirectoryPath string
 	LicenseHeaderPath  string
+	// Template overrides the default terraformed template.
+	Template string
 
 	pkg *types.Package
 }
 
 // Generate writes generated Terraformed interface functions
 func (tg *TerraformedGenerator) Generate(cfgs []*terraformedInput, apiVersion string) error {
-	trFile := wrapper.NewFile(tg.pkg.Path(), tg.pkg.Name(), templates.TerraformedTemplate,
+	trFile := wrapper.NewFile(tg.pkg.Path(), tg.pkg.Name(), templateOrDefault(tg.Template, templates.TerraformedTemplate),
 		wrapper.WithGenStatement(GenStatement),
 		wrapper.WithHeaderPath(tg.LicenseHeaderPath),
 	)
-	filePath := filepath.Join(tg.LocalDirectoryPath, "zz_generated_terraformed.go")
-	vars := map[string]any{
-		"APIVersion": apiVersion,
+	filePath := tg.FilePath()
+	vars := TerraformedTemplateVars{
+		APIVersion: apiVersion,
+		Resources:  make([]TerraformedResourceVars, len(cfgs)),
 	}
-	resources := make([]map[string]any, len(cfgs))
-	index := 0
-	for _, cfg := range cfgs {
-		resources[index] = map[string]any{
-			"CRD": map[string]string{
-				"Kind":               cfg.Kind,
-				"ParametersTypeName": cfg.ParametersTypeName,
+	for i, cfg := range cfgs {
+		vars.Resources[i] = TerraformedResourceVars{
+			CRD: TerraformedCRDVars{
+				Kind:               cfg.Kind,
+				ParametersTypeName: cfg.ParametersTypeName,
 			},
-			"Terraform": map[string]any{
-				"ResourceType":  cfg.Name,
-				"SchemaVersion": cfg.TerraformResource.SchemaVersion,
+			Terraform: TerraformVars{
+				ResourceType:  cfg.Name,
+				SchemaVersion: cfg.TerraformResource.SchemaVersion,
 			},
-			"Sensitive": map[string]any{
-				"Fields": cfg.Sensitive.GetFieldPaths(),
+			Sensitive: SensitiveVars{
+				Fields: cfg.Sensitive.GetFieldPaths(),
 			},
-			"LateInitializer": map[string]any{
-				"IgnoredFields": cfg.LateInitializer.GetIgnoredCanonicalFields(),
+			LateInitializer: LateInitializerVars{
+				IgnoredFields: cfg.LateInitializer.GetIgnoredCanonicalFields(),
 			},
 		}
-		index++
 	}
-	vars["Resources"] = resources
 	return errors.Wrap(
-		trFile.Write(filePath, vars, os.ModePerm),
+		writeFormatted(trFile, filePath, templateVars(vars)),
 		"cannot write terraformed conversion methods file",
 	)
 }
+
+// FilePath returns the path of the generated terraformed file.
+func (tg *TerraformedGenerator) FilePath() string {
+	return filepath.Join(tg.LocalDirectoryPath, "zz_generated_terraformed.go")
+}
@@ -6,7 +6,6 @@ package pipeline
 
 import (
 	"go/types"
-	"os"
 	"path/filepath"
 	"strings"
 
@@ -51,11 +50,16 @@ func (vg *VersionGenerator) Generate() error {
 		wrapper.WithHeaderPath(vg.LicenseHeaderPath),
 	)
 	return errors.Wrap(
-		gviFile.Write(filepath.Join(vg.DirectoryPath, "zz_groupversion_info.go"), vars, os.ModePerm),
+		writeFormatted(gviFile, vg.FilePath(), vars),
 		"cannot write group version info file",
 	)
 }
 
+// FilePath returns the path of the group version info file.
+func (vg *VersionGenerator) FilePath() string {
+	return filepath.Join(vg.DirectoryPath, "zz_groupversion_info.go")
+}
+
 // Package returns the package of the version that will be generated.
 func (vg *VersionGenerator) Package() *types.Package {
 	return vg.pkg
@@ -6,6 +6,7 @@ package reference
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -21,6 +22,22 @@ const (
 	fmtExtractParamFuncPath   = extractorPackagePath + `.ExtractParamPath("%s",%t)`
 )
 
+var reExtractParamPath = regexp.MustCompile(`\.ExtractParamPath\("([^"]+)",\s*(true|false)\)$`)
+
+// ExtractedAttribute returns the Terraform attribute of the referenced
+// resource whose value is extracted by the given extractor of a reference.
+// The default extractor, i.e. the external name, is assumed to extract the
+// id attribute. It returns false if the extracted attribute is not known.
+func ExtractedAttribute(extractor string) (string, bool) {
+	if extractor == "" || extractor == extractResourceIDFuncPath {
+		return "id", true
+	}
+	if m := reExtractParamPath.FindStringSubmatch(extractor); m != nil && strings.HasPrefix(extractor, extractorPackagePath) {
+		return m[1], true
+	}
+	return "", false
+}
+
 // Injector resolves references using provider metadata
 type Injector struct {
 	ModulePath        string
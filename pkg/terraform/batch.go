@@ -0,0 +1,453 @@
+// Copyright 2023 Upbound Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terraform
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+	"k8s.io/utils/clock"
+
+	"github.com/upbound/upjet/pkg/metrics"
+	"github.com/upbound/upjet/pkg/resource/json"
+)
+
+const (
+	defaultRefreshBatchWindow  = time.Second
+	defaultMaxRefreshBatchSize = 50
+	// fmtBatchResourceName is the format of the names of the resource
+	// blocks in a combined workspace, which are unique in the batch.
+	fmtBatchResourceName = "upjet_batch_%d"
+
+	errNotBatchable          = "workspace does not have a single managed resource block"
+	errBatchRefreshWait      = "cannot wait for the batched refresh"
+	errFmtBatchWorkspace     = "cannot prepare the combined workspace %s"
+	errFmtBatchRefresh       = "batched refresh of %d workspaces failed: %s"
+	errFmtBatchMemberState   = "cannot write the refreshed state of the workspace %s"
+	errFmtBatchMemberChanged = "workspace %s has changed during the batched refresh"
+)
+
+// RefreshBatcherOption configures a RefreshBatcher.
+type RefreshBatcherOption func(*RefreshBatcher)
+
+// WithRefreshBatchWindow configures how long a RefreshBatcher collects the
+// refresh requests of the workspaces sharing a provider configuration
+// before refreshing them together. Defaults to one second.
+func WithRefreshBatchWindow(d time.Duration) RefreshBatcherOption {
+	return func(b *RefreshBatcher) {
+		b.window = d
+	}
+}
+
+// WithMaxRefreshBatchSize configures the maximum number of the workspaces
+// refreshed together. A batch is refreshed as soon as it reaches this size
+// without waiting for the end of its window. Defaults to 50.
+func WithMaxRefreshBatchSize(n int) RefreshBatcherOption {
+	return func(b *RefreshBatcher) {
+		b.maxSize = n
+	}
+}
+
+// RefreshBatcher refreshes the workspaces whose managed resources share a
+// provider configuration, i.e., a ProviderHandle, together with a single
+// Terraform CLI invocation. The refresh requests received within a window
+// are refreshed in a combined workspace holding all of their resource blocks
+// and states, and the refreshed states are written back to the workspaces.
+// If a batched refresh fails, the workspaces in the batch are refreshed
+// individually, so that the failures are reported for the resources causing
+// them.
+type RefreshBatcher struct {
+	window  time.Duration
+	maxSize int
+	logger  logging.Logger
+	clock   clock.WithDelayedExecution
+	mu      *sync.Mutex
+	pending map[string]*refreshBatch
+}
+
+// NewRefreshBatcher returns a new RefreshBatcher.
+func NewRefreshBatcher(l logging.Logger, opts ...RefreshBatcherOption) *RefreshBatcher {
+	b := &RefreshBatcher{
+		window:  defaultRefreshBatchWindow,
+		maxSize: defaultMaxRefreshBatchSize,
+		logger:  l,
+		clock:   clock.RealClock{},
+		mu:      &sync.Mutex{},
+		pending: make(map[string]*refreshBatch),
+	}
+	for _, o := range opts {
+		o(b)
+	}
+	return b
+}
+
+type refreshRequest struct {
+	ctx context.Context
+	w   *Workspace
+	// resourceType, resourceName and parameters describe the single resource
+	// block of the workspace.
+	resourceType string
+	resourceName string
+	parameters   any
+	// mainTF is the parsed configuration and state is the current state of
+	// the workspace.
+	mainTF map[string]any
+	state  *json.StateV4
+	// lastOperation is the last asynchronous operation of the workspace
+	// when the request was made.
+	lastOperation OperationInfo
+	result        chan refreshResponse
+}
+
+type refreshResponse struct {
+	res RefreshResult
+	err error
+	// fallback is set when the workspace needs to be refreshed on its own.
+	fallback bool
+}
+
+type refreshBatch struct {
+	key      string
+	requests []*refreshRequest
+	timer    clock.Timer
+}
+
+// refresh refreshes the given workspace in a batch with the other workspaces
+// sharing its provider configuration. The workspaces that cannot be batched
+// are refreshed on their own right away.
+func (b *RefreshBatcher) refresh(ctx context.Context, w *Workspace) (RefreshResult, error) {
+	req, key, err := newRefreshRequest(ctx, w)
+	if err != nil {
+		w.logger.Debug("Workspace cannot be refreshed in a batch", "reason", err)
+		return w.refresh(ctx)
+	}
+	b.enqueue(key, req)
+	select {
+	case resp := <-req.result:
+		if resp.fallback {
+			return w.refresh(ctx)
+		}
+		return resp.res, resp.err
+	case <-ctx.Done():
+		return RefreshResult{}, errors.Wrap(ctx.Err(), errBatchRefreshWait)
+	}
+}
+
+func (b *RefreshBatcher) enqueue(key string, req *refreshRequest) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	batch := b.pending[key]
+	if batch == nil {
+		batch = &refreshBatch{key: key}
+		b.pending[key] = batch
+		batch.timer = b.clock.AfterFunc(b.window, func() {
+			b.flush(batch)
+		})
+	}
+	batch.requests = append(batch.requests, req)
+	if len(batch.requests) >= b.maxSize {
+		batch.timer.Stop()
+		delete(b.pending, key)
+		go b.run(batch)
+	}
+}
+
+// flush runs the given batch at the end of its window unless it has already
+// been run because it has reached the maximum size.
+func (b *RefreshBatcher) flush(batch *refreshBatch) {
+	b.mu.Lock()
+	if b.pending[batch.key] != batch {
+		b.mu.Unlock()
+		return
+	}
+	delete(b.pending, batch.key)
+	b.mu.Unlock()
+	b.run(batch)
+}
+
+func (b *RefreshBatcher) run(batch *refreshBatch) {
+	metrics.RefreshBatchSize.Observe(float64(len(batch.requests)))
+	if len(batch.requests) == 1 {
+		batch.requests[0].result <- refreshResponse{fallback: true}
+		return
+	}
+	ctx, cancel := batchContext(batch.requests)
+	defer cancel()
+	responses, err := b.refreshBatch(ctx, batch.requests)
+	if err != nil {
+		b.logger.Info("Batched refresh failed, refreshing the workspaces individually", "size", len(batch.requests), "error", err)
+		for _, r := range batch.requests {
+			r.result <- refreshResponse{fallback: true}
+		}
+		return
+	}
+	for i, r := range batch.requests {
+		r.result <- responses[i]
+	}
+}
+
+// refreshBatch refreshes the given requests in a combined workspace and
+// writes the refreshed states back to their workspaces.
+func (b *RefreshBatcher) refreshBatch(ctx context.Context, reqs []*refreshRequest) ([]refreshResponse, error) { //nolint:gocyclo
+	leader := reqs[0].w
+	dir, err := leader.fs.TempDir(filepath.Dir(leader.dir), "refresh-batch-")
+	if err != nil {
+		return nil, errors.Wrapf(err, errFmtBatchWorkspace, filepath.Dir(leader.dir))
+	}
+	defer func() {
+		if err := leader.fs.RemoveAll(dir); err != nil {
+			b.logger.Info("Cannot remove the combined workspace", "dir", dir, "error", err)
+		}
+	}()
+
+	combined := &json.StateV4{
+		Version:          reqs[0].state.Version,
+		TerraformVersion: reqs[0].state.TerraformVersion,
+		Lineage:          reqs[0].state.Lineage,
+		RootOutputs:      map[string]json.OutputStateV4{},
+	}
+	resources := map[string]any{}
+	for i, r := range reqs {
+		name := fmt.Sprintf(fmtBatchResourceName, i)
+		blocks, ok := resources[r.resourceType].(map[string]any)
+		if !ok {
+			blocks = map[string]any{}
+			resources[r.resourceType] = blocks
+		}
+		blocks[name] = r.parameters
+		for _, rs := range r.state.Resources {
+			rs.Name = name
+			combined.Resources = append(combined.Resources, rs)
+		}
+		if r.state.Serial > combined.Serial {
+			combined.Serial = r.state.Serial
+		}
+	}
+	mainTF := map[string]any{
+		"terraform": reqs[0].mainTF["terraform"],
+		"provider":  reqs[0].mainTF["provider"],
+		"resource":  resources,
+	}
+	if err := writeBatchWorkspace(leader, dir, mainTF, combined); err != nil {
+		return nil, errors.Wrapf(err, errFmtBatchWorkspace, dir)
+	}
+
+	bw := leader.batchWorkspace(dir)
+	out, err := bw.runTF(ctx, ModeSync, "apply", "-refresh-only", "-auto-approve", "-input=false", "-lock=false", "-json")
+	bw.logger.Debug("batched refresh ended", "size", len(reqs), "out", bw.filterFn(string(out)))
+	if inv := bw.debug.last(); inv != nil {
+		for _, r := range reqs {
+			r.w.debug.record(inv)
+		}
+	}
+	if err != nil {
+		return nil, errors.Errorf(errFmtBatchRefresh, len(reqs), bw.filterFn(string(out)))
+	}
+	raw, err := leader.fs.ReadFile(filepath.Join(dir, "terraform.tfstate"))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read terraform state file")
+	}
+	refreshed := &json.StateV4{}
+	if err := json.JSParser.Unmarshal(raw, refreshed); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal tfstate file")
+	}
+
+	responses := make([]refreshResponse, len(reqs))
+	for i, r := range reqs {
+		name := fmt.Sprintf(fmtBatchResourceName, i)
+		s := *r.state
+		s.TerraformVersion = refreshed.TerraformVersion
+		s.Serial++
+		s.Resources = []json.ResourceStateV4{}
+		for _, rs := range refreshed.Resources {
+			if rs.Type == r.resourceType && rs.Name == name {
+				rs.Name = r.resourceName
+				s.Resources = append(s.Resources, rs)
+			}
+		}
+		ok, err := r.writeBack(&s)
+		if err != nil {
+			responses[i] = refreshResponse{err: errors.Wrapf(err, errFmtBatchMemberState, r.w.dir)}
+			continue
+		}
+		if !ok {
+			// the caller, if still waiting, retries with the current
+			// state of its workspace.
+			responses[i] = refreshResponse{err: errors.Errorf(errFmtBatchMemberChanged, r.w.dir)}
+			continue
+		}
+		responses[i] = refreshResponse{
+			res: RefreshResult{
+				Exists: s.GetAttributes() != nil,
+				State:  &s,
+			},
+		}
+	}
+	return responses, nil
+}
+
+// writeBack writes the given refreshed state into the workspace of the
+// request. The state is not written if the caller has stopped waiting for
+// it, as the workspace may already be used by a subsequent operation, or if
+// the state or the last operation of the workspace has changed since the
+// request was made, so that a newer state is never replaced with the stale
+// one. It returns whether the state is written.
+func (r *refreshRequest) writeBack(s *json.StateV4) (bool, error) {
+	raw, err := json.JSParser.Marshal(s)
+	if err != nil {
+		return false, errors.Wrap(err, errMarshalState)
+	}
+	w := r.w
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if r.ctx.Err() != nil || w.LastOperation.info() != r.lastOperation {
+		return false, nil
+	}
+	current, err := w.fs.ReadFile(filepath.Join(w.dir, "terraform.tfstate"))
+	if err != nil {
+		return false, errors.Wrap(err, errReadTFState)
+	}
+	cs := &json.StateV4{}
+	if err := json.JSParser.Unmarshal(current, cs); err != nil {
+		return false, errors.Wrap(err, errUnmarshalTFState)
+	}
+	if cs.Serial != r.state.Serial || cs.Lineage != r.state.Lineage {
+		return false, nil
+	}
+	return true, errors.Wrap(w.fs.WriteFile(filepath.Join(w.dir, "terraform.tfstate"), raw, 0600), errWriteTFStateFile)
+}
+
+// newRefreshRequest returns a refresh request for the given workspace and
+// the key of the batch it belongs to. Only the workspaces with a single
+// managed resource block can be batched and the workspaces are batched
+// together only if they share both their ProviderHandles and their
+// provider and Terraform settings.
+func newRefreshRequest(ctx context.Context, w *Workspace) (*refreshRequest, string, error) { //nolint:gocyclo
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	handle := w.providerHandle()
+	if handle == InvalidProviderHandle {
+		return nil, "", errors.New(errNotBatchable)
+	}
+	raw, err := w.fs.ReadFile(filepath.Join(w.dir, "main.tf.json"))
+	if err != nil {
+		return nil, "", errors.Wrap(err, errReadMainTF)
+	}
+	mainTF := map[string]any{}
+	if err := json.JSParser.Unmarshal(raw, &mainTF); err != nil {
+		return nil, "", errors.Wrap(err, "cannot unmarshal main.tf.json")
+	}
+	req := &refreshRequest{
+		ctx:           ctx,
+		w:             w,
+		mainTF:        mainTF,
+		lastOperation: w.LastOperation.info(),
+		result:        make(chan refreshResponse, 1),
+	}
+	for k := range mainTF {
+		if k != "terraform" && k != "provider" && k != "resource" {
+			return nil, "", errors.New(errNotBatchable)
+		}
+	}
+	resources, ok := mainTF["resource"].(map[string]any)
+	if !ok || len(resources) != 1 {
+		return nil, "", errors.New(errNotBatchable)
+	}
+	for t, blocks := range resources {
+		m, ok := blocks.(map[string]any)
+		if !ok || len(m) != 1 {
+			return nil, "", errors.New(errNotBatchable)
+		}
+		req.resourceType = t
+		for n, params := range m {
+			req.resourceName, req.parameters = n, params
+		}
+	}
+	raw, err = w.fs.ReadFile(filepath.Join(w.dir, "terraform.tfstate"))
+	if err != nil {
+		return nil, "", errors.Wrap(err, errReadTFState)
+	}
+	req.state = &json.StateV4{}
+	if err := json.JSParser.Unmarshal(raw, req.state); err != nil {
+		return nil, "", errors.Wrap(err, errUnmarshalTFState)
+	}
+	settings, err := json.JSParser.Marshal(map[string]any{
+		"terraform": mainTF["terraform"],
+		"provider":  mainTF["provider"],
+	})
+	if err != nil {
+		return nil, "", errors.Wrap(err, "cannot marshal the provider settings")
+	}
+	return req, fmt.Sprintf("%s/%x", handle, sha256.Sum256(settings)), nil
+}
+
+// writeBatchWorkspace writes the configuration and the state of a combined
+// workspace into the given directory. The Terraform initialization artifacts
+// of the given leader workspace, if any, are linked into the directory, too,
+// as all the workspaces in a batch share the same provider requirements.
+func writeBatchWorkspace(leader *Workspace, dir string, mainTF map[string]any, state *json.StateV4) error {
+	rawMainTF, err := json.JSParser.Marshal(mainTF)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal main hcl object")
+	}
+	if err := leader.fs.WriteFile(filepath.Join(dir, "main.tf.json"), rawMainTF, 0600); err != nil {
+		return errors.Wrap(err, errWriteMainTFFile)
+	}
+	rawState, err := json.JSParser.Marshal(state)
+	if err != nil {
+		return errors.Wrap(err, errMarshalState)
+	}
+	if err := leader.fs.WriteFile(filepath.Join(dir, "terraform.tfstate"), rawState, 0600); err != nil {
+		return errors.Wrap(err, errWriteTFStateFile)
+	}
+	for _, f := range []string{".terraform", ".terraform.lock.hcl"} {
+		src := filepath.Join(leader.dir, f)
+		if _, err := leader.fs.Stat(src); err != nil {
+			continue
+		}
+		l, ok := leader.fs.Fs.(afero.Linker)
+		if !ok {
+			return errors.Errorf("cannot link %s into the combined workspace", src)
+		}
+		if err := l.SymlinkIfPossible(src, filepath.Join(dir, f)); err != nil {
+			return errors.Wrapf(err, "cannot link %s into the combined workspace", src)
+		}
+	}
+	return nil
+}
+
+// batchContext returns a context for refreshing the given requests, which is
+// canceled when all the requests are canceled.
+func batchContext(reqs []*refreshRequest) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for _, r := range reqs {
+			select {
+			case <-r.ctx.Done():
+			case <-ctx.Done():
+				return
+			}
+		}
+		cancel()
+	}()
+	return ctx, cancel
+}
@@ -0,0 +1,381 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package terraform
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+	k8sExec "k8s.io/utils/exec"
+	testingexec "k8s.io/utils/exec/testing"
+
+	"github.com/upbound/upjet/pkg/resource/json"
+)
+
+const (
+	batchMainTF = `{"provider":{"aws":{"region":"us-east-1"}},"resource":{"aws_iam_user":{"%s":{"name":"%s"}}},"terraform":{"required_providers":{"aws":{"source":"hashicorp/aws","version":"4.15.1"}}}}`
+	batchState  = `{"version":4,"terraform_version":"1.2.1","serial":%d,"lineage":"lineage-%s","outputs":{},"resources":[{"mode":"managed","type":"aws_iam_user","name":"%s","provider":"provider[\"registry.terraform.io/hashicorp/aws\"]","instances":[{"schema_version":0,"attributes":{"id":"%s"}}]}]}`
+)
+
+// refreshCmd returns a fake refresh command, which marks the attributes of
+// the resources in the state of its workspace as refreshed, except for the
+// resource named "gone", which is removed from the state.
+func refreshCmd(fs afero.Afero, fail bool) testingexec.FakeCommandAction {
+	return func(_ string, _ ...string) k8sExec.Cmd {
+		cmd := &testingexec.FakeCmd{}
+		cmd.CombinedOutputScript = []testingexec.FakeAction{
+			func() ([]byte, []byte, error) {
+				if fail {
+					return []byte("boom"), nil, errors.New("boom")
+				}
+				dir := cmd.Dirs[0]
+				raw, err := fs.ReadFile(filepath.Join(dir, "terraform.tfstate"))
+				if err != nil {
+					return nil, nil, err
+				}
+				s := &json.StateV4{}
+				if err := json.JSParser.Unmarshal(raw, s); err != nil {
+					return nil, nil, err
+				}
+				resources := make([]json.ResourceStateV4, 0, len(s.Resources))
+				for _, rs := range s.Resources {
+					attrs := map[string]any{}
+					if err := json.JSParser.Unmarshal(rs.Instances[0].AttributesRaw, &attrs); err != nil {
+						return nil, nil, err
+					}
+					if attrs["id"] == "gone" {
+						continue
+					}
+					attrs["refreshed"] = true
+					rs.Instances[0].AttributesRaw, _ = json.JSParser.Marshal(attrs)
+					resources = append(resources, rs)
+				}
+				s.Resources = resources
+				s.Serial++
+				raw, _ = json.JSParser.Marshal(s)
+				return nil, nil, fs.WriteFile(filepath.Join(dir, "terraform.tfstate"), raw, 0600)
+			},
+		}
+		return cmd
+	}
+}
+
+func TestRefreshBatcher(t *testing.T) {
+	type want struct {
+		calls    int
+		exists   map[string]bool
+		refresh  map[string]bool
+		dirsLeft int
+	}
+	cases := map[string]struct {
+		names   []string
+		scripts []bool
+		want    want
+	}{
+		"Batched": {
+			names:   []string{"a", "b", "gone"},
+			scripts: []bool{false},
+			want: want{
+				calls:   1,
+				exists:  map[string]bool{"a": true, "b": true, "gone": false},
+				refresh: map[string]bool{"a": true, "b": true},
+			},
+		},
+		"FallbackOnFailure": {
+			names:   []string{"a", "b"},
+			scripts: []bool{true, false, false},
+			want: want{
+				calls:   3,
+				exists:  map[string]bool{"a": true, "b": true},
+				refresh: map[string]bool{"a": true, "b": true},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fs := afero.Afero{Fs: afero.NewMemMapFs()}
+			e := &testingexec.FakeExec{}
+			for _, fail := range tc.scripts {
+				e.CommandScript = append(e.CommandScript, refreshCmd(fs, fail))
+			}
+			b := NewRefreshBatcher(logging.NewNopLogger(), WithRefreshBatchWindow(time.Hour), WithMaxRefreshBatchSize(len(tc.names)))
+			workspaces := make(map[string]*Workspace, len(tc.names))
+			for i, n := range tc.names {
+				dir := filepath.Join("/tmp", n)
+				if err := fs.WriteFile(filepath.Join(dir, "main.tf.json"), []byte(fmt.Sprintf(batchMainTF, n, n)), 0600); err != nil {
+					t.Fatal(err)
+				}
+				if err := fs.WriteFile(filepath.Join(dir, "terraform.tfstate"), []byte(fmt.Sprintf(batchState, i, n, n, n)), 0600); err != nil {
+					t.Fatal(err)
+				}
+				w := NewWorkspace(dir, WithExecutor(e), WithAferoFs(fs.Fs), WithFilterFn(filterFn), WithRefreshBatcher(b))
+				w.ProviderHandle = "handle"
+				workspaces[n] = w
+			}
+
+			var (
+				mu      sync.Mutex
+				wg      sync.WaitGroup
+				exists  = map[string]bool{}
+				refresh = map[string]bool{}
+			)
+			for n, w := range workspaces {
+				n, w := n, w
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					r, err := w.Refresh(context.Background())
+					if err != nil {
+						t.Errorf("Refresh(%s): %v", n, err)
+						return
+					}
+					mu.Lock()
+					defer mu.Unlock()
+					exists[n] = r.Exists
+					if !r.Exists {
+						return
+					}
+					if got := r.State.Resources[0].Name; got != n {
+						t.Errorf("Refresh(%s): want resource name %s, got %s", n, n, got)
+					}
+					attrs := map[string]any{}
+					if err := json.JSParser.Unmarshal(r.State.GetAttributes(), &attrs); err != nil {
+						t.Errorf("Refresh(%s): %v", n, err)
+					}
+					refresh[n] = attrs["refreshed"] == true
+				}()
+			}
+			wg.Wait()
+
+			if diff := cmp.Diff(tc.want.calls, e.CommandCalls); diff != "" {
+				t.Errorf("\n%s\nRefresh(...): -want CLI invocations, +got CLI invocations:\n%s", name, diff)
+			}
+			if diff := cmp.Diff(tc.want.exists, exists); diff != "" {
+				t.Errorf("\n%s\nRefresh(...): -want exists, +got exists:\n%s", name, diff)
+			}
+			if diff := cmp.Diff(tc.want.refresh, refresh); diff != "" {
+				t.Errorf("\n%s\nRefresh(...): -want refreshed, +got refreshed:\n%s", name, diff)
+			}
+			// the refreshed states are written back to the workspaces.
+			for n, ok := range tc.want.refresh {
+				raw, err := fs.ReadFile(filepath.Join("/tmp", n, "terraform.tfstate"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				s := &json.StateV4{}
+				if err := json.JSParser.Unmarshal(raw, s); err != nil {
+					t.Fatal(err)
+				}
+				attrs := map[string]any{}
+				if err := json.JSParser.Unmarshal(s.GetAttributes(), &attrs); err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(ok, attrs["refreshed"] == true); diff != "" {
+					t.Errorf("\n%s\nRefresh(...): -want refreshed state of %s, +got:\n%s", name, n, diff)
+				}
+				if diff := cmp.Diff("lineage-"+n, s.Lineage); diff != "" {
+					t.Errorf("\n%s\nRefresh(...): -want lineage of %s, +got:\n%s", name, n, diff)
+				}
+			}
+			// the combined workspaces are removed.
+			dirs, err := afero.Glob(fs, "/tmp/refresh-batch-*")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(0, len(dirs)); diff != "" {
+				t.Errorf("\n%s\nRefresh(...): -want combined workspaces, +got:\n%s", name, diff)
+			}
+		})
+	}
+}
+
+// blockingRefreshCmd returns a fake refresh command like refreshCmd, which
+// closes the given started channel and waits for the given proceed channel
+// to be closed before refreshing.
+func blockingRefreshCmd(fs afero.Afero, started, proceed chan struct{}) testingexec.FakeCommandAction {
+	return func(cmd string, args ...string) k8sExec.Cmd {
+		c := refreshCmd(fs, false)(cmd, args...).(*testingexec.FakeCmd)
+		refresh := c.CombinedOutputScript[0]
+		c.CombinedOutputScript[0] = func() ([]byte, []byte, error) {
+			close(started)
+			<-proceed
+			return refresh()
+		}
+		return c
+	}
+}
+
+func TestRefreshBatcherMemberChanged(t *testing.T) {
+	// newerState is the state written into the workspace of "a" by a
+	// subsequent operation during the batched refresh.
+	newerState := fmt.Sprintf(batchState, 5, "a", "a", "a")
+	cases := map[string]struct {
+		reason string
+		// change changes the workspace of "a" during the batched refresh.
+		change  func(w *Workspace, cancel context.CancelFunc, fs afero.Afero) error
+		wantErr error
+		// wantState is the expected state of "a" after the batched refresh.
+		wantState string
+	}{
+		"Canceled": {
+			reason: "The refreshed state must not be written back into the workspace whose caller has stopped waiting.",
+			change: func(_ *Workspace, cancel context.CancelFunc, _ afero.Afero) error {
+				cancel()
+				return nil
+			},
+			wantErr:   errors.Wrap(context.Canceled, errBatchRefreshWait),
+			wantState: fmt.Sprintf(batchState, 0, "a", "a", "a"),
+		},
+		"CanceledAndApplied": {
+			reason: "The refreshed state must not replace the state written by an operation started after the caller has stopped waiting.",
+			change: func(_ *Workspace, cancel context.CancelFunc, fs afero.Afero) error {
+				cancel()
+				return fs.WriteFile("/tmp/a/terraform.tfstate", []byte(newerState), 0600)
+			},
+			wantErr:   errors.Wrap(context.Canceled, errBatchRefreshWait),
+			wantState: newerState,
+		},
+		"StateChanged": {
+			reason: "The refreshed state must not replace a newer state of the workspace.",
+			change: func(_ *Workspace, _ context.CancelFunc, fs afero.Afero) error {
+				return fs.WriteFile("/tmp/a/terraform.tfstate", []byte(newerState), 0600)
+			},
+			wantErr:   errors.Errorf(errFmtBatchMemberChanged, "/tmp/a"),
+			wantState: newerState,
+		},
+		"OperationStarted": {
+			reason: "The refreshed state must not be written back into a workspace whose last operation has changed.",
+			change: func(w *Workspace, _ context.CancelFunc, _ afero.Afero) error {
+				w.LastOperation.MarkStart("apply")
+				return nil
+			},
+			wantErr:   errors.Errorf(errFmtBatchMemberChanged, "/tmp/a"),
+			wantState: fmt.Sprintf(batchState, 0, "a", "a", "a"),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fs := afero.Afero{Fs: afero.NewMemMapFs()}
+			started, proceed := make(chan struct{}), make(chan struct{})
+			e := &testingexec.FakeExec{CommandScript: []testingexec.FakeCommandAction{blockingRefreshCmd(fs, started, proceed)}}
+			b := NewRefreshBatcher(logging.NewNopLogger(), WithRefreshBatchWindow(time.Hour), WithMaxRefreshBatchSize(2))
+			workspaces := map[string]*Workspace{}
+			for i, n := range []string{"a", "b"} {
+				dir := filepath.Join("/tmp", n)
+				if err := fs.WriteFile(filepath.Join(dir, "main.tf.json"), []byte(fmt.Sprintf(batchMainTF, n, n)), 0600); err != nil {
+					t.Fatal(err)
+				}
+				if err := fs.WriteFile(filepath.Join(dir, "terraform.tfstate"), []byte(fmt.Sprintf(batchState, i, n, n, n)), 0600); err != nil {
+					t.Fatal(err)
+				}
+				w := NewWorkspace(dir, WithExecutor(e), WithAferoFs(fs.Fs), WithFilterFn(filterFn), WithRefreshBatcher(b))
+				w.ProviderHandle = "handle"
+				workspaces[n] = w
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			errs := map[string]chan error{"a": make(chan error, 1), "b": make(chan error, 1)}
+			go func() {
+				_, err := workspaces["a"].Refresh(ctx)
+				errs["a"] <- err
+			}()
+			go func() {
+				_, err := workspaces["b"].Refresh(context.Background())
+				errs["b"] <- err
+			}()
+			<-started
+			if err := tc.change(workspaces["a"], cancel, fs); err != nil {
+				t.Fatal(err)
+			}
+			if errors.Is(tc.wantErr, context.Canceled) {
+				// the canceled caller returns before the batch completes.
+				if diff := cmp.Diff(tc.wantErr, <-errs["a"], test.EquateErrors()); diff != "" {
+					t.Errorf("\n%s\nRefresh(a): -want error, +got error:\n%s", tc.reason, diff)
+				}
+			}
+			close(proceed)
+			if !errors.Is(tc.wantErr, context.Canceled) {
+				if diff := cmp.Diff(tc.wantErr, <-errs["a"], test.EquateErrors()); diff != "" {
+					t.Errorf("\n%s\nRefresh(a): -want error, +got error:\n%s", tc.reason, diff)
+				}
+			}
+			if err := <-errs["b"]; err != nil {
+				t.Errorf("\n%s\nRefresh(b): %v", tc.reason, err)
+			}
+			// the batch has completed once "b" is refreshed.
+			raw, err := fs.ReadFile("/tmp/a/terraform.tfstate")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.wantState, string(raw)); diff != "" {
+				t.Errorf("\n%s\nRefresh(a): -want state, +got state:\n%s", tc.reason, diff)
+			}
+			raw, err = fs.ReadFile("/tmp/b/terraform.tfstate")
+			if err != nil {
+				t.Fatal(err)
+			}
+			st := &json.StateV4{}
+			if err := json.JSParser.Unmarshal(raw, st); err != nil {
+				t.Fatal(err)
+			}
+			attrs := map[string]any{}
+			if err := json.JSParser.Unmarshal(st.GetAttributes(), &attrs); err != nil {
+				t.Fatal(err)
+			}
+			if attrs["refreshed"] != true {
+				t.Errorf("\n%s\nRefresh(b): want the refreshed state to be written back", tc.reason)
+			}
+		})
+	}
+}
+
+func TestNewRefreshRequest(t *testing.T) {
+	cases := map[string]struct {
+		handle  ProviderHandle
+		mainTF  string
+		wantErr bool
+	}{
+		"Batchable": {
+			handle: "handle",
+			mainTF: fmt.Sprintf(batchMainTF, "a", "a"),
+		},
+		"NoProviderHandle": {
+			mainTF:  fmt.Sprintf(batchMainTF, "a", "a"),
+			wantErr: true,
+		},
+		"DataSource": {
+			handle:  "handle",
+			mainTF:  `{"provider":{"aws":{}},"data":{"aws_iam_user":{"a":{"name":"a"}}}}`,
+			wantErr: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fs := afero.Afero{Fs: afero.NewMemMapFs()}
+			if err := fs.WriteFile("/tmp/a/main.tf.json", []byte(tc.mainTF), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := fs.WriteFile("/tmp/a/terraform.tfstate", []byte(fmt.Sprintf(batchState, 1, "a", "a", "a")), 0600); err != nil {
+				t.Fatal(err)
+			}
+			w := NewWorkspace("/tmp/a", WithAferoFs(fs.Fs))
+			w.ProviderHandle = tc.handle
+			_, _, err := newRefreshRequest(context.Background(), w)
+			if diff := cmp.Diff(tc.wantErr, err != nil); diff != "" {
+				t.Errorf("\n%s\nnewRefreshRequest(...): -want error, +got error:\n%s\n%v", name, diff, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,609 @@
+// Copyright 2023 Upbound Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terraform
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/upbound/upjet/pkg/resource/json"
+)
+
+const (
+	// DebugPathPrefix is the path prefix the debug handler is expected to be
+	// mounted on, e.g., as an extra handler of the metrics server.
+	DebugPathPrefix = "/debug/upjet/"
+
+	defaultInvocationHistorySize = 10
+	// maxInvocationOutputSize is the maximum size of the recorded output of
+	// a CLI invocation. Only the last part of longer outputs is recorded.
+	maxInvocationOutputSize = 16 * 1024
+
+	valRedacted    = "REDACTED"
+	pathWorkspaces = "workspaces"
+	pathRunners    = "runners"
+
+	errFmtNoWorkspace  = "no workspace found for the managed resource with UID %s"
+	errReadDebugMainTF = "cannot read main.tf.json file for the debug bundle"
+	errReadDebugState  = "cannot read terraform.tfstate file for the debug bundle"
+)
+
+var errWorkspaceNotFound = errors.New("workspace not found")
+
+// Invocation is a recorded Terraform CLI invocation of a Workspace.
+type Invocation struct {
+	// Args are the command-line arguments of the invocation.
+	Args []string `json:"args"`
+	// Mode is the execution mode of the invocation, i.e., sync or async.
+	Mode string `json:"mode"`
+	// StartTime is when the invocation started.
+	StartTime time.Time `json:"startTime"`
+	// Duration is how long the invocation took.
+	Duration time.Duration `json:"duration"`
+	// Error is the error returned by the invocation, if any.
+	Error string `json:"error,omitempty"`
+	// Output is the redacted combined output of the invocation.
+	Output string `json:"output"`
+}
+
+// RunnerInfo describes a native provider runner managed by a
+// ProviderScheduler.
+type RunnerInfo struct {
+	// Handle is the ProviderHandle the runner serves.
+	Handle ProviderHandle `json:"handle"`
+	// StartedAt is when the runner was scheduled.
+	StartedAt time.Time `json:"startedAt"`
+	// LastUsed is when the runner was last scheduled or released.
+	LastUsed time.Time `json:"lastUsed"`
+	// ReattachConfig is the last reattach configuration returned by the
+	// runner.
+	ReattachConfig string `json:"reattachConfig,omitempty"`
+	// ReattachConfigAge is the time elapsed since the runner first returned
+	// its last reattach configuration, i.e., the uptime of its native
+	// provider process.
+	ReattachConfigAge time.Duration `json:"reattachConfigAge,omitempty"`
+	// InvocationCount is the number of the Terraform CLI invocations that
+	// have used the runner.
+	InvocationCount int `json:"invocationCount"`
+	// InUse is the number of the ongoing Terraform CLI invocations using the
+	// runner.
+	InUse int `json:"inUse"`
+	// TTL is the number of invocations after which the runner is replaced.
+	TTL int `json:"ttl"`
+	// TTLBudget is the number of invocations left before the runner is
+	// replaced. It becomes negative while an expired runner is still in use.
+	TTLBudget int `json:"ttlBudget"`
+	// Draining is set when the runner is being replaced and will be stopped
+	// once it's no longer in use.
+	Draining bool `json:"draining,omitempty"`
+}
+
+// RunnerInspector is implemented by the ProviderSchedulers that can report
+// the native provider runners they manage.
+type RunnerInspector interface {
+	// Runners returns the information about the scheduled runners.
+	Runners() []RunnerInfo
+}
+
+// OperationInfo is a snapshot of the last operation of a Workspace.
+type OperationInfo struct {
+	Type      string     `json:"type,omitempty"`
+	StartTime *time.Time `json:"startTime,omitempty"`
+	EndTime   *time.Time `json:"endTime,omitempty"`
+}
+
+// WorkspaceInfo is the summary of a Workspace in a WorkspaceStore.
+type WorkspaceInfo struct {
+	// UID is the UID of the managed resource of the Workspace.
+	UID types.UID `json:"uid"`
+	// Dir is the directory of the Workspace.
+	Dir string `json:"dir"`
+	// ProviderHandle is the handle of the native provider used by the
+	// Workspace.
+	ProviderHandle ProviderHandle `json:"providerHandle,omitempty"`
+	// LastOperation is the last asynchronous operation of the Workspace.
+	LastOperation OperationInfo `json:"lastOperation"`
+}
+
+// DebugBundle is the information collected from the Workspace of a managed
+// resource to debug its reconciliation. The sensitive parameters of the
+// resource and the provider configuration values are redacted.
+type DebugBundle struct {
+	// UID is the UID of the managed resource.
+	UID types.UID `json:"uid"`
+	// Dir is the directory of the Workspace.
+	Dir string `json:"dir"`
+	// ProviderHandle is the handle of the native provider used by the
+	// Workspace.
+	ProviderHandle ProviderHandle `json:"providerHandle,omitempty"`
+	// ReattachConfig is the reattach configuration of the shared native
+	// provider last used by the Workspace, if any.
+	ReattachConfig string `json:"reattachConfig,omitempty"`
+	// AttachedAt is when the Workspace last attached to a shared native
+	// provider.
+	AttachedAt *time.Time `json:"attachedAt,omitempty"`
+	// LastOperation is the last asynchronous operation of the Workspace.
+	LastOperation OperationInfo `json:"lastOperation"`
+	// MainTF is the redacted main.tf.json of the Workspace.
+	MainTF string `json:"mainTF,omitempty"`
+	// MainTFHCL is the redacted main.tf.json rendered in HCL syntax.
+	MainTFHCL string `json:"mainTFHCL,omitempty"`
+	// State is the redacted terraform.tfstate of the Workspace.
+	State string `json:"state,omitempty"`
+	// Invocations are the most recent Terraform CLI invocations of the
+	// Workspace, the oldest first.
+	Invocations []Invocation `json:"invocations"`
+	// Runner is the native provider runner serving the Workspace, if the
+	// scheduler can report it.
+	Runner *RunnerInfo `json:"runner,omitempty"`
+}
+
+// debugInfo holds the information recorded for the debug bundles of a
+// Workspace. It has its own lock so that the bundles can be collected while
+// a Terraform CLI invocation holds the lock of the Workspace.
+type debugInfo struct {
+	mu             sync.Mutex
+	maxInvocations int
+	invocations    []Invocation
+	reattachConfig string
+	attachedAt     *time.Time
+	tfResource     *schema.Resource
+}
+
+func (d *debugInfo) record(inv *Invocation) {
+	if inv == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.invocations = append(d.invocations, *inv)
+	if n := len(d.invocations) - d.maxInvocations; n > 0 {
+		d.invocations = append(d.invocations[:0:0], d.invocations[n:]...)
+	}
+}
+
+// last returns the most recent recorded invocation, if any.
+func (d *debugInfo) last() *Invocation {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.invocations) == 0 {
+		return nil
+	}
+	inv := d.invocations[len(d.invocations)-1]
+	return &inv
+}
+
+func (d *debugInfo) attach(reattachConfig string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	d.reattachConfig = reattachConfig
+	d.attachedAt = &now
+}
+
+func (d *debugInfo) setResource(r *schema.Resource) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.tfResource = r
+}
+
+// newInvocation returns the record of a Terraform CLI invocation with its
+// arguments and output redacted, or nil if the recording is disabled.
+func (w *Workspace) newInvocation(mode ExecMode, args []string, start time.Time, out []byte, err error) *Invocation {
+	if w.debug.maxInvocations <= 0 {
+		return nil
+	}
+	filter := w.filterFn
+	if filter == nil {
+		filter = func(s string) string { return s }
+	}
+	inv := &Invocation{
+		Args:      make([]string, len(args)),
+		Mode:      mode.String(),
+		StartTime: start,
+		Duration:  time.Since(start),
+	}
+	for i, a := range args {
+		inv.Args[i] = filter(a)
+	}
+	if len(out) > maxInvocationOutputSize {
+		out = out[len(out)-maxInvocationOutputSize:]
+	}
+	inv.Output = filter(string(out))
+	if err != nil {
+		inv.Error = filter(err.Error())
+	}
+	return inv
+}
+
+// Workspaces returns the summaries of the Workspaces in the store sorted by
+// the UIDs of their managed resources.
+func (ws *WorkspaceStore) Workspaces() []WorkspaceInfo {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	result := make([]WorkspaceInfo, 0, len(ws.store))
+	for uid, w := range ws.store {
+		result = append(result, WorkspaceInfo{
+			UID:            uid,
+			Dir:            w.dir,
+			ProviderHandle: w.providerHandle(),
+			LastOperation:  w.LastOperation.info(),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].UID < result[j].UID
+	})
+	return result
+}
+
+// DebugBundle collects the debug bundle of the Workspace of the managed
+// resource with the given UID.
+func (ws *WorkspaceStore) DebugBundle(uid types.UID) (*DebugBundle, error) {
+	ws.mu.Lock()
+	w, ok := ws.store[uid]
+	ws.mu.Unlock()
+	if !ok {
+		return nil, errors.WithMessagef(errWorkspaceNotFound, errFmtNoWorkspace, uid)
+	}
+	b := &DebugBundle{
+		UID:            uid,
+		Dir:            w.dir,
+		ProviderHandle: w.providerHandle(),
+		LastOperation:  w.LastOperation.info(),
+	}
+	w.debug.mu.Lock()
+	b.Invocations = append([]Invocation{}, w.debug.invocations...)
+	b.ReattachConfig = w.debug.reattachConfig
+	b.AttachedAt = w.debug.attachedAt
+	tfResource := w.debug.tfResource
+	w.debug.mu.Unlock()
+
+	filter := w.filterFn
+	if filter == nil {
+		filter = func(s string) string { return s }
+	}
+	raw, err := w.fs.ReadFile(filepath.Join(w.dir, "main.tf.json"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, errReadDebugMainTF)
+	}
+	if err == nil {
+		mainTF, hcl, err := redactMainTF(raw, tfResource)
+		if err != nil {
+			return nil, err
+		}
+		b.MainTF = filter(mainTF)
+		b.MainTFHCL = filter(hcl)
+	}
+	raw, err = w.fs.ReadFile(filepath.Join(w.dir, "terraform.tfstate"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, errReadDebugState)
+	}
+	if err == nil {
+		st, err := redactState(raw, tfResource)
+		if err != nil {
+			return nil, err
+		}
+		b.State = filter(st)
+	}
+	return b, nil
+}
+
+// redactMainTF redacts the provider configurations and the sensitive
+// parameters of the resource in the given main.tf.json, and returns it
+// in both JSON and HCL syntax.
+func redactMainTF(raw []byte, r *schema.Resource) (string, string, error) {
+	m := map[string]any{}
+	if err := json.JSParser.Unmarshal(raw, &m); err != nil {
+		return "", "", errors.Wrap(err, "cannot unmarshal main.tf.json file")
+	}
+	if providers, ok := m["provider"].(map[string]any); ok {
+		for _, p := range providers {
+			if pc, ok := p.(map[string]any); ok {
+				for k := range pc {
+					if k != "alias" {
+						pc[k] = valRedacted
+					}
+				}
+			}
+		}
+	}
+	for _, bt := range []string{"resource", "data"} {
+		byType, _ := m[bt].(map[string]any)
+		for _, rs := range byType {
+			names, _ := rs.(map[string]any)
+			for _, params := range names {
+				if p, ok := params.(map[string]any); ok {
+					redactSensitive(r, p)
+				}
+			}
+		}
+	}
+	out, err := marshalIndent(m)
+	if err != nil {
+		return "", "", errors.Wrap(err, "cannot marshal the redacted main.tf.json")
+	}
+	hcl, err := renderHCL(m)
+	return string(out), string(hcl), err
+}
+
+// redactState redacts the sensitive attributes of the resource instances in
+// the given Terraform state.
+func redactState(raw []byte, r *schema.Resource) (string, error) {
+	st := &json.StateV4{}
+	if err := json.JSParser.Unmarshal(raw, st); err != nil {
+		return "", errors.Wrap(err, "cannot unmarshal tfstate file")
+	}
+	for i := range st.Resources {
+		for j := range st.Resources[i].Instances {
+			inst := &st.Resources[i].Instances[j]
+			if len(inst.AttributesRaw) == 0 {
+				continue
+			}
+			attrs := map[string]any{}
+			if err := json.JSParser.Unmarshal(inst.AttributesRaw, &attrs); err != nil {
+				return "", errors.Wrap(err, "cannot unmarshal the state attributes")
+			}
+			redactSensitive(r, attrs)
+			a, err := json.JSParser.Marshal(attrs)
+			if err != nil {
+				return "", errors.Wrap(err, "cannot marshal the redacted state attributes")
+			}
+			inst.AttributesRaw = a
+		}
+	}
+	out, err := marshalIndent(st)
+	return string(out), errors.Wrap(err, "cannot marshal the redacted tfstate")
+}
+
+// redactSensitive replaces the values of the sensitive fields of the given
+// Terraform schema in the given map, including the nested blocks.
+func redactSensitive(r *schema.Resource, m map[string]any) {
+	if r == nil {
+		return
+	}
+	for k, v := range m {
+		s, ok := r.Schema[k]
+		if !ok || v == nil {
+			continue
+		}
+		if s.Sensitive {
+			m[k] = valRedacted
+			continue
+		}
+		elem, ok := s.Elem.(*schema.Resource)
+		if !ok {
+			continue
+		}
+		switch t := v.(type) {
+		case map[string]any:
+			redactSensitive(elem, t)
+		case []any:
+			for _, e := range t {
+				if em, ok := e.(map[string]any); ok {
+					redactSensitive(elem, em)
+				}
+			}
+		}
+	}
+}
+
+// hclBlockLabels are the numbers of labels of the top-level blocks of a
+// main.tf.json.
+var hclBlockLabels = map[string]int{
+	"terraform": 0,
+	"provider":  1,
+	"resource":  2,
+	"data":      2,
+}
+
+// hclNestedBlocks are the arguments of a main.tf.json rendered as nested
+// blocks. The other object arguments are rendered as object attributes
+// since they cannot be distinguished from the nested blocks without the
+// schema.
+var hclNestedBlocks = map[string]bool{
+	"required_providers": true,
+	"lifecycle":          true,
+	"timeouts":           true,
+}
+
+// renderHCL renders the given main.tf.json content in HCL syntax on a best
+// effort basis for debugging purposes.
+func renderHCL(m map[string]any) ([]byte, error) {
+	f := hclwrite.NewEmptyFile()
+	for _, k := range sortedMapKeys(m) {
+		n, ok := hclBlockLabels[k]
+		if !ok {
+			continue
+		}
+		if err := appendBlocks(f.Body(), k, nil, n, m[k]); err != nil {
+			return nil, err
+		}
+	}
+	return f.Bytes(), nil
+}
+
+func appendBlocks(body *hclwrite.Body, blockType string, labels []string, depth int, v any) error {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return errors.Errorf("cannot render the %s block %s: not an object", blockType, strings.Join(labels, "."))
+	}
+	if depth > 0 {
+		for _, k := range sortedMapKeys(m) {
+			l := append(append(make([]string, 0, len(labels)+1), labels...), k)
+			if err := appendBlocks(body, blockType, l, depth-1, m[k]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return writeHCLBody(body.AppendNewBlock(blockType, labels).Body(), m)
+}
+
+func writeHCLBody(body *hclwrite.Body, m map[string]any) error {
+	for _, k := range sortedMapKeys(m) {
+		if nested, ok := m[k].(map[string]any); ok && hclNestedBlocks[k] {
+			if err := writeHCLBody(body.AppendNewBlock(k, nil).Body(), nested); err != nil {
+				return err
+			}
+			continue
+		}
+		v, err := toCtyValue(m[k])
+		if err != nil {
+			return errors.Wrapf(err, "cannot render the argument %s", k)
+		}
+		body.SetAttributeValue(k, v)
+	}
+	return nil
+}
+
+func toCtyValue(v any) (cty.Value, error) {
+	raw, err := json.JSParser.Marshal(v)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	t, err := ctyjson.ImpliedType(raw)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	return ctyjson.Unmarshal(raw, t)
+}
+
+func sortedMapKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// DebugHandlerOption configures the debug handler.
+type DebugHandlerOption func(*debugHandler)
+
+// WithRunnerInspector configures the scheduler whose native provider runners
+// are reported in the debug bundles.
+func WithRunnerInspector(ri RunnerInspector) DebugHandlerOption {
+	return func(h *debugHandler) {
+		h.runners = ri
+	}
+}
+
+// NewDebugHandler returns an HTTP handler serving the following paths under
+// DebugPathPrefix:
+//   - workspaces: The summaries of the Workspaces in the given store.
+//   - workspaces/<managed resource UID>: The debug bundle of a Workspace.
+//   - runners: The native provider runners of the configured
+//     RunnerInspector, if any.
+//
+// It can be mounted on the metrics server of the controller manager with:
+//
+//	mgr.AddMetricsExtraHandler(terraform.DebugPathPrefix, terraform.NewDebugHandler(ws))
+//
+// Although the sensitive values are redacted, the handler is meant to be
+// reached only locally, e.g., through a port-forward.
+func NewDebugHandler(ws *WorkspaceStore, opts ...DebugHandlerOption) http.Handler {
+	h := &debugHandler{store: ws}
+	for _, o := range opts {
+		o(h)
+	}
+	return h
+}
+
+type debugHandler struct {
+	store   *WorkspaceStore
+	runners RunnerInspector
+}
+
+func (h *debugHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	p := strings.Trim(strings.TrimPrefix(r.URL.Path, DebugPathPrefix), "/")
+	switch {
+	case p == pathWorkspaces:
+		writeDebugJSON(rw, h.store.Workspaces())
+	case p == pathRunners:
+		runners := []RunnerInfo{}
+		if h.runners != nil {
+			runners = h.runners.Runners()
+		}
+		writeDebugJSON(rw, runners)
+	case strings.HasPrefix(p, pathWorkspaces+"/"):
+		h.serveBundle(rw, types.UID(strings.TrimPrefix(p, pathWorkspaces+"/")))
+	default:
+		http.NotFound(rw, r)
+	}
+}
+
+func (h *debugHandler) serveBundle(rw http.ResponseWriter, uid types.UID) {
+	b, err := h.store.DebugBundle(uid)
+	switch {
+	case errors.Is(err, errWorkspaceNotFound):
+		http.Error(rw, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if h.runners != nil {
+		// a handle may have a pool of runners, so the runner is looked up
+		// by the reattach configuration the workspace uses.
+		for _, ri := range h.runners.Runners() {
+			if ri.Handle == b.ProviderHandle && (b.Runner == nil || ri.ReattachConfig == b.ReattachConfig) {
+				ri := ri
+				b.Runner = &ri
+			}
+		}
+	}
+	writeDebugJSON(rw, b)
+}
+
+func writeDebugJSON(rw http.ResponseWriter, v any) {
+	out, err := marshalIndent(v)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	_, _ = rw.Write(out)
+}
+
+// marshalIndent marshals the given value with the JSON parser of upjet and
+// indents the output, as the indentation of the parser is not reliable for
+// the nested maps.
+func marshalIndent(v any) ([]byte, error) {
+	raw, err := json.JSParser.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	buff := &bytes.Buffer{}
+	err = stdjson.Indent(buff, raw, "", "  ")
+	return buff.Bytes(), err
+}
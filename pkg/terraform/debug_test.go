@@ -0,0 +1,341 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package terraform
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	xpfake "github.com/crossplane/crossplane-runtime/pkg/resource/fake"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/upbound/upjet/pkg/config"
+	"github.com/upbound/upjet/pkg/resource/fake"
+	"github.com/upbound/upjet/pkg/resource/json"
+)
+
+const (
+	debugMainTF = `{"provider":{"test":{"token":"t0ken"}},"resource":{"test_instance":{"example":{"name":"example","password":"s3cr3t","rule":[{"port":80,"secret":"p4ss"}],"lifecycle":{"prevent_destroy":true}}}},"terraform":{"required_providers":{"test":{"source":"upbound/test","version":"1.0.0"}}}}`
+	debugState  = `{"version":4,"terraform_version":"1.2.1","serial":1,"lineage":"l","outputs":{},"resources":[{"mode":"managed","type":"test_instance","name":"example","provider":"provider[\"registry.terraform.io/upbound/test\"]","instances":[{"schema_version":0,"attributes":{"id":"example","name":"example","password":"s3cr3t"}}]}]}`
+)
+
+type fakeRunnerInspector []RunnerInfo
+
+func (f fakeRunnerInspector) Runners() []RunnerInfo {
+	return f
+}
+
+func TestDebugBundle(t *testing.T) {
+	uid := types.UID("some-uid")
+	fs := afero.NewMemMapFs()
+	ws := NewWorkspaceStore(logging.NewNopLogger(), WithFs(fs))
+	w := NewWorkspace("/tmp/some-uid", WithAferoFs(fs), WithInvocationHistorySize(2), WithFilterFn(Setup{
+		Configuration: ProviderConfiguration{"token": "t0ken"},
+	}.filterSensitiveInformation))
+	w.ProviderHandle = "handle"
+	w.debug.setResource(&schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"name":     {Type: schema.TypeString, Required: true},
+			"password": {Type: schema.TypeString, Optional: true, Sensitive: true},
+			"rule": {Type: schema.TypeList, Optional: true, Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"port":   {Type: schema.TypeInt, Optional: true},
+					"secret": {Type: schema.TypeString, Optional: true, Sensitive: true},
+				},
+			}},
+		},
+	})
+	ws.store[uid] = w
+	if err := fs.MkdirAll(w.dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := afero.WriteFile(fs, w.dir+"/main.tf.json", []byte(debugMainTF), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := afero.WriteFile(fs, w.dir+"/terraform.tfstate", []byte(debugState), 0600); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for _, args := range [][]string{{"init"}, {"plan"}, {"apply", "-refresh-only"}} {
+		w.debug.record(w.newInvocation(ModeSync, args, start, []byte("using t0ken"), errors.New("failed with t0ken")))
+	}
+	w.UseProvider(noopInUse{}, "reattach-config")
+
+	srv := httptest.NewServer(NewDebugHandler(ws, WithRunnerInspector(fakeRunnerInspector{
+		{Handle: "other", InvocationCount: 1},
+		{Handle: "handle", InvocationCount: 3, InUse: 1, TTL: 100},
+	})))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + DebugPathPrefix + "workspaces/unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET unknown workspace: want status %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+
+	resp, err = http.Get(srv.URL + DebugPathPrefix + "workspaces/" + string(uid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET workspace: want status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	got := &DebugBundle{}
+	if err := json.JSParser.NewDecoder(resp.Body).Decode(got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := &DebugBundle{
+		UID:            uid,
+		Dir:            "/tmp/some-uid",
+		ProviderHandle: "handle",
+		ReattachConfig: "reattach-config",
+		MainTF: `{
+  "provider": {
+    "test": {
+      "token": "REDACTED"
+    }
+  },
+  "resource": {
+    "test_instance": {
+      "example": {
+        "lifecycle": {
+          "prevent_destroy": true
+        },
+        "name": "example",
+        "password": "REDACTED",
+        "rule": [
+          {
+            "port": 80,
+            "secret": "REDACTED"
+          }
+        ]
+      }
+    }
+  },
+  "terraform": {
+    "required_providers": {
+      "test": {
+        "source": "upbound/test",
+        "version": "1.0.0"
+      }
+    }
+  }
+}`,
+		MainTFHCL: `provider "test" {
+  token = "REDACTED"
+}
+resource "test_instance" "example" {
+  lifecycle {
+    prevent_destroy = true
+  }
+  name     = "example"
+  password = "REDACTED"
+  rule = [{
+    port   = 80
+    secret = "REDACTED"
+  }]
+}
+terraform {
+  required_providers {
+    test = {
+      source  = "upbound/test"
+      version = "1.0.0"
+    }
+  }
+}
+`,
+		Invocations: []Invocation{
+			{Args: []string{"plan"}, Mode: "sync", Output: "using REDACTED", Error: "failed with REDACTED"},
+			{Args: []string{"apply", "-refresh-only"}, Mode: "sync", Output: "using REDACTED", Error: "failed with REDACTED"},
+		},
+		Runner: &RunnerInfo{Handle: "handle", InvocationCount: 3, InUse: 1, TTL: 100},
+	}
+	ignore := cmpopts.IgnoreFields(DebugBundle{}, "AttachedAt", "State")
+	ignoreTimes := cmpopts.IgnoreFields(Invocation{}, "StartTime", "Duration")
+	if diff := cmp.Diff(want, got, ignore, ignoreTimes); diff != "" {
+		t.Errorf("DebugBundle: -want, +got:\n%s", diff)
+	}
+	if got.AttachedAt == nil {
+		t.Errorf("DebugBundle: want the attachment time to be set")
+	}
+	st := &json.StateV4{}
+	if err := json.JSParser.Unmarshal([]byte(got.State), st); err != nil {
+		t.Fatal(err)
+	}
+	attrs := map[string]any{}
+	if err := json.JSParser.Unmarshal(st.GetAttributes(), &attrs); err != nil {
+		t.Fatal(err)
+	}
+	wantAttrs := map[string]any{"id": "example", "name": "example", "password": "REDACTED"}
+	if diff := cmp.Diff(wantAttrs, attrs); diff != "" {
+		t.Errorf("DebugBundle: -want state attributes, +got:\n%s", diff)
+	}
+}
+
+func TestDebugHandlerListing(t *testing.T) {
+	ws := NewWorkspaceStore(logging.NewNopLogger(), WithFs(afero.NewMemMapFs()))
+	applying := &Operation{}
+	applying.MarkStart("apply")
+	ws.store["uid-b"] = NewWorkspace("/tmp/uid-b", WithLastOperation(applying))
+	ws.store["uid-a"] = NewWorkspace("/tmp/uid-a")
+	ws.store["uid-a"].ProviderHandle = "handle"
+
+	type want struct {
+		status int
+		body   any
+	}
+	cases := map[string]struct {
+		path    string
+		runners RunnerInspector
+		got     any
+		want    want
+	}{
+		"Workspaces": {
+			path: "workspaces",
+			got:  &[]WorkspaceInfo{},
+			want: want{
+				status: http.StatusOK,
+				body: &[]WorkspaceInfo{
+					{UID: "uid-a", Dir: "/tmp/uid-a", ProviderHandle: "handle"},
+					{UID: "uid-b", Dir: "/tmp/uid-b", LastOperation: OperationInfo{Type: "apply"}},
+				},
+			},
+		},
+		"Runners": {
+			path:    "runners",
+			runners: fakeRunnerInspector{{Handle: "handle", InvocationCount: 3, InUse: 1, TTL: 100, TTLBudget: 97}},
+			got:     &[]RunnerInfo{},
+			want: want{
+				status: http.StatusOK,
+				body:   &[]RunnerInfo{{Handle: "handle", InvocationCount: 3, InUse: 1, TTL: 100, TTLBudget: 97}},
+			},
+		},
+		"NoRunnerInspector": {
+			path: "runners",
+			got:  &[]RunnerInfo{},
+			want: want{
+				status: http.StatusOK,
+				body:   &[]RunnerInfo{},
+			},
+		},
+		"UnknownPath": {
+			path: "unknown",
+			want: want{
+				status: http.StatusNotFound,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var opts []DebugHandlerOption
+			if tc.runners != nil {
+				opts = append(opts, WithRunnerInspector(tc.runners))
+			}
+			srv := httptest.NewServer(NewDebugHandler(ws, opts...))
+			defer srv.Close()
+			resp, err := http.Get(srv.URL + DebugPathPrefix + tc.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close() //nolint:errcheck
+			if diff := cmp.Diff(tc.want.status, resp.StatusCode); diff != "" {
+				t.Errorf("\n%s\nGET %s: -want status, +got status:\n%s", name, tc.path, diff)
+			}
+			if tc.got == nil {
+				return
+			}
+			if err := json.JSParser.NewDecoder(resp.Body).Decode(tc.got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want.body, tc.got, cmpopts.IgnoreFields(OperationInfo{}, "StartTime")); diff != "" {
+				t.Errorf("\n%s\nGET %s: -want body, +got body:\n%s", name, tc.path, diff)
+			}
+		})
+	}
+}
+
+func TestDebugHandlerConcurrentWorkspace(t *testing.T) {
+	// The workspaces are prepared in the OS temporary directory.
+	t.Setenv("TMPDIR", t.TempDir())
+	ws := NewWorkspaceStore(logging.NewNopLogger(), WithFs(afero.NewOsFs()), WithDisableInit(true))
+	tr := &fake.Terraformed{
+		Managed: xpfake.Managed{
+			ObjectMeta: metav1.ObjectMeta{
+				UID:         "some-uid",
+				Annotations: map[string]string{meta.AnnotationKeyExternalName: "some-id"},
+			},
+		},
+		Parameterizable: fake.Parameterizable{Parameters: map[string]any{"param": "paramval"}},
+	}
+	setup := Setup{Requirement: ProviderRequirement{Source: "hashicorp/provider-test", Version: "1.2.3"}}
+	cfg := config.DefaultResource("upjet_resource", nil, nil)
+	srv := httptest.NewServer(NewDebugHandler(ws))
+	defer srv.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		for i := 0; i < 10; i++ {
+			if _, err := ws.Workspace(context.Background(), nil, tr, setup, cfg); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	for running := true; running; {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("Workspace(...): %s", err)
+			}
+			running = false
+		default:
+		}
+		resp, err := http.Get(srv.URL + DebugPathPrefix + "workspaces")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET workspaces: want status %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+	}
+	got := ws.Workspaces()
+	if len(got) != 1 || got[0].ProviderHandle == InvalidProviderHandle {
+		t.Errorf("Workspaces(): want a single workspace with a provider handle, got %v", got)
+	}
+}
+
+func TestSharedProviderSchedulerRunners(t *testing.T) {
+	s := NewSharedProviderScheduler(logging.NewNopLogger(), 100)
+	s.runners["b"] = []*schedulerEntry{{ProviderRunner: NewNoOpProviderRunner(), handle: "b", invocationCount: 101, inUse: 2, draining: true}}
+	s.runners["a"] = []*schedulerEntry{{ProviderRunner: NewNoOpProviderRunner(), handle: "a", invocationCount: 3}}
+	s.runners["a"][0].setReattachConfig("reattach-config", time.Now())
+
+	got := s.Runners()
+	want := []RunnerInfo{
+		{Handle: "a", ReattachConfig: "reattach-config", InvocationCount: 3, TTL: 100, TTLBudget: 97},
+		{Handle: "b", InvocationCount: 101, InUse: 2, TTL: 100, TTLBudget: -1, Draining: true},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(RunnerInfo{}, "StartedAt", "LastUsed", "ReattachConfigAge")); diff != "" {
+		t.Errorf("Runners(): -want, +got:\n%s", diff)
+	}
+}
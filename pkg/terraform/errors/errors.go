@@ -165,6 +165,7 @@ func IsPlanFailed(err error) bool {
 type retrySchedule struct {
 	invocationCount int
 	ttl             int
+	maxRunners      int
 }
 
 func NewRetryScheduleError(invocationCount, ttl int) error {
@@ -174,7 +175,19 @@ func NewRetryScheduleError(invocationCount, ttl int) error {
 	}
 }
 
+// NewRetryScheduleCapacityError returns a retry error for the scheduler
+// when the maximum number of the native providers are running and all of
+// them are in use.
+func NewRetryScheduleCapacityError(maxRunners int) error {
+	return &retrySchedule{
+		maxRunners: maxRunners,
+	}
+}
+
 func (r *retrySchedule) Error() string {
+	if r.maxRunners > 0 {
+		return fmt.Sprintf("maximum number of native providers are running and all are in use: maxRunners: %d", r.maxRunners)
+	}
 	return fmt.Sprintf("native provider reuse budget has been exceeded: invocationCount: %d, ttl: %d", r.invocationCount, r.ttl)
 }
 
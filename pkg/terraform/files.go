@@ -14,6 +14,7 @@ import (
 	"dario.cat/mergo"
 
 	"github.com/crossplane/crossplane-runtime/pkg/feature"
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
 	"github.com/crossplane/crossplane-runtime/pkg/meta"
 	"github.com/pkg/errors"
 	"github.com/spf13/afero"
@@ -35,6 +36,16 @@ const (
 	errUnmarshalTFState  = "cannot unmarshal tfstate file"
 	errFmtNonString      = "cannot work with a non-string id: %s"
 	errReadMainTF        = "cannot read main.tf.json file"
+	errFmtNoProvider     = "cannot find the setup of the Terraform provider %q"
+
+	errFmtGetProviderArgument    = "cannot get the provider argument %q from the parameters"
+	errFmtDeleteProviderArgument = "cannot remove the provider argument %q from the parameters"
+	errFmtSetProviderArgument    = "cannot set the provider configuration argument %q"
+	errProviderAlias             = "cannot compute the provider configuration alias"
+
+	// providerAliasPrefix is the prefix of the aliases of the provider
+	// configurations that are overridden by the resource arguments.
+	providerAliasPrefix = "upjet_"
 )
 
 // FileProducerOption allows you to configure FileProducer
@@ -103,6 +114,9 @@ func NewFileProducer(ctx context.Context, client resource.SecretClient, dir stri
 	}
 	fp.Config.ExternalName.SetIdentifierArgumentFn(params, meta.GetExternalName(tr))
 	fp.parameters = params
+	if err := fp.applyProviderArguments(); err != nil {
+		return nil, errors.Wrapf(err, "cannot apply the provider arguments of the resource %q", tr.GetName())
+	}
 
 	obs, err := tr.GetObservation()
 	if err != nil {
@@ -127,46 +141,73 @@ type FileProducer struct {
 	parameters  map[string]any
 	observation map[string]any
 	ignored     []string
-	fs          afero.Afero
-	features    *feature.Flags
+	// providerAlias is the alias of the provider configuration if the
+	// resource overrides any of its arguments.
+	providerAlias string
+	fs            afero.Afero
+	features      *feature.Flags
 }
 
 // WriteMainTF writes the content main configuration file that has the desired
 // state configuration for Terraform.
 func (fp *FileProducer) WriteMainTF() (ProviderHandle, error) {
-	// If the resource is in a deletion process, we need to remove the deletion
-	// protection.
-	lifecycle := map[string]any{
-		"prevent_destroy": !meta.WasDeleted(fp.Resource),
-	}
+	// Data sources are only read by Terraform, hence they neither support
+	// the lifecycle meta-arguments nor the operation timeouts.
+	blockType := "data"
+	if !fp.Config.DataSource {
+		blockType = "resource"
+		// If the resource is in a deletion process, we need to remove the
+		// deletion protection.
+		lifecycle := map[string]any{
+			"prevent_destroy": !meta.WasDeleted(fp.Resource),
+		}
 
-	if len(fp.ignored) != 0 {
-		lifecycle["ignore_changes"] = fp.ignored
-	}
+		if len(fp.ignored) != 0 {
+			lifecycle["ignore_changes"] = fp.ignored
+		}
 
-	fp.parameters["lifecycle"] = lifecycle
+		fp.parameters["lifecycle"] = lifecycle
 
-	// Add operation timeouts if any timeout configured for the resource
-	if tp := timeouts(fp.Config.OperationTimeouts).asParameter(); len(tp) != 0 {
-		fp.parameters["timeouts"] = tp
+		// Add operation timeouts if any timeout configured for the resource
+		if tp := timeouts(fp.Config.OperationTimeouts).asParameter(); len(tp) != 0 {
+			fp.parameters["timeouts"] = tp
+		}
+	}
+
+	providerName, requirement, configuration, err := fp.terraformProvider()
+	if err != nil {
+		return InvalidProviderHandle, err
+	}
+	// Resources of the additional providers may share their type prefix with
+	// the main provider, e.g. google-beta, so we explicitly select them.
+	if fp.Config.TerraformProviderName != "" {
+		fp.parameters["provider"] = providerName
+	}
+	providerBlock := configuration
+	if fp.providerAlias != "" {
+		providerBlock = make(ProviderConfiguration, len(configuration)+1)
+		for k, v := range configuration {
+			providerBlock[k] = v
+		}
+		providerBlock["alias"] = fp.providerAlias
+		fp.parameters["provider"] = fmt.Sprintf("%s.%s", providerName, fp.providerAlias)
 	}
 
 	// Note(turkenh): To use third party providers, we need to configure
 	// provider name in required_providers.
-	providerSource := strings.Split(fp.Setup.Requirement.Source, "/")
 	m := map[string]any{
 		"terraform": map[string]any{
 			"required_providers": map[string]any{
-				providerSource[len(providerSource)-1]: map[string]string{
-					"source":  fp.Setup.Requirement.Source,
-					"version": fp.Setup.Requirement.Version,
+				providerName: map[string]string{
+					"source":  requirement.Source,
+					"version": requirement.Version,
 				},
 			},
 		},
 		"provider": map[string]any{
-			providerSource[len(providerSource)-1]: fp.Setup.Configuration,
+			providerName: providerBlock,
 		},
-		"resource": map[string]any{
+		blockType: map[string]any{
 			fp.Resource.GetTerraformResourceType(): map[string]any{
 				fp.Resource.GetName(): fp.parameters,
 			},
@@ -176,10 +217,13 @@ func (fp *FileProducer) WriteMainTF() (ProviderHandle, error) {
 	if err != nil {
 		return InvalidProviderHandle, errors.Wrap(err, "cannot marshal main hcl object")
 	}
-	h, err := fp.Setup.Configuration.ToProviderHandle()
+	h, err := configuration.ToProviderHandle()
 	if err != nil {
 		return InvalidProviderHandle, errors.Wrap(err, "cannot get scheduler handle")
 	}
+	if fp.Config.TerraformProviderName != "" {
+		h = ProviderHandle(fmt.Sprintf("%s/%s", providerName, h))
+	}
 	return h, errors.Wrap(fp.fs.WriteFile(filepath.Join(fp.Dir, "main.tf.json"), rawMainTF, 0600), errWriteMainTFFile)
 }
 
@@ -188,6 +232,11 @@ func (fp *FileProducer) WriteMainTF() (ProviderHandle, error) {
 func (fp *FileProducer) EnsureTFState(ctx context.Context, tfID string) error { //nolint:gocyclo
 	// TODO(muvaf): Reduce the cyclomatic complexity by separating the attributes
 	// generation into its own function/interface.
+	// Data sources are read from scratch in every refresh, so we never need
+	// to produce their state.
+	if fp.Config.DataSource {
+		return nil
+	}
 	empty, err := fp.isStateEmpty()
 	if err != nil {
 		return errors.Wrap(err, errCheckIfStateEmpty)
@@ -214,6 +263,16 @@ func (fp *FileProducer) EnsureTFState(ctx context.Context, tfID string) error {
 	if err != nil {
 		return errors.Wrap(err, errMarshalAttributes)
 	}
+	_, requirement, _, err := fp.terraformProvider()
+	if err != nil {
+		return err
+	}
+	// TODO(muvaf): we should get the full URL from Dockerfile since
+	// providers don't have to be hosted in registry.terraform.io
+	providerConfig := fmt.Sprintf(`provider["registry.terraform.io/%s"]`, requirement.Source)
+	if fp.providerAlias != "" {
+		providerConfig = fmt.Sprintf("%s.%s", providerConfig, fp.providerAlias)
+	}
 	var privateRaw []byte
 	if pr, ok := fp.Resource.GetAnnotations()[resource.AnnotationKeyPrivateRawAttribute]; ok {
 		privateRaw = []byte(pr)
@@ -226,12 +285,10 @@ func (fp *FileProducer) EnsureTFState(ctx context.Context, tfID string) error {
 	s.Lineage = string(fp.Resource.GetUID())
 	s.Resources = []json.ResourceStateV4{
 		{
-			Mode: "managed",
-			Type: fp.Resource.GetTerraformResourceType(),
-			Name: fp.Resource.GetName(),
-			// TODO(muvaf): we should get the full URL from Dockerfile since
-			// providers don't have to be hosted in registry.terraform.io
-			ProviderConfig: fmt.Sprintf(`provider["registry.terraform.io/%s"]`, fp.Setup.Requirement.Source),
+			Mode:           "managed",
+			Type:           fp.Resource.GetTerraformResourceType(),
+			Name:           fp.Resource.GetName(),
+			ProviderConfig: providerConfig,
 			Instances: []json.InstanceObjectStateV4{
 				{
 					SchemaVersion: uint64(fp.Resource.GetTerraformSchemaVersion()),
@@ -301,8 +358,11 @@ func (fp *FileProducer) needProviderUpgrade() (bool, error) {
 	if err := json.JSParser.Unmarshal(data, &mainConfiguration); err != nil {
 		return false, errors.Wrap(err, errReadMainTF)
 	}
-	providerSource := strings.Split(fp.Setup.Requirement.Source, "/")
-	providerConfiguration, ok := mainConfiguration.Terraform.RequiredProviders[providerSource[len(providerSource)-1]]
+	providerName, requirement, _, err := fp.terraformProvider()
+	if err != nil {
+		return false, err
+	}
+	providerConfiguration, ok := mainConfiguration.Terraform.RequiredProviders[providerName]
 	if !ok {
 		return false, errors.New("cannot get provider configuration")
 	}
@@ -310,5 +370,107 @@ func (fp *FileProducer) needProviderUpgrade() (bool, error) {
 	if !ok {
 		return false, errors.New("cannot get version")
 	}
-	return v != fp.Setup.Requirement.Version, nil
+	return v != requirement.Version, nil
+}
+
+// terraformProvider returns the local name, the requirement and the
+// configuration of the Terraform provider the resource belongs to.
+func (fp *FileProducer) terraformProvider() (string, ProviderRequirement, ProviderConfiguration, error) {
+	if fp.Config.TerraformProviderName == "" {
+		providerSource := strings.Split(fp.Setup.Requirement.Source, "/")
+		return providerSource[len(providerSource)-1], fp.Setup.Requirement, fp.Setup.Configuration, nil
+	}
+	ap, ok := fp.Setup.AdditionalProviders[fp.Config.TerraformProviderName]
+	if !ok {
+		return "", ProviderRequirement{}, nil, errors.Errorf(errFmtNoProvider, fp.Config.TerraformProviderName)
+	}
+	return fp.Config.TerraformProviderName, ap.Requirement, ap.Configuration, nil
+}
+
+// applyProviderArguments moves the values of the resource arguments
+// configured in config.Resource.ProviderArguments from the parameters to the
+// configuration of the Terraform provider the resource belongs to. The
+// overridden configuration is given an alias computed from the overrides so
+// that it does not clash with the provider setup.
+func (fp *FileProducer) applyProviderArguments() error {
+	if len(fp.Config.ProviderArguments) == 0 {
+		return nil
+	}
+	params := fieldpath.Pave(fp.parameters)
+	overrides := make(map[string]any, len(fp.Config.ProviderArguments))
+	for argPath, providerPath := range fp.Config.ProviderArguments {
+		v, err := params.GetValue(argPath)
+		if fieldpath.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, errFmtGetProviderArgument, argPath)
+		}
+		if err := params.DeleteField(argPath); err != nil {
+			return errors.Wrapf(err, errFmtDeleteProviderArgument, argPath)
+		}
+		if v != nil {
+			overrides[providerPath] = v
+		}
+	}
+	if len(overrides) == 0 {
+		return nil
+	}
+	_, _, configuration, err := fp.terraformProvider()
+	if err != nil {
+		return err
+	}
+	merged := fieldpath.Pave(copyConfiguration(configuration))
+	for providerPath, v := range overrides {
+		if err := merged.SetValue(providerPath, v); err != nil {
+			return errors.Wrapf(err, errFmtSetProviderArgument, providerPath)
+		}
+	}
+	h, err := ProviderConfiguration(overrides).ToProviderHandle()
+	if err != nil {
+		return errors.Wrap(err, errProviderAlias)
+	}
+	fp.providerAlias = providerAliasPrefix + string(h)[:16]
+	// The setup is a copy but its maps are shared with the other resources,
+	// so we replace them instead of modifying.
+	if fp.Config.TerraformProviderName == "" {
+		fp.Setup.Configuration = merged.UnstructuredContent()
+		return nil
+	}
+	additional := make(map[string]AdditionalProvider, len(fp.Setup.AdditionalProviders))
+	for n, ap := range fp.Setup.AdditionalProviders {
+		additional[n] = ap
+	}
+	ap := additional[fp.Config.TerraformProviderName]
+	ap.Configuration = merged.UnstructuredContent()
+	additional[fp.Config.TerraformProviderName] = ap
+	fp.Setup.AdditionalProviders = additional
+	return nil
+}
+
+// copyConfiguration returns a copy of the given provider configuration whose
+// nested objects and lists are not shared with the original one.
+func copyConfiguration(m map[string]any) map[string]any {
+	result := make(map[string]any, len(m))
+	for k, v := range m {
+		result[k] = copyConfigurationValue(v)
+	}
+	return result
+}
+
+func copyConfigurationValue(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		return copyConfiguration(t)
+	case ProviderConfiguration:
+		return copyConfiguration(t)
+	case []any:
+		result := make([]any, len(t))
+		for i, e := range t {
+			result[i] = copyConfigurationValue(e)
+		}
+		return result
+	default:
+		return v
+	}
 }
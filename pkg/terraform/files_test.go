@@ -408,6 +408,143 @@ func TestWriteMainTF(t *testing.T) {
 				maintf: `{"provider":{"provider-test":null},"resource":{"":{"":{"lifecycle":{"prevent_destroy":true},"name":"some-id","param":"paramval"}}},"terraform":{"required_providers":{"provider-test":{"source":"my-company/namespace/provider-test","version":"1.2.3"}}}}`,
 			},
 		},
+		"DataSource": {
+			reason: "Data sources should be written as data blocks without the lifecycle meta-arguments",
+			args: args{
+				tr: &fake.Terraformed{
+					Managed: xpfake.Managed{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								meta.AnnotationKeyExternalName: "some-id",
+							},
+						},
+					},
+					Parameterizable: fake.Parameterizable{Parameters: map[string]any{
+						"param": "paramval",
+					}},
+					Observable: fake.Observable{Observation: map[string]any{
+						"obs": "obsval",
+					}},
+				},
+				cfg: config.DefaultDataSource("upjet_data", nil),
+				s: Setup{
+					Requirement: ProviderRequirement{
+						Source:  "hashicorp/provider-test",
+						Version: "1.2.3",
+					},
+					Configuration: nil,
+				},
+			},
+			want: want{
+				maintf: `{"provider":{"provider-test":null},"data":{"":{"":{"param":"paramval"}}},"terraform":{"required_providers":{"provider-test":{"source":"hashicorp/provider-test","version":"1.2.3"}}}}`,
+			},
+		},
+		"AdditionalProvider": {
+			reason: "Resources of an additional Terraform provider should select the provider and use its setup",
+			args: args{
+				tr: &fake.Terraformed{
+					Managed: xpfake.Managed{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								meta.AnnotationKeyExternalName: "some-id",
+							},
+						},
+					},
+					Parameterizable: fake.Parameterizable{Parameters: map[string]any{
+						"param": "paramval",
+					}},
+				},
+				cfg: config.DefaultResource("upjet_resource", nil, nil, func(r *config.Resource) {
+					r.TerraformProviderName = "provider-test-beta"
+				}),
+				s: Setup{
+					Requirement: ProviderRequirement{
+						Source:  "hashicorp/provider-test",
+						Version: "1.2.3",
+					},
+					AdditionalProviders: map[string]AdditionalProvider{
+						"provider-test-beta": {
+							Requirement: ProviderRequirement{
+								Source:  "hashicorp/provider-test-beta",
+								Version: "4.5.6",
+							},
+							Configuration: ProviderConfiguration{
+								"region": "us-east-1",
+							},
+						},
+					},
+				},
+			},
+			want: want{
+				maintf: `{"provider":{"provider-test-beta":{"region":"us-east-1"}},"resource":{"":{"":{"lifecycle":{"prevent_destroy":true},"name":"some-id","param":"paramval","provider":"provider-test-beta"}}},"terraform":{"required_providers":{"provider-test-beta":{"source":"hashicorp/provider-test-beta","version":"4.5.6"}}}}`,
+			},
+		},
+		"ProviderArguments": {
+			reason: "Resource arguments overriding the provider configuration should be moved to an aliased provider configuration",
+			args: args{
+				tr: &fake.Terraformed{
+					Managed: xpfake.Managed{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								meta.AnnotationKeyExternalName: "some-id",
+							},
+						},
+					},
+					Parameterizable: fake.Parameterizable{Parameters: map[string]any{
+						"param":    "paramval",
+						"region":   "eu-west-1",
+						"role_arn": "some-role",
+					}},
+				},
+				cfg: config.DefaultResource("upjet_resource", nil, nil, func(r *config.Resource) {
+					r.ProviderArguments = map[string]string{
+						"region":   "region",
+						"role_arn": "assume_role.role_arn",
+						"profile":  "profile",
+					}
+				}),
+				s: Setup{
+					Requirement: ProviderRequirement{
+						Source:  "hashicorp/provider-test",
+						Version: "1.2.3",
+					},
+					Configuration: ProviderConfiguration{
+						"region": "us-east-1",
+						"token":  "some-token",
+					},
+				},
+			},
+			want: want{
+				maintf: `{"provider":{"provider-test":{"alias":"upjet_8c6a1f9d7c2ebbb1","assume_role":{"role_arn":"some-role"},"region":"eu-west-1","token":"some-token"}},"resource":{"":{"":{"lifecycle":{"prevent_destroy":true},"name":"some-id","param":"paramval","provider":"provider-test.upjet_8c6a1f9d7c2ebbb1"}}},"terraform":{"required_providers":{"provider-test":{"source":"hashicorp/provider-test","version":"1.2.3"}}}}`,
+			},
+		},
+		"MissingAdditionalProvider": {
+			reason: "An error should be returned if the setup of the additional Terraform provider is missing",
+			args: args{
+				tr: &fake.Terraformed{
+					Managed: xpfake.Managed{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								meta.AnnotationKeyExternalName: "some-id",
+							},
+						},
+					},
+					Parameterizable: fake.Parameterizable{Parameters: map[string]any{}},
+				},
+				cfg: config.DefaultResource("upjet_resource", nil, nil, func(r *config.Resource) {
+					r.TerraformProviderName = "provider-test-beta"
+				}),
+				s: Setup{
+					Requirement: ProviderRequirement{
+						Source:  "hashicorp/provider-test",
+						Version: "1.2.3",
+					},
+				},
+			},
+			want: want{
+				err: errors.Errorf(errFmtNoProvider, "provider-test-beta"),
+			},
+		},
 		"SuccessManagementPolicies": {
 			reason: "Management policies enabled with ignore changes resources and merging initProvider should be able to write everything it has into maintf file",
 			args: args{
@@ -479,6 +616,9 @@ func TestWriteMainTF(t *testing.T) {
 			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
 				t.Errorf("\n%s\nWriteMainTF(...): -want error, +got error:\n%s", tc.reason, diff)
 			}
+			if tc.want.err != nil {
+				return
+			}
 			s, _ := afero.Afero{Fs: fs}.ReadFile(filepath.Join(dir, "main.tf.json"))
 			var res map[string]any
 			var wantJson map[string]any
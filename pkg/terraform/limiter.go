@@ -0,0 +1,240 @@
+// Copyright 2023 Upbound Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terraform
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/upbound/upjet/pkg/metrics"
+)
+
+// CLIClass is a class of Terraform CLI subcommands sharing a concurrency
+// limit.
+type CLIClass string
+
+const (
+	// CLIClassObserve is the class of the refresh, plan and import
+	// invocations observing the external resources.
+	CLIClassObserve CLIClass = "observe"
+	// CLIClassApply is the class of the apply and destroy invocations
+	// changing the external resources.
+	CLIClassApply CLIClass = "apply"
+	// CLIClassInit is the class of the init invocations.
+	CLIClassInit CLIClass = "init"
+
+	errFmtAcquireCLISlot = "cannot acquire a slot to run the Terraform CLI %s invocation"
+)
+
+// cliPriority is the priority of a Terraform CLI invocation waiting for a
+// slot. The waiting invocations with higher priorities are run first, so
+// that the deletions and the creations are not starved by the observations.
+type cliPriority int
+
+const (
+	priorityObserve cliPriority = iota
+	priorityInit
+	priorityApply
+	priorityDestroy
+)
+
+// cliClassOf returns the class and the priority of a Terraform CLI
+// invocation with the given arguments.
+func cliClassOf(args []string) (CLIClass, cliPriority) {
+	switch args[0] {
+	case "init":
+		return CLIClassInit, priorityInit
+	case "destroy":
+		return CLIClassApply, priorityDestroy
+	case "apply":
+		for _, a := range args[1:] {
+			if a == "-refresh-only" {
+				return CLIClassObserve, priorityObserve
+			}
+		}
+		return CLIClassApply, priorityApply
+	default:
+		return CLIClassObserve, priorityObserve
+	}
+}
+
+// cliLimiter limits the number of the concurrent Terraform CLI invocations
+// per CLIClass and in total.
+type cliLimiter struct {
+	classes map[CLIClass]*prioritySemaphore
+	total   *prioritySemaphore
+}
+
+func newCLILimiter(classLimits map[CLIClass]int, total int) *cliLimiter {
+	l := &cliLimiter{
+		classes: make(map[CLIClass]*prioritySemaphore, len(classLimits)),
+	}
+	for c, n := range classLimits {
+		if n > 0 {
+			l.classes[c] = newPrioritySemaphore(n)
+		}
+	}
+	if total > 0 {
+		l.total = newPrioritySemaphore(total)
+	}
+	if len(l.classes) == 0 && l.total == nil {
+		return nil
+	}
+	return l
+}
+
+// acquire waits until the Terraform CLI invocation with the given arguments
+// can be run, or the given context is done. The returned function must be
+// called to release the acquired slots when the invocation completes.
+func (l *cliLimiter) acquire(ctx context.Context, args []string) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	class, priority := cliClassOf(args)
+	start := time.Now()
+	metrics.CLIQueuedInvocations.WithLabelValues(string(class)).Inc()
+	defer func() {
+		metrics.CLIQueuedInvocations.WithLabelValues(string(class)).Dec()
+		metrics.CLIQueueWaitTime.WithLabelValues(args[0], string(class)).Observe(time.Since(start).Seconds())
+	}()
+	// the class slot is always acquired before the total slot, so that the
+	// invocations cannot hold the slots in different orders.
+	cs := l.classes[class]
+	if err := cs.acquire(ctx, priority); err != nil {
+		return nil, errors.Wrapf(err, errFmtAcquireCLISlot, args[0])
+	}
+	if err := l.total.acquire(ctx, priority); err != nil {
+		cs.release()
+		return nil, errors.Wrapf(err, errFmtAcquireCLISlot, args[0])
+	}
+	return func() {
+		l.total.release()
+		cs.release()
+	}, nil
+}
+
+// prioritySemaphore is a counting semaphore, which grants the slots to the
+// waiters with higher priorities first, and in the order of their arrival
+// among the waiters with the same priority. A nil prioritySemaphore has
+// no limit.
+type prioritySemaphore struct {
+	mu      sync.Mutex
+	size    int
+	used    int
+	seq     uint64
+	waiters waiterQueue
+}
+
+type waiter struct {
+	priority cliPriority
+	seq      uint64
+	index    int
+	granted  bool
+	ready    chan struct{}
+}
+
+func newPrioritySemaphore(size int) *prioritySemaphore {
+	return &prioritySemaphore{size: size}
+}
+
+func (s *prioritySemaphore) acquire(ctx context.Context, priority cliPriority) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	if s.used < s.size && len(s.waiters) == 0 {
+		s.used++
+		s.mu.Unlock()
+		return nil
+	}
+	w := &waiter{priority: priority, seq: s.seq, ready: make(chan struct{})}
+	s.seq++
+	heap.Push(&s.waiters, w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if w.granted {
+			// the slot has been granted while the context was being
+			// canceled, so it's passed on.
+			s.releaseLocked()
+		} else {
+			heap.Remove(&s.waiters, w.index)
+		}
+		return ctx.Err()
+	}
+}
+
+func (s *prioritySemaphore) release() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.releaseLocked()
+}
+
+// releaseLocked hands over the released slot to the next waiter, if any. The
+// caller must hold the lock.
+func (s *prioritySemaphore) releaseLocked() {
+	if len(s.waiters) == 0 {
+		s.used--
+		return
+	}
+	w := heap.Pop(&s.waiters).(*waiter)
+	w.granted = true
+	close(w.ready)
+}
+
+// waiterQueue is a heap of waiters ordered by their priorities and arrival.
+type waiterQueue []*waiter
+
+func (q waiterQueue) Len() int { return len(q) }
+
+func (q waiterQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q waiterQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *waiterQueue) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *waiterQueue) Pop() any {
+	old := *q
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return w
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package terraform
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCLIClassOf(t *testing.T) {
+	type want struct {
+		class    CLIClass
+		priority cliPriority
+	}
+	cases := map[string]struct {
+		args []string
+		want want
+	}{
+		"Refresh": {
+			args: []string{"apply", "-refresh-only", "-auto-approve"},
+			want: want{class: CLIClassObserve, priority: priorityObserve},
+		},
+		"Plan": {
+			args: []string{"plan", "-refresh=false"},
+			want: want{class: CLIClassObserve, priority: priorityObserve},
+		},
+		"Import": {
+			args: []string{"import", "-input=false"},
+			want: want{class: CLIClassObserve, priority: priorityObserve},
+		},
+		"Apply": {
+			args: []string{"apply", "-auto-approve"},
+			want: want{class: CLIClassApply, priority: priorityApply},
+		},
+		"Destroy": {
+			args: []string{"destroy", "-auto-approve"},
+			want: want{class: CLIClassApply, priority: priorityDestroy},
+		},
+		"Init": {
+			args: []string{"init", "-input=false"},
+			want: want{class: CLIClassInit, priority: priorityInit},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			class, priority := cliClassOf(tc.args)
+			if diff := cmp.Diff(tc.want, want{class: class, priority: priority}, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\ncliClassOf(...): -want, +got:\n%s", name, diff)
+			}
+		})
+	}
+}
+
+// queued returns the number of the waiters of the semaphore.
+func (s *prioritySemaphore) queued() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.waiters)
+}
+
+func waitQueued(t *testing.T, s *prioritySemaphore, n int) {
+	t.Helper()
+	for i := 0; s.queued() != n; i++ {
+		if i == 1000 {
+			t.Fatalf("want %d waiters, got %d", n, s.queued())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPrioritySemaphore(t *testing.T) {
+	s := newPrioritySemaphore(1)
+	if err := s.acquire(context.Background(), priorityObserve); err != nil {
+		t.Fatal(err)
+	}
+	order := make(chan cliPriority, 4)
+	for i, p := range []cliPriority{priorityObserve, priorityApply, priorityObserve, priorityDestroy} {
+		p := p
+		go func() {
+			if err := s.acquire(context.Background(), p); err != nil {
+				t.Error(err)
+				return
+			}
+			order <- p
+			s.release()
+		}()
+		waitQueued(t, s, i+1)
+	}
+	s.release()
+	got := make([]cliPriority, 0, 4)
+	for i := 0; i < 4; i++ {
+		got = append(got, <-order)
+	}
+	want := []cliPriority{priorityDestroy, priorityApply, priorityObserve, priorityObserve}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("acquire(...): -want order, +got order:\n%s", diff)
+	}
+}
+
+func TestPrioritySemaphoreCanceled(t *testing.T) {
+	s := newPrioritySemaphore(1)
+	if err := s.acquire(context.Background(), priorityObserve); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.acquire(ctx, priorityDestroy); err == nil {
+		t.Errorf("acquire(...): want an error when the context is done")
+	}
+	if diff := cmp.Diff(0, s.queued()); diff != "" {
+		t.Errorf("acquire(...): -want waiters, +got waiters:\n%s", diff)
+	}
+	// the slot is available again once released.
+	s.release()
+	if err := s.acquire(context.Background(), priorityObserve); err != nil {
+		t.Errorf("acquire(...): %v", err)
+	}
+}
+
+func TestCLILimiter(t *testing.T) {
+	if l := newCLILimiter(map[CLIClass]int{}, 0); l != nil {
+		t.Errorf("newCLILimiter(...): want no limiter without limits")
+	}
+	l := newCLILimiter(map[CLIClass]int{CLIClassObserve: 1}, 2)
+	release, err := l.acquire(context.Background(), []string{"plan"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.acquire(ctx, []string{"apply", "-refresh-only"}); err == nil {
+		t.Errorf("acquire(refresh): want an error when the observe limit is reached")
+	}
+	// the other classes are limited only by the total limit.
+	releaseApply, err := l.acquire(context.Background(), []string{"apply"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.acquire(ctx, []string{"destroy"}); err == nil {
+		t.Errorf("acquire(destroy): want an error when the total limit is reached")
+	}
+	releaseApply()
+	release()
+	if _, err := l.acquire(context.Background(), []string{"plan"}); err != nil {
+		t.Errorf("acquire(plan): %v", err)
+	}
+}
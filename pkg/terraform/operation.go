@@ -78,3 +78,14 @@ func (o *Operation) EndTime() time.Time {
 	defer o.mu.RUnlock()
 	return *o.endTime
 }
+
+// info returns a snapshot of the operation.
+func (o *Operation) info() OperationInfo {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return OperationInfo{
+		Type:      o.Type,
+		StartTime: o.startTime,
+		EndTime:   o.endTime,
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2023 Upbound Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terraform
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	defaultProcFS = "/proc"
+	// pluginHealthService is the name of the gRPC health service registered
+	// by the go-plugin servers, such as the Terraform providers.
+	pluginHealthService = "plugin"
+
+	errNotRunning        = "native provider is not running"
+	errFmtHealthCheck    = "native provider health check failed for socket %s"
+	errFmtNotServing     = "native provider is not serving: %s"
+	errFmtSocketNotFound = "cannot find the unix socket %s"
+	errFmtSocketOwner    = "cannot find the process listening on the unix socket %s"
+	errFmtReadRSS        = "cannot read the resident set size of the process %d"
+)
+
+// reProviderUnavailable matches the Terraform CLI outputs of the failures to
+// reach a native provider, which happen before the CLI makes any changes.
+// Hence, the invocations failing with them are safe to retry.
+var reProviderUnavailable = regexp.MustCompile(`dial unix .*: connect: (connection refused|no such file or directory)|Failed to load plugin schemas|failed to instantiate provider`)
+
+// isProviderUnavailable returns whether the given Terraform CLI output
+// reports that the native provider could not be reached.
+func isProviderUnavailable(out []byte) bool {
+	return reProviderUnavailable.Match(out)
+}
+
+// runnerProbe is implemented by the ProviderRunners whose native provider
+// processes can be checked.
+type runnerProbe interface {
+	// HealthCheck checks whether the native provider is serving.
+	HealthCheck(ctx context.Context) error
+	// RSS returns the resident set size of the native provider process in
+	// bytes.
+	RSS() (uint64, error)
+}
+
+// HealthCheck checks whether the native provider is serving using the gRPC
+// health service of its plugin server.
+func (sr *SharedProvider) HealthCheck(ctx context.Context) error {
+	sr.mu.Lock()
+	socketPath := sr.socketPath
+	sr.mu.Unlock()
+	if socketPath == "" {
+		return errors.New(errNotRunning)
+	}
+	conn, err := grpc.DialContext(ctx, "unix:"+socketPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return errors.Wrapf(err, errFmtHealthCheck, socketPath)
+	}
+	defer conn.Close() //nolint:errcheck
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: pluginHealthService})
+	if err != nil {
+		return errors.Wrapf(err, errFmtHealthCheck, socketPath)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return errors.Errorf(errFmtNotServing, resp.GetStatus())
+	}
+	return nil
+}
+
+// RSS returns the resident set size of the native provider process in bytes
+// as reported by the proc filesystem. The process is looked up by the unix
+// socket it listens on.
+func (sr *SharedProvider) RSS() (uint64, error) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	if sr.socketPath == "" {
+		return 0, errors.New(errNotRunning)
+	}
+	if sr.pid == 0 {
+		pid, err := socketOwner(sr.procFS, sr.socketPath)
+		if err != nil {
+			return 0, err
+		}
+		sr.pid = pid
+	}
+	return readRSS(sr.procFS, sr.pid)
+}
+
+// socketOwner returns the ID of the process listening on the unix socket
+// with the given path by matching the inode of the socket in net/unix with
+// the file descriptors of the processes.
+func socketOwner(procFS, socketPath string) (int, error) {
+	data, err := os.ReadFile(filepath.Join(procFS, "net", "unix"))
+	if err != nil {
+		return 0, errors.Wrapf(err, errFmtSocketNotFound, socketPath)
+	}
+	inode := ""
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		// Num RefCount Protocol Flags Type St Inode Path
+		fields := strings.Fields(s.Text())
+		if len(fields) == 8 && fields[7] == socketPath {
+			inode = fields[6]
+			break
+		}
+	}
+	if inode == "" {
+		return 0, errors.Errorf(errFmtSocketNotFound, socketPath)
+	}
+	target := "socket:[" + inode + "]"
+	entries, err := os.ReadDir(procFS)
+	if err != nil {
+		return 0, errors.Wrapf(err, errFmtSocketOwner, socketPath)
+	}
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		fdDir := filepath.Join(procFS, e.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			// the process may have exited or may not be accessible
+			continue
+		}
+		for _, fd := range fds {
+			if l, err := os.Readlink(filepath.Join(fdDir, fd.Name())); err == nil && l == target {
+				return pid, nil
+			}
+		}
+	}
+	return 0, errors.Errorf(errFmtSocketOwner, socketPath)
+}
+
+// readRSS reads the resident set size of the process with the given ID from
+// its status file.
+func readRSS(procFS string, pid int) (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(procFS, strconv.Itoa(pid), "status"))
+	if err != nil {
+		return 0, errors.Wrapf(err, errFmtReadRSS, pid)
+	}
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		// VmRSS:	   12345 kB
+		fields := strings.Fields(s.Text())
+		if len(fields) != 3 || fields[0] != "VmRSS:" {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, errors.Wrapf(err, errFmtReadRSS, pid)
+		}
+		return kb * 1024, nil
+	}
+	return 0, errors.Errorf(errFmtReadRSS, pid)
+}
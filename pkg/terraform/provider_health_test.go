@@ -0,0 +1,255 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package terraform
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestSharedProviderHealthCheck(t *testing.T) {
+	dir, err := os.MkdirTemp("", "upjet-health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+	socketPath := filepath.Join(dir, "plugin.sock")
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(l) //nolint:errcheck
+	defer srv.Stop()
+
+	cases := map[string]struct {
+		socketPath string
+		status     healthpb.HealthCheckResponse_ServingStatus
+		wantErr    bool
+	}{
+		"Serving": {
+			socketPath: socketPath,
+			status:     healthpb.HealthCheckResponse_SERVING,
+		},
+		"NotServing": {
+			socketPath: socketPath,
+			status:     healthpb.HealthCheckResponse_NOT_SERVING,
+			wantErr:    true,
+		},
+		"Unreachable": {
+			socketPath: filepath.Join(dir, "missing.sock"),
+			status:     healthpb.HealthCheckResponse_SERVING,
+			wantErr:    true,
+		},
+		"NotRunning": {
+			wantErr: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			hs.SetServingStatus(pluginHealthService, tc.status)
+			sr := &SharedProvider{socketPath: tc.socketPath, mu: &sync.Mutex{}}
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			err := sr.HealthCheck(ctx)
+			if diff := cmp.Diff(tc.wantErr, err != nil); diff != "" {
+				t.Errorf("\n%s\nHealthCheck(...): -want error, +got error:\n%s\n%v", name, diff, err)
+			}
+		})
+	}
+}
+
+func TestSharedProviderRSS(t *testing.T) {
+	procFS := t.TempDir()
+	files := map[string]string{
+		"net/unix": `Num       RefCount Protocol Flags    Type St Inode Path
+0000000000000000: 00000002 00000000 00010000 0001 01 1111 /tmp/other
+0000000000000000: 00000002 00000000 00010000 0001 01 2222 /tmp/plugin-socket
+0000000000000000: 00000003 00000000 00000000 0001 03 3333
+`,
+		"100/status": "Name:\tterraform-provider-other\nVmRSS:\t    1024 kB\n",
+		"200/status": "Name:\tterraform-provider-test\nVmRSS:\t  204800 kB\n",
+	}
+	for f, content := range files {
+		if err := os.MkdirAll(filepath.Join(procFS, filepath.Dir(f)), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(procFS, f), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"100/fd/3": "socket:[1111]",
+		"200/fd/0": "/dev/null",
+		"200/fd/7": "socket:[2222]",
+	}
+	for l, target := range links {
+		if err := os.MkdirAll(filepath.Join(procFS, filepath.Dir(l)), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(target, filepath.Join(procFS, l)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := map[string]struct {
+		socketPath string
+		want       uint64
+		wantErr    bool
+	}{
+		"Found": {
+			socketPath: "/tmp/plugin-socket",
+			want:       204800 * 1024,
+		},
+		"UnknownSocket": {
+			socketPath: "/tmp/unknown",
+			wantErr:    true,
+		},
+		"NotRunning": {
+			wantErr: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			sr := &SharedProvider{socketPath: tc.socketPath, procFS: procFS, mu: &sync.Mutex{}}
+			got, err := sr.RSS()
+			if diff := cmp.Diff(tc.wantErr, err != nil); diff != "" {
+				t.Errorf("\n%s\nRSS(): -want error, +got error:\n%s\n%v", name, diff, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nRSS(): -want, +got:\n%s", name, diff)
+			}
+		})
+	}
+}
+
+func TestIsProviderUnavailable(t *testing.T) {
+	cases := map[string]struct {
+		out  string
+		want bool
+	}{
+		"ConnectionRefused": {
+			out:  `Error: Failed to load plugin schemas: ... rpc error: code = Unavailable desc = connection error: desc = "transport: Error while dialing: dial unix /tmp/plugin123: connect: connection refused"`,
+			want: true,
+		},
+		"SocketRemoved": {
+			out:  `dial unix /tmp/plugin123: connect: no such file or directory`,
+			want: true,
+		},
+		"ProviderError": {
+			out:  `Error: creating EC2 Instance: UnauthorizedOperation`,
+			want: false,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, isProviderUnavailable([]byte(tc.out))); diff != "" {
+				t.Errorf("\n%s\nisProviderUnavailable(...): -want, +got:\n%s", name, diff)
+			}
+		})
+	}
+}
+
+type fakeProbeRunner struct {
+	fakeRunner
+	healthErr error
+	rss       uint64
+}
+
+func (f *fakeProbeRunner) HealthCheck(context.Context) error {
+	return f.healthErr
+}
+
+func (f *fakeProbeRunner) RSS() (uint64, error) {
+	return f.rss, nil
+}
+
+func TestSharedProviderSchedulerCheckRunners(t *testing.T) {
+	type want struct {
+		replaced        bool
+		stopped         bool
+		invocationCount int
+	}
+	cases := map[string]struct {
+		runner *fakeProbeRunner
+		inUse  bool
+		checks int
+		want   want
+	}{
+		"Healthy": {
+			runner: &fakeProbeRunner{rss: 100},
+			checks: 2,
+			want:   want{invocationCount: 1},
+		},
+		"UnhealthyOnce": {
+			runner: &fakeProbeRunner{healthErr: errors.New("boom")},
+			checks: 1,
+			want:   want{invocationCount: 1},
+		},
+		"UnhealthyIsRestarted": {
+			runner: &fakeProbeRunner{healthErr: errors.New("boom")},
+			checks: 2,
+			want:   want{replaced: true, stopped: true},
+		},
+		"ExceedsMaxRSSNotInUse": {
+			runner: &fakeProbeRunner{rss: 2000},
+			checks: 1,
+			want:   want{replaced: true, stopped: true},
+		},
+		"ExceedsMaxRSSInUse": {
+			runner: &fakeProbeRunner{rss: 2000},
+			inUse:  true,
+			checks: 1,
+			want:   want{invocationCount: 10},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := newTestScheduler(clocktesting.NewFakeClock(time.Now()), 10, WithMaxRSS(1000))
+			s.newRunner = func(logging.Logger) ProviderRunner {
+				return tc.runner
+			}
+			inUse, _, err := s.Start("h")
+			if err != nil {
+				t.Fatal(err)
+			}
+			inUse.Increment()
+			if !tc.inUse {
+				inUse.Decrement()
+			}
+			entry := s.runners["h"][0]
+			s.newRunner = func(logging.Logger) ProviderRunner {
+				return &fakeProbeRunner{}
+			}
+			for i := 0; i < tc.checks; i++ {
+				s.checkRunners(context.Background())
+			}
+			if diff := cmp.Diff(tc.want.replaced, !s.scheduled(entry)); diff != "" {
+				t.Errorf("\n%s\ncheckRunners(...): -want replaced, +got replaced:\n%s", name, diff)
+			}
+			if diff := cmp.Diff(tc.want.stopped, tc.runner.stopped); diff != "" {
+				t.Errorf("\n%s\ncheckRunners(...): -want stopped, +got stopped:\n%s", name, diff)
+			}
+			if diff := cmp.Diff(tc.want.invocationCount, entry.invocationCount); diff != "" && !tc.want.replaced {
+				t.Errorf("\n%s\ncheckRunners(...): -want invocationCount, +got invocationCount:\n%s", name, diff)
+			}
+		})
+	}
+}
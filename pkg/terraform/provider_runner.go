@@ -80,6 +80,11 @@ type SharedProvider struct {
 	nativeProviderPath string
 	nativeProviderArgs []string
 	reattachConfig     string
+	// socketPath is the path of the unix socket the native provider
+	// listens on and pid is its process ID once it's looked up.
+	socketPath         string
+	pid                int
+	procFS             string
 	nativeProviderName string
 	protocolVersion    int
 	logger             logging.Logger
@@ -145,6 +150,7 @@ func NewSharedProvider(opts ...SharedProviderOption) *SharedProvider {
 		executor:        exec.New(),
 		clock:           clock.RealClock{},
 		mu:              &sync.Mutex{},
+		procFS:          defaultProcFS,
 	}
 	for _, o := range opts {
 		o(sr)
@@ -167,15 +173,17 @@ func (sr *SharedProvider) Start() (string, error) { //nolint:gocyclo
 	}
 	log.Debug("Provider runner not yet started. Will fork a new native provider.")
 	errCh := make(chan error, 1)
-	reattachCh := make(chan string, 1)
+	socketCh := make(chan string, 1)
 	sr.stopCh = make(chan bool, 1)
 
 	go func() {
 		defer close(errCh)
-		defer close(reattachCh)
+		defer close(socketCh)
 		defer func() {
 			sr.mu.Lock()
 			sr.reattachConfig = ""
+			sr.socketPath = ""
+			sr.pid = 0
 			sr.mu.Unlock()
 		}()
 		//#nosec G204 no user input
@@ -198,7 +206,7 @@ func (sr *SharedProvider) Start() (string, error) { //nolint:gocyclo
 			if matches == nil {
 				continue
 			}
-			reattachCh <- fmt.Sprintf(fmtReattachEnv, sr.nativeProviderName, sr.protocolVersion, os.Getpid(), matches[1])
+			socketCh <- matches[1]
 			break
 		}
 
@@ -218,8 +226,9 @@ func (sr *SharedProvider) Start() (string, error) { //nolint:gocyclo
 	}()
 
 	select {
-	case reattachConfig := <-reattachCh:
-		sr.reattachConfig = reattachConfig
+	case socketPath := <-socketCh:
+		sr.socketPath = socketPath
+		sr.reattachConfig = fmt.Sprintf(fmtReattachEnv, sr.nativeProviderName, sr.protocolVersion, os.Getpid(), socketPath)
 		return sr.reattachConfig, nil
 	case err := <-errCh:
 		return "", err
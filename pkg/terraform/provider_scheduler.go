@@ -15,11 +15,17 @@
 package terraform
 
 import (
+	"context"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/crossplane/crossplane-runtime/pkg/logging"
 	"github.com/pkg/errors"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 
+	"github.com/upbound/upjet/pkg/metrics"
 	tferrors "github.com/upbound/upjet/pkg/terraform/errors"
 )
 
@@ -33,6 +39,18 @@ const (
 	InvalidProviderHandle ProviderHandle = ""
 
 	ttlMargin = 0.1
+	// healthCheckTimeout is the timeout of a health check of a native
+	// provider.
+	healthCheckTimeout = 10 * time.Second
+	// maxHealthCheckFailures is the number of the consecutive failed health
+	// checks after which a native provider is restarted.
+	maxHealthCheckFailures = 2
+	// reservationTimeout is the duration after which the reservation of a
+	// runner handed out by the scheduler but not used yet expires, e.g.,
+	// because its caller has failed before running a Terraform CLI command.
+	reservationTimeout = 5 * time.Minute
+
+	errSchedulerClosed = "the provider scheduler is closed"
 )
 
 // ProviderScheduler represents a shared native plugin process scheduler.
@@ -56,6 +74,16 @@ type InUse interface {
 	Decrement()
 }
 
+// ProviderRestarter is implemented by the InUses of the schedulers that can
+// restart an unavailable native provider, so that the Terraform CLI
+// invocations that fail to reach it can be retried.
+type ProviderRestarter interface {
+	// Restart replaces the native provider of the InUse unless it has
+	// already been replaced, and returns the InUse and the reattach
+	// configuration of the replacement.
+	Restart() (InUse, string, error)
+}
+
 // noopInUse satisfies the InUse interface and is a noop implementation.
 type noopInUse struct{}
 
@@ -83,30 +111,92 @@ func (NoOpProviderScheduler) Stop(ProviderHandle) error {
 
 type schedulerEntry struct {
 	ProviderRunner
+	handle          ProviderHandle
 	inUse           int
 	invocationCount int
+	startedAt       time.Time
+	// lastUsed is when the runner was last scheduled or released.
+	lastUsed time.Time
+	// reattachConfig is the last reattach configuration returned by the
+	// runner and reattachedAt is when it was first returned.
+	reattachConfig string
+	reattachedAt   time.Time
+	// healthFailures is the number of the consecutive failed health checks.
+	healthFailures int
+	// draining is set when the runner is being replaced. A draining runner
+	// is not scheduled anymore and is stopped when it's no longer in use.
+	draining bool
+	// reservations is the number of the callers that the runner has been
+	// handed out to but that have not used it yet, and reservedAt is when
+	// it was last handed out. A reserved runner is not stopped even if it's
+	// not in use, as its callers are about to use its reattach
+	// configuration.
+	reservations int
+	reservedAt   time.Time
+}
+
+// setReattachConfig records the reattach configuration returned by the
+// runner.
+func (e *schedulerEntry) setReattachConfig(rc string, now time.Time) {
+	if rc != e.reattachConfig {
+		e.reattachConfig = rc
+		e.reattachedAt = now
+	}
 }
 
 type providerInUse struct {
 	scheduler *SharedProviderScheduler
 	handle    ProviderHandle
+	entry     *schedulerEntry
+	// reserved is set until the runner is used for the first time.
+	reserved bool
 }
 
 func (p *providerInUse) Increment() {
 	p.scheduler.mu.Lock()
 	defer p.scheduler.mu.Unlock()
-	r := p.scheduler.runners[p.handle]
-	r.inUse++
-	r.invocationCount++
+	p.entry.inUse++
+	p.entry.invocationCount++
+	if p.reserved {
+		p.reserved = false
+		if p.entry.reservations > 0 {
+			p.entry.reservations--
+		}
+	}
 }
 
 func (p *providerInUse) Decrement() {
-	p.scheduler.mu.Lock()
-	defer p.scheduler.mu.Unlock()
-	if p.scheduler.runners[p.handle].inUse == 0 {
+	s := p.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p.entry.lastUsed = s.clock.Now()
+	if p.entry.inUse == 0 {
 		return
 	}
-	p.scheduler.runners[p.handle].inUse--
+	p.entry.inUse--
+	// a draining runner is stopped as soon as it's released by its last
+	// user, as its replacement has already been started.
+	if p.entry.draining && s.free(p.entry) && s.scheduled(p.entry) {
+		s.stopRunner(p.entry, metrics.SchedulerOperationStop)
+	}
+}
+
+func (p *providerInUse) Restart() (InUse, string, error) {
+	s := p.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.scheduled(p.entry) {
+		// the runner has already been replaced, so one of the scheduled
+		// runners is used instead.
+		return s.start(p.handle)
+	}
+	s.logger.Debug("Restarting the unavailable provider runner.", "handle", p.handle)
+	r := s.replaceRunner(p.entry)
+	now := s.clock.Now()
+	r.lastUsed = now
+	rc, err := r.Start()
+	r.setReattachConfig(rc, now)
+	return s.reserve(r, now), rc, errors.Wrapf(err, "cannot restart the shared provider runner for handle: %s", p.handle)
 }
 
 // SharedProviderScheduler is a ProviderScheduler that
@@ -114,14 +204,29 @@ func (p *providerInUse) Decrement() {
 // MR reconciliation loops whose MRs yield the same ProviderHandle, i.e.,
 // whose Terraform resource blocks are configuration-wise identical.
 // SharedProviderScheduler is configured with a max TTL and it will gracefully
-// attempt to replace ProviderRunners whose TTL exceed this maximum,
-// if they are not in-use.
+// replace ProviderRunners whose TTL exceed this maximum by starting their
+// replacements and by stopping them once they are no longer in-use.
+// It can also be configured to run a pool of runners per ProviderHandle,
+// to stop the runners that have been idle for a while and to limit the
+// number of the concurrently running native providers.
 type SharedProviderScheduler struct {
-	runnerOpts []SharedProviderOption
-	runners    map[ProviderHandle]*schedulerEntry
-	ttl        int
-	mu         *sync.Mutex
-	logger     logging.Logger
+	runnerOpts          []SharedProviderOption
+	runners             map[ProviderHandle][]*schedulerEntry
+	ttl                 int
+	poolSize            int
+	idleTimeout         time.Duration
+	maxRunners          int
+	healthCheckInterval time.Duration
+	maxRSS              uint64
+	mu                  *sync.Mutex
+	logger              logging.Logger
+	clock               clock.WithTicker
+	// done is closed when the scheduler is closed to stop its background
+	// checks.
+	done   chan struct{}
+	closed bool
+	// newRunner returns a new ProviderRunner with the given logger.
+	newRunner func(logging.Logger) ProviderRunner
 }
 
 // SharedProviderSchedulerOption represents an option to configure the
@@ -136,66 +241,541 @@ func WithSharedProviderOptions(opts ...SharedProviderOption) SharedProviderSched
 	}
 }
 
+// WithPoolSize configures the maximum number of the native provider runners
+// per ProviderHandle. The least loaded runner of a handle is scheduled and a
+// new runner is started for the handle if all of its runners are in use and
+// the pool is not full yet. The runners being replaced because of their TTLs
+// do not count towards the pool size. The default is one runner per handle.
+func WithPoolSize(n int) SharedProviderSchedulerOption {
+	return func(scheduler *SharedProviderScheduler) {
+		scheduler.poolSize = n
+	}
+}
+
+// WithIdleTimeout configures the scheduler to stop the native provider
+// runners that have not been in use for the given duration. A zero
+// duration, which is the default, keeps the runners running until they
+// expire.
+func WithIdleTimeout(d time.Duration) SharedProviderSchedulerOption {
+	return func(scheduler *SharedProviderScheduler) {
+		scheduler.idleTimeout = d
+	}
+}
+
+// WithMaxRunners configures the maximum number of the concurrently running
+// native providers. When the maximum is reached, the least recently used
+// runner that is not in use is evicted to start a new one, and the callers
+// are asked to retry if all the runners are in use. Zero, which is the
+// default, means no limit.
+func WithMaxRunners(n int) SharedProviderSchedulerOption {
+	return func(scheduler *SharedProviderScheduler) {
+		scheduler.maxRunners = n
+	}
+}
+
+// WithHealthCheckInterval configures the scheduler to check the health of
+// the native providers at the given interval using their gRPC health
+// services. A native provider failing consecutive health checks is
+// restarted. Zero, which is the default, disables the health checks.
+func WithHealthCheckInterval(d time.Duration) SharedProviderSchedulerOption {
+	return func(scheduler *SharedProviderScheduler) {
+		scheduler.healthCheckInterval = d
+	}
+}
+
+// WithMaxRSS configures the scheduler to recycle the native providers whose
+// resident set sizes exceed the given number of bytes. The sizes are read
+// from the proc filesystem during the health checks, so the option has no
+// effect unless the health checks are enabled. A native provider exceeding
+// the limit is stopped if it's not in use, otherwise it's replaced
+// gracefully as if its TTL has expired. Zero, which is the default, disables
+// the recycling.
+func WithMaxRSS(bytes uint64) SharedProviderSchedulerOption {
+	return func(scheduler *SharedProviderScheduler) {
+		scheduler.maxRSS = bytes
+	}
+}
+
 // NewSharedProviderScheduler initializes a new SharedProviderScheduler
 // with the specified logger and options.
 func NewSharedProviderScheduler(l logging.Logger, ttl int, opts ...SharedProviderSchedulerOption) *SharedProviderScheduler {
 	scheduler := &SharedProviderScheduler{
-		mu:      &sync.Mutex{},
-		runners: make(map[ProviderHandle]*schedulerEntry),
-		logger:  l,
-		ttl:     ttl,
+		mu:       &sync.Mutex{},
+		runners:  make(map[ProviderHandle][]*schedulerEntry),
+		logger:   l,
+		ttl:      ttl,
+		poolSize: 1,
+		clock:    clock.RealClock{},
+		done:     make(chan struct{}),
+	}
+	scheduler.newRunner = func(l logging.Logger) ProviderRunner {
+		runner := NewSharedProvider(scheduler.runnerOpts...)
+		runner.logger = l
+		return runner
 	}
 	for _, o := range opts {
 		o(scheduler)
 	}
+	if scheduler.poolSize < 1 {
+		scheduler.poolSize = 1
+	}
+	if scheduler.idleTimeout > 0 {
+		go scheduler.reapIdleRunners(scheduler.idleTimeout / 2)
+	}
+	if scheduler.healthCheckInterval > 0 {
+		go scheduler.checkRunnersPeriodically(scheduler.healthCheckInterval)
+	}
 	return scheduler
 }
 
 func (s *SharedProviderScheduler) Start(h ProviderHandle) (InUse, string, error) {
-	logger := s.logger.WithValues("handle", h, "ttl", s.ttl, "ttlMargin", ttlMargin)
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	return s.start(h)
+}
 
-	r := s.runners[h]
-	switch {
-	case r != nil && (r.invocationCount < s.ttl || r.inUse > 0):
-		if r.invocationCount > int(float64(s.ttl)*(1+ttlMargin)) {
-			logger.Debug("Reuse budget has been exceeded. Caller will need to retry.")
-			return nil, "", tferrors.NewRetryScheduleError(r.invocationCount, s.ttl)
+// start schedules the least loaded runner of the given handle, starting a
+// new one if needed. The caller must hold the lock.
+func (s *SharedProviderScheduler) start(h ProviderHandle) (InUse, string, error) {
+	if s.closed {
+		return nil, "", errors.New(errSchedulerClosed)
+	}
+	logger := s.logger.WithValues("handle", h, "ttl", s.ttl, "ttlMargin", ttlMargin, "poolSize", s.poolSize)
+	s.drainExpired(h)
+
+	now := s.clock.Now()
+	r, active := s.leastLoaded(h)
+	if r == nil || (r.inUse > 0 && active < s.poolSize) {
+		if s.maxRunners == 0 || s.count() < s.maxRunners || s.evictLRU() {
+			r = s.newEntry(h, now)
+			logger.Debug("Starting new shared provider...", "runners", active+1)
+			metrics.ProviderSchedulerOperations.WithLabelValues(metrics.SchedulerOperationStart).Inc()
+			rc, err := r.Start()
+			r.setReattachConfig(rc, now)
+			return s.reserve(r, now), rc, errors.Wrapf(err, "cannot start the shared provider runner for handle: %s", h)
 		}
-
-		logger.Debug("Reusing the provider runner", "invocationCount", r.invocationCount, "inUse", r.inUse)
-		rc, err := r.Start()
-		return &providerInUse{
-			scheduler: s,
-			handle:    h,
-		}, rc, errors.Wrapf(err, "cannot use already started provider with handle: %s", h)
-	case r != nil:
-		logger.Debug("The provider runner has expired. Attempting to stop...", "invocationCount", r.invocationCount, "inUse", r.inUse)
-		if err := r.Stop(); err != nil {
-			return nil, "", errors.Wrapf(err, "cannot schedule a new shared provider for handle: %s", h)
+		if r == nil {
+			// as long as its reuse budget allows, a draining runner is
+			// scheduled until a replacement can be started.
+			r = s.leastUsedDraining(h)
+			switch {
+			case r == nil:
+				logger.Debug("Maximum number of the provider runners has been reached and all of them are in use. Caller will need to retry.", "maxRunners", s.maxRunners)
+				metrics.ProviderSchedulerOperations.WithLabelValues(metrics.SchedulerOperationRetry).Inc()
+				return nil, "", tferrors.NewRetryScheduleCapacityError(s.maxRunners)
+			case r.invocationCount > int(float64(s.ttl)*(1+ttlMargin)):
+				logger.Debug("Reuse budget has been exceeded. Caller will need to retry.", "invocationCount", r.invocationCount)
+				metrics.ProviderSchedulerOperations.WithLabelValues(metrics.SchedulerOperationRetry).Inc()
+				return nil, "", tferrors.NewRetryScheduleError(r.invocationCount, s.ttl)
+			}
 		}
+		logger.Debug("Maximum number of the provider runners has been reached. Reusing a started one.", "maxRunners", s.maxRunners)
 	}
 
-	runner := NewSharedProvider(s.runnerOpts...)
-	r = &schedulerEntry{
-		ProviderRunner: runner,
+	logger.Debug("Reusing the provider runner", "invocationCount", r.invocationCount, "inUse", r.inUse)
+	r.lastUsed = now
+	rc, err := r.Start()
+	r.setReattachConfig(rc, now)
+	return s.reserve(r, now), rc, errors.Wrapf(err, "cannot use already started provider with handle: %s", h)
+}
+
+// reserve hands out the given runner reserving it until it's used by the
+// returned InUse for the first time, so that it's not stopped before its
+// caller has a chance to use it. The caller must hold the lock.
+func (s *SharedProviderScheduler) reserve(r *schedulerEntry, now time.Time) *providerInUse {
+	if !s.reserved(r) {
+		// drop the expired reservations.
+		r.reservations = 0
 	}
-	runner.logger = logger
-	s.runners[h] = r
-	logger.Debug("Starting new shared provider...")
-	rc, err := s.runners[h].Start()
+	r.reservations++
+	r.reservedAt = now
 	return &providerInUse{
 		scheduler: s,
-		handle:    h,
-	}, rc, errors.Wrapf(err, "cannot start the shared provider runner for handle: %s", h)
+		handle:    r.handle,
+		entry:     r,
+		reserved:  true,
+	}
+}
+
+// Close stops the background checks of the scheduler and all of its native
+// provider runners. The scheduler does not schedule any runners after it's
+// closed.
+func (s *SharedProviderScheduler) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	for _, r := range s.entries() {
+		s.stopRunner(r, metrics.SchedulerOperationStop)
+	}
+	return nil
 }
 
-func (s *SharedProviderScheduler) Stop(ProviderHandle) error {
-	// noop
+// Runnable returns a controller manager Runnable that closes the scheduler
+// when the manager is stopped.
+func (s *SharedProviderScheduler) Runnable() manager.Runnable {
+	return schedulerCloser{scheduler: s}
+}
+
+// schedulerCloser closes a SharedProviderScheduler when its context is done.
+type schedulerCloser struct {
+	scheduler *SharedProviderScheduler
+}
+
+func (c schedulerCloser) Start(ctx context.Context) error {
+	<-ctx.Done()
+	return errors.Wrap(c.scheduler.Close(), "cannot close the provider scheduler")
+}
+
+// NeedLeaderElection returns false as the runners are started by every
+// replica.
+func (c schedulerCloser) NeedLeaderElection() bool {
+	return false
+}
+
+// Stop stops the provider runners of the given handle if they are not in use
+// and they have been idle for longer than the idle timeout. As it's called
+// whenever an external client disconnects, the runners that are not idle are
+// kept running to be shared by the subsequent reconciliations.
+func (s *SharedProviderScheduler) Stop(h ProviderHandle) error {
+	if s.idleTimeout <= 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.runners[h] {
+		if s.isIdle(r) {
+			s.stopRunner(r, metrics.SchedulerOperationStop)
+		}
+	}
 	return nil
 }
 
+// Runners returns the information about the scheduled provider runners
+// sorted by their handles and start times.
+func (s *SharedProviderScheduler) Runners() []RunnerInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]RunnerInfo, 0, s.count())
+	for h, pool := range s.runners {
+		for _, r := range pool {
+			ri := RunnerInfo{
+				Handle:          h,
+				StartedAt:       r.startedAt,
+				LastUsed:        r.lastUsed,
+				ReattachConfig:  r.reattachConfig,
+				InvocationCount: r.invocationCount,
+				InUse:           r.inUse,
+				TTL:             s.ttl,
+				TTLBudget:       s.ttl - r.invocationCount,
+				Draining:        r.draining,
+			}
+			if r.reattachConfig != "" {
+				ri.ReattachConfigAge = s.clock.Since(r.reattachedAt)
+			}
+			result = append(result, ri)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Handle != result[j].Handle {
+			return result[i].Handle < result[j].Handle
+		}
+		return result[i].StartedAt.Before(result[j].StartedAt)
+	})
+	return result
+}
+
+// newEntry schedules a new runner for the given handle. The caller must hold
+// the lock.
+func (s *SharedProviderScheduler) newEntry(h ProviderHandle, now time.Time) *schedulerEntry {
+	r := &schedulerEntry{
+		ProviderRunner: s.newRunner(s.logger.WithValues("handle", h, "ttl", s.ttl, "ttlMargin", ttlMargin)),
+		handle:         h,
+		startedAt:      now,
+		lastUsed:       now,
+	}
+	s.runners[h] = append(s.runners[h], r)
+	return r
+}
+
+// replaceRunner stops the given runner and schedules a new runner for its
+// handle in its place. The caller must hold the lock and start the returned
+// runner.
+func (s *SharedProviderScheduler) replaceRunner(old *schedulerEntry) *schedulerEntry {
+	if err := old.Stop(); err != nil {
+		s.logger.Debug("Failed to stop the provider runner being replaced", "handle", old.handle, "error", err)
+	}
+	s.remove(old)
+	metrics.ProviderSchedulerOperations.WithLabelValues(metrics.SchedulerOperationRestart).Inc()
+	return s.newEntry(old.handle, s.clock.Now())
+}
+
+// leastLoaded returns the runner of the given handle with the fewest users
+// that is not draining, and the number of such runners. The caller must hold
+// the lock.
+func (s *SharedProviderScheduler) leastLoaded(h ProviderHandle) (*schedulerEntry, int) {
+	var (
+		result *schedulerEntry
+		active int
+	)
+	for _, r := range s.runners[h] {
+		if r.draining {
+			continue
+		}
+		active++
+		if result == nil || r.inUse < result.inUse ||
+			(r.inUse == result.inUse && r.invocationCount < result.invocationCount) {
+			result = r
+		}
+	}
+	return result, active
+}
+
+// leastUsedDraining returns the draining runner of the given handle with the
+// fewest invocations, if any. The caller must hold the lock.
+func (s *SharedProviderScheduler) leastUsedDraining(h ProviderHandle) *schedulerEntry {
+	var result *schedulerEntry
+	for _, r := range s.runners[h] {
+		if r.draining && (result == nil || r.invocationCount < result.invocationCount) {
+			result = r
+		}
+	}
+	return result
+}
+
+// drainExpired marks the runners of the given handle whose TTLs have expired
+// as draining, so that they are replaced, and stops the draining runners that
+// are no longer in use. The caller must hold the lock.
+func (s *SharedProviderScheduler) drainExpired(h ProviderHandle) {
+	for _, r := range s.runners[h] {
+		if !r.draining && r.invocationCount >= s.ttl {
+			s.logger.Debug("The provider runner has expired. Draining...", "handle", h, "invocationCount", r.invocationCount, "inUse", r.inUse)
+			r.draining = true
+		}
+		if r.draining && s.free(r) {
+			s.stopRunner(r, metrics.SchedulerOperationStop)
+		}
+	}
+}
+
+// count returns the number of the scheduled runners. The caller must hold the
+// lock.
+func (s *SharedProviderScheduler) count() int {
+	n := 0
+	for _, pool := range s.runners {
+		n += len(pool)
+	}
+	return n
+}
+
+// scheduled returns whether the given runner is still scheduled. The caller
+// must hold the lock.
+func (s *SharedProviderScheduler) scheduled(r *schedulerEntry) bool {
+	for _, e := range s.runners[r.handle] {
+		if e == r {
+			return true
+		}
+	}
+	return false
+}
+
+// remove removes the given runner from the pool of its handle. The caller
+// must hold the lock.
+func (s *SharedProviderScheduler) remove(r *schedulerEntry) {
+	pool := s.runners[r.handle]
+	result := make([]*schedulerEntry, 0, len(pool))
+	for _, e := range pool {
+		if e != r {
+			result = append(result, e)
+		}
+	}
+	if len(result) == 0 {
+		delete(s.runners, r.handle)
+		return
+	}
+	s.runners[r.handle] = result
+}
+
+func (s *SharedProviderScheduler) isIdle(r *schedulerEntry) bool {
+	return s.free(r) && s.clock.Since(r.lastUsed) >= s.idleTimeout
+}
+
+// reserved returns whether the given runner has been handed out but not
+// used yet, unless its reservation has expired. The caller must hold the
+// lock.
+func (s *SharedProviderScheduler) reserved(r *schedulerEntry) bool {
+	return r.reservations > 0 && s.clock.Since(r.reservedAt) < reservationTimeout
+}
+
+// free returns whether the given runner can be stopped, i.e., it's neither
+// in use nor reserved. The caller must hold the lock.
+func (s *SharedProviderScheduler) free(r *schedulerEntry) bool {
+	return r.inUse == 0 && !s.reserved(r)
+}
+
+// stopRunner stops the given runner and removes it from the scheduler
+// recording the given operation. The caller must hold the lock.
+func (s *SharedProviderScheduler) stopRunner(r *schedulerEntry, operation string) {
+	s.logger.Debug("Stopping the provider runner.", "handle", r.handle, "operation", operation, "lastUsed", r.lastUsed, "invocationCount", r.invocationCount)
+	// the runner is removed even if it cannot be stopped, e.g., because
+	// its native provider has never started.
+	if err := r.Stop(); err != nil {
+		s.logger.Debug("Failed to stop the provider runner", "handle", r.handle, "error", err)
+	}
+	s.remove(r)
+	metrics.ProviderSchedulerOperations.WithLabelValues(operation).Inc()
+}
+
+// evictLRU stops the least recently used runner that is neither in use nor
+// reserved. It returns false if there's no such runner. The caller must hold
+// the lock.
+func (s *SharedProviderScheduler) evictLRU() bool {
+	var lru *schedulerEntry
+	for _, pool := range s.runners {
+		for _, r := range pool {
+			if s.free(r) && (lru == nil || r.lastUsed.Before(lru.lastUsed)) {
+				lru = r
+			}
+		}
+	}
+	if lru == nil {
+		return false
+	}
+	s.stopRunner(lru, metrics.SchedulerOperationEvict)
+	return true
+}
+
+// entries returns all the scheduled runners. The caller must hold the lock.
+func (s *SharedProviderScheduler) entries() []*schedulerEntry {
+	result := make([]*schedulerEntry, 0, s.count())
+	for _, pool := range s.runners {
+		result = append(result, pool...)
+	}
+	return result
+}
+
+// reapIdle stops the runners that have been idle for longer than the idle
+// timeout.
+func (s *SharedProviderScheduler) reapIdle() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.entries() {
+		if s.isIdle(r) {
+			s.stopRunner(r, metrics.SchedulerOperationStop)
+		}
+	}
+}
+
+// checkRunners checks the health and the resident set sizes of the native
+// providers. The unhealthy ones are restarted and the ones exceeding the
+// maximum resident set size are recycled.
+func (s *SharedProviderScheduler) checkRunners(ctx context.Context) {
+	s.mu.Lock()
+	runners := s.entries()
+	s.mu.Unlock()
+	// the checks are made without holding the lock as they may take a while.
+	for _, r := range runners {
+		p, ok := r.ProviderRunner.(runnerProbe)
+		if !ok {
+			continue
+		}
+		hctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		err := p.HealthCheck(hctx)
+		cancel()
+		if err != nil {
+			s.onUnhealthy(r, err)
+			continue
+		}
+		var rss uint64
+		if s.maxRSS > 0 {
+			if rss, err = p.RSS(); err != nil {
+				s.logger.Debug("Cannot read the resident set size of the native provider", "handle", r.handle, "error", err)
+			}
+		}
+		s.onHealthy(r, rss)
+	}
+}
+
+func (s *SharedProviderScheduler) onUnhealthy(r *schedulerEntry, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.scheduled(r) {
+		return
+	}
+	r.healthFailures++
+	s.logger.Debug("Native provider health check failed", "handle", r.handle, "failures", r.healthFailures, "error", err)
+	if r.healthFailures < maxHealthCheckFailures {
+		return
+	}
+	s.logger.Info("Restarting the unhealthy native provider", "handle", r.handle, "error", err)
+	r = s.replaceRunner(r)
+	now := s.clock.Now()
+	rc, err := r.Start()
+	if err != nil {
+		s.logger.Info("Failed to restart the native provider", "handle", r.handle, "error", err)
+		return
+	}
+	r.setReattachConfig(rc, now)
+}
+
+func (s *SharedProviderScheduler) onHealthy(r *schedulerEntry, rss uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.scheduled(r) {
+		return
+	}
+	r.healthFailures = 0
+	if s.maxRSS == 0 || rss <= s.maxRSS {
+		return
+	}
+	s.logger.Debug("Native provider exceeds the maximum resident set size. Recycling...", "handle", r.handle, "rss", rss, "maxRSS", s.maxRSS, "inUse", r.inUse)
+	if s.free(r) {
+		s.stopRunner(r, metrics.SchedulerOperationRecycle)
+		return
+	}
+	// let it expire, so that it's replaced and drained.
+	if r.invocationCount < s.ttl {
+		r.invocationCount = s.ttl
+		metrics.ProviderSchedulerOperations.WithLabelValues(metrics.SchedulerOperationRecycle).Inc()
+	}
+}
+
+func (s *SharedProviderScheduler) checkRunnersPeriodically(interval time.Duration) {
+	// the ongoing checks are canceled when the scheduler is closed.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.done
+		cancel()
+	}()
+	t := s.clock.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-t.C():
+			s.checkRunners(ctx)
+		}
+	}
+}
+
+func (s *SharedProviderScheduler) reapIdleRunners(interval time.Duration) {
+	t := s.clock.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-t.C():
+			s.reapIdle()
+		}
+	}
+}
+
 // WorkspaceProviderScheduler is a ProviderScheduler that
 // shares a native plugin (Terraform provider) process between
 // the Terraform CLI invocations in the context of a single
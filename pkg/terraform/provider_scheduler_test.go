@@ -0,0 +1,370 @@
+/*
+Copyright 2023 Upbound Inc.
+*/
+
+package terraform
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/google/go-cmp/cmp"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	tferrors "github.com/upbound/upjet/pkg/terraform/errors"
+)
+
+type fakeRunner struct {
+	stopped bool
+}
+
+func (f *fakeRunner) Start() (string, error) {
+	return "reattach-config", nil
+}
+
+func (f *fakeRunner) Stop() error {
+	f.stopped = true
+	return nil
+}
+
+// newTestScheduler returns a SharedProviderScheduler with fake runners and
+// the given clock, which does not reap the idle runners in the background.
+func newTestScheduler(c *clocktesting.FakeClock, ttl int, opts ...SharedProviderSchedulerOption) *SharedProviderScheduler {
+	s := &SharedProviderScheduler{
+		mu:       &sync.Mutex{},
+		runners:  make(map[ProviderHandle][]*schedulerEntry),
+		logger:   logging.NewNopLogger(),
+		ttl:      ttl,
+		poolSize: 1,
+		clock:    c,
+		done:     make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	s.newRunner = func(logging.Logger) ProviderRunner {
+		return &fakeRunner{}
+	}
+	return s
+}
+
+func (s *SharedProviderScheduler) handles() []ProviderHandle {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]ProviderHandle, 0, len(s.runners))
+	for h, pool := range s.runners {
+		for range pool {
+			result = append(result, h)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i] < result[j]
+	})
+	return result
+}
+
+func TestSharedProviderSchedulerIdleTimeout(t *testing.T) {
+	type step struct {
+		start   ProviderHandle
+		use     bool
+		release bool
+		advance time.Duration
+		stop    ProviderHandle
+		reap    bool
+	}
+	cases := map[string]struct {
+		steps []step
+		want  []ProviderHandle
+	}{
+		"IdleRunnerIsStoppedOnStop": {
+			steps: []step{
+				{start: "a", use: true, release: true},
+				{advance: 2 * time.Minute, stop: "a"},
+			},
+			want: []ProviderHandle{},
+		},
+		"RecentlyUsedRunnerIsNotStopped": {
+			steps: []step{
+				{start: "a", use: true, release: true},
+				{advance: 30 * time.Second, stop: "a"},
+			},
+			want: []ProviderHandle{"a"},
+		},
+		"IdleRunnersAreReaped": {
+			steps: []step{
+				{start: "a", use: true, release: true},
+				{advance: 90 * time.Second},
+				{start: "b", use: true, release: true},
+				{advance: 30 * time.Second, reap: true},
+			},
+			want: []ProviderHandle{"b"},
+		},
+		"InUseRunnerIsNotReaped": {
+			steps: []step{
+				{start: "a", use: true},
+				{advance: 2 * time.Minute, reap: true},
+			},
+			want: []ProviderHandle{"a"},
+		},
+		"ReservedRunnerIsNotReaped": {
+			steps: []step{
+				{start: "a", use: true, release: true},
+				{advance: 2 * time.Minute, start: "a"},
+				{reap: true, stop: "a"},
+			},
+			want: []ProviderHandle{"a"},
+		},
+		"ExpiredReservationIsReaped": {
+			steps: []step{
+				{start: "a"},
+				{advance: reservationTimeout, reap: true},
+			},
+			want: []ProviderHandle{},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := clocktesting.NewFakeClock(time.Now())
+			s := newTestScheduler(c, 100, WithIdleTimeout(time.Minute))
+			for _, st := range tc.steps {
+				if st.start != "" {
+					inUse, _, err := s.Start(st.start)
+					if err != nil {
+						t.Fatalf("Start(%s): %s", st.start, err)
+					}
+					if st.use {
+						inUse.Increment()
+					}
+					if st.release {
+						inUse.Decrement()
+					}
+				}
+				c.Step(st.advance)
+				if st.stop != "" {
+					if err := s.Stop(st.stop); err != nil {
+						t.Fatalf("Stop(%s): %s", st.stop, err)
+					}
+				}
+				if st.reap {
+					s.reapIdle()
+				}
+			}
+			if diff := cmp.Diff(tc.want, s.handles()); diff != "" {
+				t.Errorf("\n%s\nrunners: -want, +got:\n%s", name, diff)
+			}
+		})
+	}
+}
+
+func TestSharedProviderSchedulerMaxRunners(t *testing.T) {
+	c := clocktesting.NewFakeClock(time.Now())
+	s := newTestScheduler(c, 100, WithMaxRunners(2))
+
+	inUseA, _, err := s.Start("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inUseA.Increment()
+	inUseA.Decrement()
+	c.Step(time.Second)
+	inUseB, _, err := s.Start("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inUseB.Increment()
+	inUseB.Decrement()
+	c.Step(time.Second)
+	// "a" is the least recently used runner.
+	runnerA := s.runners["a"][0].ProviderRunner.(*fakeRunner)
+	if _, _, err := s.Start("c"); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]ProviderHandle{"b", "c"}, s.handles()); diff != "" {
+		t.Errorf("Start(c): -want runners, +got runners:\n%s", diff)
+	}
+	if !runnerA.stopped {
+		t.Errorf("Start(c): want the least recently used runner to be stopped")
+	}
+	// releasing an evicted runner must not affect the others.
+	inUseA.Increment()
+	inUseA.Decrement()
+
+	inUseB.Increment()
+	// "c" has been handed out but it's not used yet.
+	c.Step(time.Second)
+	_, _, err = s.Start("d")
+	if !tferrors.IsRetryScheduleError(err) {
+		t.Errorf("Start(d): want a retry schedule error when the runners are either in use or reserved, got: %v", err)
+	}
+	inUseC, _, err := s.Start("c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inUseC.Increment()
+	_, _, err = s.Start("d")
+	if !tferrors.IsRetryScheduleError(err) {
+		t.Errorf("Start(d): want a retry schedule error when all runners are in use, got: %v", err)
+	}
+	if diff := cmp.Diff([]ProviderHandle{"b", "c"}, s.handles()); diff != "" {
+		t.Errorf("Start(d): -want runners, +got runners:\n%s", diff)
+	}
+	inUseC.Decrement()
+	// "c" is still reserved for its first caller, which has not used it.
+	if _, _, err := s.Start("d"); !tferrors.IsRetryScheduleError(err) {
+		t.Errorf("Start(d): want a retry schedule error when the runners are either in use or reserved, got: %v", err)
+	}
+	// the reservations that are not consumed expire.
+	c.Step(reservationTimeout)
+	if _, _, err := s.Start("d"); err != nil {
+		t.Fatalf("Start(d): %s", err)
+	}
+	if diff := cmp.Diff([]ProviderHandle{"b", "d"}, s.handles()); diff != "" {
+		t.Errorf("Start(d): -want runners, +got runners:\n%s", diff)
+	}
+}
+
+func TestSharedProviderSchedulerClose(t *testing.T) {
+	c := clocktesting.NewFakeClock(time.Now())
+	s := newTestScheduler(c, 100, WithIdleTimeout(time.Minute))
+	inUse, _, err := s.Start("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inUse.Increment()
+	runner := s.runners["a"][0].ProviderRunner.(*fakeRunner)
+	reaped := make(chan struct{})
+	go func() {
+		s.reapIdleRunners(time.Second)
+		close(reaped)
+	}()
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close(): %s", err)
+	}
+	if !runner.stopped {
+		t.Errorf("Close(): want the runners to be stopped")
+	}
+	if diff := cmp.Diff([]ProviderHandle{}, s.handles()); diff != "" {
+		t.Errorf("Close(): -want runners, +got runners:\n%s", diff)
+	}
+	select {
+	case <-reaped:
+	case <-time.After(5 * time.Second):
+		t.Errorf("Close(): want the idle runners not to be reaped anymore")
+	}
+	if _, _, err := s.Start("a"); err == nil {
+		t.Errorf("Start(a): want an error after the scheduler is closed")
+	}
+	// releasing a runner stopped by Close must not panic and closing again
+	// is a no-op.
+	inUse.Decrement()
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close(): %s", err)
+	}
+}
+
+func TestSharedProviderSchedulerPool(t *testing.T) {
+	c := clocktesting.NewFakeClock(time.Now())
+	s := newTestScheduler(c, 100, WithPoolSize(2))
+
+	inUse1, _, err := s.Start("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an idle runner is reused.
+	if _, _, err := s.Start("a"); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]ProviderHandle{"a"}, s.handles()); diff != "" {
+		t.Errorf("Start(a): -want runners, +got runners:\n%s", diff)
+	}
+	// a new runner is started when all the runners are in use.
+	inUse1.Increment()
+	inUse2, _, err := s.Start("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]ProviderHandle{"a", "a"}, s.handles()); diff != "" {
+		t.Errorf("Start(a): -want runners, +got runners:\n%s", diff)
+	}
+	inUse2.Increment()
+	inUse2.Increment()
+	// the least loaded runner is reused when the pool is full.
+	inUse3, _, err := s.Start("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]ProviderHandle{"a", "a"}, s.handles()); diff != "" {
+		t.Errorf("Start(a): -want runners, +got runners:\n%s", diff)
+	}
+	if inUse3.(*providerInUse).entry != inUse1.(*providerInUse).entry {
+		t.Errorf("Start(a): want the least loaded runner to be scheduled")
+	}
+}
+
+func TestSharedProviderSchedulerRotation(t *testing.T) {
+	c := clocktesting.NewFakeClock(time.Now())
+	s := newTestScheduler(c, 2)
+
+	inUse, _, err := s.Start("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inUse.Increment()
+	inUse.Increment()
+	expiring := inUse.(*providerInUse).entry
+	// the replacement is started while the expired runner is still in use.
+	replacement, _, err := s.Start("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replacement.(*providerInUse).entry == expiring {
+		t.Errorf("Start(a): want a replacement for the expired runner")
+	}
+	if diff := cmp.Diff([]ProviderHandle{"a", "a"}, s.handles()); diff != "" {
+		t.Errorf("Start(a): -want runners, +got runners:\n%s", diff)
+	}
+	// the expired runner is stopped when it's released.
+	inUse.Decrement()
+	if expiring.ProviderRunner.(*fakeRunner).stopped {
+		t.Errorf("Decrement(): want the draining runner to be running while it's in use")
+	}
+	inUse.Decrement()
+	if !expiring.ProviderRunner.(*fakeRunner).stopped {
+		t.Errorf("Decrement(): want the draining runner to be stopped when it's released")
+	}
+	if diff := cmp.Diff([]ProviderHandle{"a"}, s.handles()); diff != "" {
+		t.Errorf("Decrement(): -want runners, +got runners:\n%s", diff)
+	}
+}
+
+func TestSharedProviderSchedulerRotationAtCapacity(t *testing.T) {
+	c := clocktesting.NewFakeClock(time.Now())
+	s := newTestScheduler(c, 10, WithMaxRunners(1))
+
+	inUse, _, err := s.Start("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		inUse.Increment()
+	}
+	// no replacement can be started, so the expired runner is reused
+	// within its reuse budget.
+	reused, _, err := s.Start("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reused.(*providerInUse).entry != inUse.(*providerInUse).entry {
+		t.Errorf("Start(a): want the expired runner to be reused")
+	}
+	reused.Increment()
+	reused.Increment()
+	_, _, err = s.Start("a")
+	if !tferrors.IsRetryScheduleError(err) {
+		t.Errorf("Start(a): want a retry schedule error when the reuse budget is exceeded, got: %v", err)
+	}
+}
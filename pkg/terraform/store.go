@@ -130,6 +130,22 @@ type Setup struct {
 	// the lifecycle of Terraform provider processes will be managed by
 	// the Terraform CLI.
 	Scheduler ProviderScheduler
+
+	// AdditionalProviders contains the requirements and configurations of the
+	// Terraform providers other than the main one, keyed by their local names
+	// as configured in config.Provider.AdditionalSchemas. Please note that
+	// the scheduler only manages the native processes of the main provider.
+	AdditionalProviders map[string]AdditionalProvider
+}
+
+// AdditionalProvider holds the requirement and the configuration of a
+// Terraform provider other than the main one.
+type AdditionalProvider struct {
+	// Requirement contains the source and version of the provider.
+	Requirement ProviderRequirement
+
+	// Configuration contains the provider configuration parameters.
+	Configuration ProviderConfiguration
 }
 
 // Map returns the Setup object in map form. The initial reason was so that
@@ -182,6 +198,48 @@ func WithFeatures(f *feature.Flags) WorkspaceStoreOption {
 	}
 }
 
+// WithInvocationHistory configures the number of the most recent Terraform
+// CLI invocations recorded per workspace for the debug bundles. Defaults to
+// 10 and a non-positive value disables the recording.
+func WithInvocationHistory(n int) WorkspaceStoreOption {
+	return func(ws *WorkspaceStore) {
+		ws.invocationHistory = n
+	}
+}
+
+// WithRefreshBatching configures the workspaces to refresh their resources
+// in batches using the given RefreshBatcher, so that the managed resources
+// sharing a provider configuration are observed with fewer Terraform CLI
+// invocations. By default, each workspace is refreshed on its own.
+func WithRefreshBatching(b *RefreshBatcher) WorkspaceStoreOption {
+	return func(ws *WorkspaceStore) {
+		ws.batcher = b
+	}
+}
+
+// WithCLIConcurrency limits the number of the concurrent Terraform CLI
+// invocations of the given class, so that a burst of reconciliations, e.g.,
+// after a restart, does not run too many Terraform processes at once. The
+// invocations exceeding the limit wait for a slot, and the waiting apply
+// and destroy invocations are run before the others. A non-positive limit,
+// which is the default, means no limit.
+func WithCLIConcurrency(class CLIClass, n int) WorkspaceStoreOption {
+	return func(ws *WorkspaceStore) {
+		ws.cliLimits[class] = n
+	}
+}
+
+// WithMaxCLIInvocations limits the total number of the concurrent Terraform
+// CLI invocations of all the classes. The waiting invocations are
+// prioritized in the order of destroy, apply, init and the observations,
+// i.e., refresh, plan and import. A non-positive limit, which is the
+// default, means no limit.
+func WithMaxCLIInvocations(n int) WorkspaceStoreOption {
+	return func(ws *WorkspaceStore) {
+		ws.maxCLIInvocations = n
+	}
+}
+
 // NewWorkspaceStore returns a new WorkspaceStore.
 func NewWorkspaceStore(l logging.Logger, opts ...WorkspaceStoreOption) *WorkspaceStore {
 	ws := &WorkspaceStore{
@@ -191,10 +249,14 @@ func NewWorkspaceStore(l logging.Logger, opts ...WorkspaceStoreOption) *Workspac
 		fs:       afero.Afero{Fs: afero.NewOsFs()},
 		executor: exec.New(),
 		features: &feature.Flags{},
+
+		invocationHistory: defaultInvocationHistorySize,
+		cliLimits:         map[CLIClass]int{},
 	}
 	for _, f := range opts {
 		f(ws)
 	}
+	ws.limiter = newCLILimiter(ws.cliLimits, ws.maxCLIInvocations)
 	ws.initMetrics()
 	if ws.processReportInterval != 0 {
 		go ws.reportTFProcesses(ws.processReportInterval)
@@ -216,6 +278,11 @@ type WorkspaceStore struct {
 	executor              exec.Interface
 	disableInit           bool
 	features              *feature.Flags
+	invocationHistory     int
+	batcher               *RefreshBatcher
+	cliLimits             map[CLIClass]int
+	maxCLIInvocations     int
+	limiter               *cliLimiter
 }
 
 // Workspace makes sure the Terraform workspace for the given resource is ready
@@ -230,10 +297,12 @@ func (ws *WorkspaceStore) Workspace(ctx context.Context, c resource.SecretClient
 	w, ok := ws.store[tr.GetUID()]
 	if !ok {
 		l := ws.logger.WithValues("workspace", dir)
-		ws.store[tr.GetUID()] = NewWorkspace(dir, WithLogger(l), WithExecutor(ws.executor), WithFilterFn(ts.filterSensitiveInformation))
+		ws.store[tr.GetUID()] = NewWorkspace(dir, WithLogger(l), WithExecutor(ws.executor), WithFilterFn(ts.filterSensitiveInformation), WithInvocationHistorySize(ws.invocationHistory), WithRefreshBatcher(ws.batcher))
 		w = ws.store[tr.GetUID()]
+		w.limiter = ws.limiter
 	}
 	ws.mu.Unlock()
+	w.debug.setResource(cfg.TerraformResource)
 	// If there is an ongoing operation, no changes should be made in the
 	// workspace files.
 	if w.LastOperation.IsRunning() {
@@ -261,9 +330,11 @@ func (ws *WorkspaceStore) Workspace(ctx context.Context, c resource.SecretClient
 		}
 	}
 
-	if w.ProviderHandle, err = fp.WriteMainTF(); err != nil {
+	h, err := fp.WriteMainTF()
+	if err != nil {
 		return nil, errors.Wrap(err, "cannot write main tf file")
 	}
+	w.setProviderHandle(h)
 	if isNeedProviderUpgrade {
 		out, err := w.runTF(ctx, ModeSync, "init", "-upgrade", "-input=false")
 		w.logger.Debug("init -upgrade ended", "out", ts.filterSensitiveInformation(string(out)))
@@ -303,6 +374,20 @@ func (ws *WorkspaceStore) Remove(obj xpresource.Object) error {
 	return nil
 }
 
+// RunningOperations returns the UIDs of the managed resources whose
+// asynchronous operations are running.
+func (ws *WorkspaceStore) RunningOperations() []types.UID {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	var result []types.UID
+	for uid, w := range ws.store {
+		if w.LastOperation.IsRunning() {
+			result = append(result, uid)
+		}
+	}
+	return result
+}
+
 func (ws *WorkspaceStore) initMetrics() {
 	for _, mode := range []ExecMode{ModeSync, ModeASync} {
 		for _, subcommand := range []string{"init", "apply", "destroy", "plan"} {
@@ -312,9 +397,16 @@ func (ws *WorkspaceStore) initMetrics() {
 }
 
 func (ts Setup) filterSensitiveInformation(s string) string {
-	for _, v := range ts.Configuration {
-		if str, ok := v.(string); ok && str != "" {
-			s = strings.ReplaceAll(s, str, "REDACTED")
+	configurations := make([]ProviderConfiguration, 0, len(ts.AdditionalProviders)+1)
+	configurations = append(configurations, ts.Configuration)
+	for _, ap := range ts.AdditionalProviders {
+		configurations = append(configurations, ap.Configuration)
+	}
+	for _, c := range configurations {
+		for _, v := range c {
+			if str, ok := v.(string); ok && str != "" {
+				s = strings.ReplaceAll(s, str, valRedacted)
+			}
 		}
 	}
 	return s
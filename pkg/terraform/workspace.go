@@ -109,6 +109,24 @@ func WithProviderInUse(providerInUse InUse) WorkspaceOption {
 	}
 }
 
+// WithInvocationHistorySize configures the number of the most recent
+// Terraform CLI invocations recorded for the debug bundles of the Workspace.
+// Defaults to 10 and a non-positive size disables the recording.
+func WithInvocationHistorySize(n int) WorkspaceOption {
+	return func(w *Workspace) {
+		w.debug.maxInvocations = n
+	}
+}
+
+// WithRefreshBatcher configures the Workspace to refresh its resource in
+// batches with the other workspaces sharing its provider configuration using
+// the given RefreshBatcher.
+func WithRefreshBatcher(b *RefreshBatcher) WorkspaceOption {
+	return func(w *Workspace) {
+		w.batcher = b
+	}
+}
+
 // NewWorkspace returns a new Workspace object that operates in the given
 // directory.
 func NewWorkspace(dir string, opts ...WorkspaceOption) *Workspace {
@@ -119,6 +137,7 @@ func NewWorkspace(dir string, opts ...WorkspaceOption) *Workspace {
 		fs:            afero.Afero{Fs: afero.NewOsFs()},
 		providerInUse: noopInUse{},
 		mu:            &sync.Mutex{},
+		debug:         &debugInfo{maxInvocations: defaultInvocationHistorySize},
 	}
 	for _, f := range opts {
 		f(w)
@@ -137,7 +156,10 @@ type Workspace struct {
 	LastOperation *Operation
 	// ProviderHandle is the handle of the associated native Terraform provider
 	// computed from the generated provider resource configuration block
-	// of the Terraform workspace.
+	// of the Terraform workspace. It's set while the workspace is being
+	// prepared for a reconciliation, hence the readers that run concurrently
+	// with the reconciler, e.g. the debug handler, must read it via
+	// providerHandle.
 	ProviderHandle ProviderHandle
 
 	dir string
@@ -148,16 +170,42 @@ type Workspace struct {
 	providerInUse InUse
 	fs            afero.Afero
 	mu            *sync.Mutex
+	// handleMu guards ProviderHandle. The lock of the workspace cannot be
+	// used because it's held during the Terraform CLI invocations.
+	handleMu sync.RWMutex
 
 	filterFn func(string) string
+	debug    *debugInfo
+	batcher  *RefreshBatcher
+	limiter  *cliLimiter
 
 	terraformID string
 }
 
+// providerHandle returns the ProviderHandle of the Workspace.
+func (w *Workspace) providerHandle() ProviderHandle {
+	w.handleMu.RLock()
+	defer w.handleMu.RUnlock()
+	return w.ProviderHandle
+}
+
+// setProviderHandle sets the ProviderHandle of the Workspace.
+func (w *Workspace) setProviderHandle(h ProviderHandle) {
+	w.handleMu.Lock()
+	defer w.handleMu.Unlock()
+	w.ProviderHandle = h
+}
+
 // UseProvider shares a native provider with the receiver Workspace.
 func (w *Workspace) UseProvider(inuse InUse, attachmentConfig string) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
+	w.useProvider(inuse, attachmentConfig)
+}
+
+// useProvider shares a native provider with the receiver Workspace. The
+// caller must hold the lock of the Workspace.
+func (w *Workspace) useProvider(inuse InUse, attachmentConfig string) {
 	// remove existing reattach configs
 	env := make([]string, 0, len(w.env))
 	prefix := fmt.Sprintf(fmtEnv, envReattachConfig, "")
@@ -169,6 +217,7 @@ func (w *Workspace) UseProvider(inuse InUse, attachmentConfig string) {
 	env = append(env, prefix+attachmentConfig)
 	w.env = env
 	w.providerInUse = inuse
+	w.debug.attach(attachmentConfig)
 }
 
 // ApplyAsync makes a terraform apply call without blocking and calls the given
@@ -286,6 +335,14 @@ func (w *Workspace) Refresh(ctx context.Context) (RefreshResult, error) {
 	case w.LastOperation.IsEnded():
 		defer w.LastOperation.Flush()
 	}
+	if w.batcher != nil {
+		return w.batcher.refresh(ctx, w)
+	}
+	return w.refresh(ctx)
+}
+
+// refresh runs a refresh-only apply in the Workspace.
+func (w *Workspace) refresh(ctx context.Context) (RefreshResult, error) {
 	out, err := w.runTF(ctx, ModeSync, "apply", "-refresh-only", "-auto-approve", "-input=false", "-lock=false", "-json")
 	w.logger.Debug("refresh ended", "out", w.filterFn(string(out)))
 	if err != nil {
@@ -410,6 +467,12 @@ func (w *Workspace) runTF(ctx context.Context, execMode ExecMode, args ...string
 	if len(args) < 1 {
 		return nil, errors.New("args cannot be empty")
 	}
+	release, err := w.limiter.acquire(ctx, args)
+	if err != nil {
+		// the callers report the output of the failed invocations.
+		return []byte(err.Error()), err
+	}
+	defer release()
 	w.logger.Debug("Running terraform", "args", args)
 	if execMode == ModeSync {
 		w.providerInUse.Increment()
@@ -417,6 +480,45 @@ func (w *Workspace) runTF(ctx context.Context, execMode ExecMode, args ...string
 	defer w.providerInUse.Decrement()
 	w.mu.Lock()
 	defer w.mu.Unlock()
+	out, err := w.execTF(ctx, execMode, args...)
+	if err == nil || !isProviderUnavailable(out) {
+		return out, err
+	}
+	// The CLI could not reach the shared native provider, e.g., because it
+	// has crashed. If the scheduler can restart it, the invocation is
+	// retried once with the restarted native provider.
+	r, ok := w.providerInUse.(ProviderRestarter)
+	if !ok {
+		return out, err
+	}
+	inuse, attachmentConfig, rErr := r.Restart()
+	if rErr != nil {
+		w.logger.Info("Cannot restart the unavailable native provider", "error", rErr)
+		return out, err
+	}
+	w.logger.Debug("Native provider is unavailable, retrying with the restarted one", "args", args)
+	w.useProvider(inuse, attachmentConfig)
+	inuse.Increment()
+	defer inuse.Decrement()
+	return w.execTF(ctx, execMode, args...)
+}
+
+// batchWorkspace returns a Workspace operating in the given directory, which
+// shares the executor and the native provider of the receiver Workspace.
+func (w *Workspace) batchWorkspace(dir string) *Workspace {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	bw := NewWorkspace(dir, WithLogger(w.logger.WithValues("batch", dir)), WithExecutor(w.executor), WithAferoFs(w.fs.Fs),
+		WithFilterFn(w.filterFn), WithProviderInUse(w.providerInUse), WithInvocationHistorySize(1))
+	bw.ProviderHandle = w.providerHandle()
+	bw.limiter = w.limiter
+	bw.env = append([]string(nil), w.env...)
+	return bw
+}
+
+// execTF runs the Terraform CLI with the given arguments. The caller must
+// hold the lock of the Workspace.
+func (w *Workspace) execTF(ctx context.Context, execMode ExecMode, args ...string) ([]byte, error) {
 	cmd := w.executor.CommandContext(ctx, "terraform", args...)
 	cmd.SetEnv(append(os.Environ(), w.env...))
 	cmd.SetDir(w.dir)
@@ -426,5 +528,7 @@ func (w *Workspace) runTF(ctx context.Context, execMode ExecMode, args ...string
 		metrics.CLITime.WithLabelValues(args[0], execMode.String()).Observe(time.Since(start).Seconds())
 		metrics.CLIExecutions.WithLabelValues(args[0], execMode.String()).Dec()
 	}()
-	return cmd.CombinedOutput()
+	out, err := cmd.CombinedOutput()
+	w.debug.record(w.newInvocation(execMode, args, start, out, err))
+	return out, err
 }
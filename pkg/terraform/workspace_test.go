@@ -475,3 +475,95 @@ func TestWorkspaceDestroyAsync(t *testing.T) {
 		})
 	}
 }
+
+type fakeRestarter struct {
+	restarted int
+	err       error
+}
+
+func (f *fakeRestarter) Increment() {}
+
+func (f *fakeRestarter) Decrement() {}
+
+func (f *fakeRestarter) Restart() (InUse, string, error) {
+	f.restarted++
+	return noopInUse{}, "restarted-reattach-config", f.err
+}
+
+func TestWorkspaceRetryUnavailableProvider(t *testing.T) {
+	unavailable := "Error: Failed to load plugin schemas: dial unix /tmp/plugin123: connect: connection refused"
+	newExec := func(outs ...string) *testingexec.FakeExec {
+		e := &testingexec.FakeExec{}
+		for _, out := range outs {
+			out := out
+			var err error
+			if out != "" {
+				err = errors.New(out)
+			}
+			e.CommandScript = append(e.CommandScript, func(_ string, _ ...string) k8sExec.Cmd {
+				return &testingexec.FakeCmd{
+					CombinedOutputScript: []testingexec.FakeAction{
+						func() ([]byte, []byte, error) {
+							return []byte(out), nil, err
+						},
+					},
+				}
+			})
+		}
+		return e
+	}
+	type want struct {
+		restarted int
+		err       error
+	}
+	cases := map[string]struct {
+		exec       *testingexec.FakeExec
+		restartErr error
+		want       want
+	}{
+		"RetriedWithRestartedProvider": {
+			exec: newExec(unavailable, ""),
+			want: want{
+				restarted: 1,
+			},
+		},
+		"RetriedOnlyOnce": {
+			exec: newExec(unavailable, unavailable),
+			want: want{
+				restarted: 1,
+				err:       tferrors.NewRefreshFailed([]byte(unavailable)),
+			},
+		},
+		"RestartFailed": {
+			exec:       newExec(unavailable),
+			restartErr: errBoom,
+			want: want{
+				restarted: 1,
+				err:       tferrors.NewRefreshFailed([]byte(unavailable)),
+			},
+		},
+		"NotRetriedOnOtherErrors": {
+			exec: newExec(errBoom.Error()),
+			want: want{
+				err: tferrors.NewRefreshFailed([]byte(errBoom.Error())),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := NewWorkspace(directory, WithExecutor(tc.exec), WithAferoFs(fs), WithFilterFn(filterFn))
+			r := &fakeRestarter{err: tc.restartErr}
+			w.UseProvider(r, "reattach-config")
+			if err := w.fs.WriteFile(directory+"terraform.tfstate", []byte(tfstate), 0777); err != nil {
+				panic(err)
+			}
+			_, err := w.Refresh(context.TODO())
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nRefresh(...): -want error, +got error:\n%s", name, diff)
+			}
+			if diff := cmp.Diff(tc.want.restarted, r.restarted); diff != "" {
+				t.Errorf("\n%s\nRefresh(...): -want restarts, +got restarts:\n%s", name, diff)
+			}
+		})
+	}
+}
@@ -37,24 +37,42 @@ import (
 // there exactly for this purpose, an external representation of Terraform
 // schemas. This conversion aims to be an intermediate step for that ultimate
 // goal.
+// It panics on errors, see GetV2ResourceMapE for an error-returning variant.
 func GetV2ResourceMap(resourceSchemas map[string]*tfjson.Schema) map[string]*schemav2.Resource {
+	v2map, err := GetV2ResourceMapE(resourceSchemas)
+	if err != nil {
+		panic(err)
+	}
+	return v2map
+}
+
+// GetV2ResourceMapE is the error-returning variant of GetV2ResourceMap.
+func GetV2ResourceMapE(resourceSchemas map[string]*tfjson.Schema) (map[string]*schemav2.Resource, error) {
 	v2map := make(map[string]*schemav2.Resource, len(resourceSchemas))
 	for k, v := range resourceSchemas {
-		v2map[k] = v2ResourceFromTFJSONSchema(v)
+		r, err := v2ResourceFromTFJSONSchema(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot convert the schema of %s", k)
+		}
+		v2map[k] = r
 	}
-	return v2map
+	return v2map, nil
 }
 
-func v2ResourceFromTFJSONSchema(s *tfjson.Schema) *schemav2.Resource {
+func v2ResourceFromTFJSONSchema(s *tfjson.Schema) (*schemav2.Resource, error) {
 	v2Res := &schemav2.Resource{SchemaVersion: int(s.Version)}
 	if s.Block == nil {
-		return v2Res
+		return v2Res, nil
 	}
 
 	toSchemaMap := make(map[string]*schemav2.Schema, len(s.Block.Attributes)+len(s.Block.NestedBlocks))
 
 	for k, v := range s.Block.Attributes {
-		toSchemaMap[k] = tfJSONAttributeToV2Schema(v)
+		sch, err := tfJSONAttributeToV2Schema(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot convert attribute %s", k)
+		}
+		toSchemaMap[k] = sch
 	}
 	for k, v := range s.Block.NestedBlocks {
 		// Note(turkenh): We see resource timeouts here as NestingModeSingle.
@@ -65,16 +83,20 @@ func v2ResourceFromTFJSONSchema(s *tfjson.Schema) *schemav2.Resource {
 		if v.NestingMode == tfjson.SchemaNestingModeSingle {
 			continue
 		}
-		toSchemaMap[k] = tfJSONBlockTypeToV2Schema(v)
+		sch, err := tfJSONBlockTypeToV2Schema(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot convert block %s", k)
+		}
+		toSchemaMap[k] = sch
 	}
 
 	v2Res.Schema = toSchemaMap
 	v2Res.Description = s.Block.Description
 	v2Res.DeprecationMessage = deprecatedMessage(s.Block.Deprecated)
-	return v2Res
+	return v2Res, nil
 }
 
-func tfJSONAttributeToV2Schema(attr *tfjson.SchemaAttribute) *schemav2.Schema {
+func tfJSONAttributeToV2Schema(attr *tfjson.SchemaAttribute) (*schemav2.Schema, error) {
 	v2sch := &schemav2.Schema{
 		Optional:    attr.Optional,
 		Required:    attr.Required,
@@ -84,12 +106,12 @@ func tfJSONAttributeToV2Schema(attr *tfjson.SchemaAttribute) *schemav2.Schema {
 		Sensitive:   attr.Sensitive,
 	}
 	if err := schemaV2TypeFromCtyType(attr.AttributeType, v2sch); err != nil {
-		panic(err)
+		return nil, err
 	}
-	return v2sch
+	return v2sch, nil
 }
 
-func tfJSONBlockTypeToV2Schema(nb *tfjson.SchemaBlockType) *schemav2.Schema { //nolint:gocyclo
+func tfJSONBlockTypeToV2Schema(nb *tfjson.SchemaBlockType) (*schemav2.Schema, error) { //nolint:gocyclo
 	v2sch := &schemav2.Schema{
 		MinItems: int(nb.MinItems),
 		MaxItems: int(nb.MaxItems),
@@ -115,13 +137,13 @@ func tfJSONBlockTypeToV2Schema(nb *tfjson.SchemaBlockType) *schemav2.Schema { //
 	case tfjson.SchemaNestingModeMap:
 		v2sch.Type = schemav2.TypeMap
 	case tfjson.SchemaNestingModeSingle, tfjson.SchemaNestingModeGroup:
-		panic("unexpected nesting mode: " + nb.NestingMode)
+		return nil, errors.Errorf("unexpected nesting mode: %s", nb.NestingMode)
 	default:
-		panic("unknown nesting mode: " + nb.NestingMode)
+		return nil, errors.Errorf("unknown nesting mode: %s", nb.NestingMode)
 	}
 
 	if nb.Block == nil {
-		return v2sch
+		return v2sch, nil
 	}
 
 	v2sch.Description = nb.Block.Description
@@ -130,7 +152,11 @@ func tfJSONBlockTypeToV2Schema(nb *tfjson.SchemaBlockType) *schemav2.Schema { //
 	res := &schemav2.Resource{}
 	res.Schema = make(map[string]*schemav2.Schema, len(nb.Block.Attributes)+len(nb.Block.NestedBlocks))
 	for key, attr := range nb.Block.Attributes {
-		res.Schema[key] = tfJSONAttributeToV2Schema(attr)
+		sch, err := tfJSONAttributeToV2Schema(attr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot convert attribute %s", key)
+		}
+		res.Schema[key] = sch
 	}
 	for key, block := range nb.Block.NestedBlocks {
 		// Note(turkenh): We see resource timeouts here as NestingModeSingle.
@@ -141,10 +167,14 @@ func tfJSONBlockTypeToV2Schema(nb *tfjson.SchemaBlockType) *schemav2.Schema { //
 		if block.NestingMode == tfjson.SchemaNestingModeSingle {
 			continue
 		}
-		res.Schema[key] = tfJSONBlockTypeToV2Schema(block)
+		sch, err := tfJSONBlockTypeToV2Schema(block)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot convert block %s", key)
+		}
+		res.Schema[key] = sch
 	}
 	v2sch.Elem = res
-	return v2sch
+	return v2sch, nil
 }
 
 func schemaV2TypeFromCtyType(typ cty.Type, schema *schemav2.Schema) error { //nolint:gocyclo